@@ -16,6 +16,54 @@ type AgentConfig struct {
 
 	// Configuration for remote hosts.
 	RemoteHosts []*RemoteHost `pkl:"remoteHosts"`
+
+	// Snapshot storage settings. Defaults apply when unset.
+	Storage *StorageConfig `pkl:"storage"`
+
+	// Locations of the host filesystems when the agent runs in a container.
+	// The HOST_ROOT, HOST_PROC, HOST_SYS, HOST_ETC and HOST_RUN environment
+	// variables take precedence over these settings.
+	Host *HostConfig `pkl:"host"`
+
+	// Cloud instance metadata settings. Snapshots are not labelled with
+	// instance metadata when unset.
+	Cloud *CloudConfig `pkl:"cloud"`
+
+	// StatsD and DogStatsD receiver settings. The receiver is disabled when unset.
+	Statsd *StatsDConfig `pkl:"statsd"`
+
+	// Expected schedules of jobs run with `sailfin run`, used to detect missed runs.
+	Jobs []*JobSchedule `pkl:"jobs"`
+
+	// HTTP endpoints returning JSON (such as expvar's /debug/vars) to scrape.
+	JsonScrapers []*JSONScraper `pkl:"jsonScrapers"`
+
+	// DNS resolution probes.
+	DnsProbes []*DNSProbe `pkl:"dnsProbes"`
+
+	// NTP clock offset collector settings. The collector is disabled when unset.
+	Ntp *NTPConfig `pkl:"ntp"`
+
+	// Docker Engine collector settings. The collector is disabled when unset.
+	Docker *DockerConfig `pkl:"docker"`
+
+	// nginx and Apache status page collector settings. The collectors are disabled when unset.
+	WebStatus *WebStatusConfig `pkl:"webStatus"`
+
+	// Redis collector settings. The collector is disabled when unset.
+	Redis *RedisConfig `pkl:"redis"`
+
+	// Memcached collector settings. The collector is disabled when unset.
+	Memcached *MemcachedConfig `pkl:"memcached"`
+
+	// Directory size scanner settings. The scanner is disabled when unset.
+	DiskScan *DiskScanConfig `pkl:"diskScan"`
+
+	// File integrity monitoring settings. Monitoring is disabled when unset.
+	Integrity *IntegrityConfig `pkl:"integrity"`
+
+	// TLS certificate expiry collector settings. The collector is disabled when unset.
+	Certificates *CertificateConfig `pkl:"certificates"`
 }
 
 // LoadFromPath loads the pkl module at the given path and evaluates it into a AgentConfig
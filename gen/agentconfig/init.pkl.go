@@ -6,4 +6,22 @@ import "github.com/apple/pkl-go/pkl"
 func init() {
 	pkl.RegisterMapping("SailfinIO.agent.AgentConfig", AgentConfig{})
 	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#RemoteHost", RemoteHost{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#StorageConfig", StorageConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#HostConfig", HostConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#CloudConfig", CloudConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#StatsDConfig", StatsDConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#JobSchedule", JobSchedule{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#JSONScraper", JSONScraper{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#JSONMetric", JSONMetric{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#DNSProbe", DNSProbe{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#NTPConfig", NTPConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#DockerConfig", DockerConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#WebStatusConfig", WebStatusConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#RedisConfig", RedisConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#MemcachedConfig", MemcachedConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#DiskScanConfig", DiskScanConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#IntegrityConfig", IntegrityConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#CertificateConfig", CertificateConfig{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#StorageRollup", StorageRollup{})
+	pkl.RegisterMapping("SailfinIO.agent.AgentConfig#StorageEncryption", StorageEncryption{})
 }
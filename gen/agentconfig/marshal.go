@@ -3,6 +3,8 @@ package agentconfig
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // Marshal converts the AgentConfig struct into a PKL-formatted []byte.
@@ -34,5 +36,231 @@ func Marshal(cfg *AgentConfig) ([]byte, error) {
 	}
 	buf.WriteString(")\n")
 
+	// Write the optional storage block.
+	if cfg.Storage != nil {
+		buf.WriteString("storage = new StorageConfig {\n")
+		buf.WriteString(fmt.Sprintf("  backend = %q\n", cfg.Storage.Backend))
+		writeOptionalString(&buf, "path", cfg.Storage.Path)
+		buf.WriteString(fmt.Sprintf("  fsync = %q\n", cfg.Storage.Fsync))
+		buf.WriteString(fmt.Sprintf("  fsyncIntervalSeconds = %d\n", cfg.Storage.FsyncIntervalSeconds))
+		buf.WriteString(fmt.Sprintf("  segmentMaxSnapshots = %d\n", cfg.Storage.SegmentMaxSnapshots))
+		buf.WriteString(fmt.Sprintf("  segmentMaxBytes = %d\n", cfg.Storage.SegmentMaxBytes))
+		buf.WriteString(fmt.Sprintf("  blockDurationSeconds = %d\n", cfg.Storage.BlockDurationSeconds))
+		buf.WriteString("  rollups = List(\n")
+		for _, r := range cfg.Storage.Rollups {
+			buf.WriteString("    new StorageRollup {\n")
+			buf.WriteString(fmt.Sprintf("      resolutionSeconds = %d\n", r.ResolutionSeconds))
+			buf.WriteString(fmt.Sprintf("      retentionSeconds = %d\n", r.RetentionSeconds))
+			buf.WriteString("    }\n")
+		}
+		buf.WriteString("  )\n")
+		if e := cfg.Storage.Encryption; e != nil {
+			buf.WriteString("  encryption = new StorageEncryption {\n")
+			if e.KeyFile != nil {
+				buf.WriteString(fmt.Sprintf("    keyFile = %q\n", *e.KeyFile))
+			}
+			if e.KeyEnv != nil {
+				buf.WriteString(fmt.Sprintf("    keyEnv = %q\n", *e.KeyEnv))
+			}
+			buf.WriteString("  }\n")
+		}
+		buf.WriteString(fmt.Sprintf("  maxSnapshots = %d\n", cfg.Storage.MaxSnapshots))
+		buf.WriteString(fmt.Sprintf("  maxAgeSeconds = %d\n", cfg.Storage.MaxAgeSeconds))
+		buf.WriteString(fmt.Sprintf("  maxBytes = %d\n", cfg.Storage.MaxBytes))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional host block.
+	if cfg.Host != nil {
+		buf.WriteString("host = new HostConfig {\n")
+		writeOptionalString(&buf, "root", cfg.Host.Root)
+		writeOptionalString(&buf, "proc", cfg.Host.Proc)
+		writeOptionalString(&buf, "sys", cfg.Host.Sys)
+		writeOptionalString(&buf, "etc", cfg.Host.Etc)
+		writeOptionalString(&buf, "run", cfg.Host.Run)
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional cloud block.
+	if cfg.Cloud != nil {
+		buf.WriteString("cloud = new CloudConfig {\n")
+		buf.WriteString(fmt.Sprintf("  providers = %s\n", stringList(cfg.Cloud.Providers)))
+		buf.WriteString(fmt.Sprintf("  awsBaseUrl = %q\n", cfg.Cloud.AwsBaseUrl))
+		buf.WriteString(fmt.Sprintf("  gcpBaseUrl = %q\n", cfg.Cloud.GcpBaseUrl))
+		buf.WriteString(fmt.Sprintf("  azureBaseUrl = %q\n", cfg.Cloud.AzureBaseUrl))
+		buf.WriteString(fmt.Sprintf("  timeoutSeconds = %d\n", cfg.Cloud.TimeoutSeconds))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional statsd block.
+	if cfg.Statsd != nil {
+		buf.WriteString("statsd = new StatsDConfig {\n")
+		buf.WriteString(fmt.Sprintf("  address = %q\n", cfg.Statsd.Address))
+		writeOptionalString(&buf, "socketPath", cfg.Statsd.SocketPath)
+		buf.WriteString(fmt.Sprintf("  flushIntervalSeconds = %d\n", cfg.Statsd.FlushIntervalSeconds))
+		buf.WriteString(fmt.Sprintf("  maxSeries = %d\n", cfg.Statsd.MaxSeries))
+		buf.WriteString(fmt.Sprintf("  gaugeExpiryFlushes = %d\n", cfg.Statsd.GaugeExpiryFlushes))
+		buf.WriteString("}\n")
+	}
+
+	// Write the jobs list.
+	if len(cfg.Jobs) > 0 {
+		buf.WriteString("jobs = List(\n")
+		for _, j := range cfg.Jobs {
+			buf.WriteString("  new JobSchedule {\n")
+			buf.WriteString(fmt.Sprintf("    name = %q\n", j.Name))
+			buf.WriteString(fmt.Sprintf("    schedule = %q\n", j.Schedule))
+			buf.WriteString(fmt.Sprintf("    graceSeconds = %d\n", j.GraceSeconds))
+			buf.WriteString("  },\n")
+		}
+		buf.WriteString(")\n")
+	}
+
+	// Write the jsonScrapers list.
+	if len(cfg.JsonScrapers) > 0 {
+		buf.WriteString("jsonScrapers = List(\n")
+		for _, s := range cfg.JsonScrapers {
+			buf.WriteString("  new JSONScraper {\n")
+			buf.WriteString(fmt.Sprintf("    name = %q\n", s.Name))
+			buf.WriteString(fmt.Sprintf("    url = %q\n", s.Url))
+			writeMapping(&buf, "    ", "labels", s.Labels)
+			writeMapping(&buf, "    ", "headers", s.Headers)
+			buf.WriteString(fmt.Sprintf("    timeoutSeconds = %d\n", s.TimeoutSeconds))
+			buf.WriteString("    metrics = List(\n")
+			for _, m := range s.Metrics {
+				buf.WriteString("      new JSONMetric {\n")
+				buf.WriteString(fmt.Sprintf("        name = %q\n", m.Name))
+				buf.WriteString(fmt.Sprintf("        path = %q\n", m.Path))
+				writeMapping(&buf, "        ", "labels", m.Labels)
+				buf.WriteString(fmt.Sprintf("        wildcardLabels = %s\n", stringList(m.WildcardLabels)))
+				buf.WriteString("      },\n")
+			}
+			buf.WriteString("    )\n")
+			buf.WriteString("  },\n")
+		}
+		buf.WriteString(")\n")
+	}
+
+	// Write the dnsProbes list.
+	if len(cfg.DnsProbes) > 0 {
+		buf.WriteString("dnsProbes = List(\n")
+		for _, p := range cfg.DnsProbes {
+			buf.WriteString("  new DNSProbe {\n")
+			buf.WriteString(fmt.Sprintf("    name = %q\n", p.Name))
+			buf.WriteString(fmt.Sprintf("    type = %q\n", p.Type))
+			buf.WriteString(fmt.Sprintf("    resolvers = %s\n", stringList(p.Resolvers)))
+			buf.WriteString(fmt.Sprintf("    expected = %s\n", stringList(p.Expected)))
+			buf.WriteString(fmt.Sprintf("    timeoutSeconds = %d\n", p.TimeoutSeconds))
+			buf.WriteString("  },\n")
+		}
+		buf.WriteString(")\n")
+	}
+
+	// Write the optional ntp block.
+	if cfg.Ntp != nil {
+		buf.WriteString("ntp = new NTPConfig {\n")
+		buf.WriteString(fmt.Sprintf("  servers = %s\n", stringList(cfg.Ntp.Servers)))
+		buf.WriteString(fmt.Sprintf("  timeoutSeconds = %d\n", cfg.Ntp.TimeoutSeconds))
+		buf.WriteString(fmt.Sprintf("  maxOffsetMilliseconds = %d\n", cfg.Ntp.MaxOffsetMilliseconds))
+		buf.WriteString(fmt.Sprintf("  pollIntervalSeconds = %d\n", cfg.Ntp.PollIntervalSeconds))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional docker block.
+	if cfg.Docker != nil {
+		buf.WriteString("docker = new DockerConfig {\n")
+		buf.WriteString(fmt.Sprintf("  socketPath = %q\n", cfg.Docker.SocketPath))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional webStatus block.
+	if cfg.WebStatus != nil {
+		buf.WriteString("webStatus = new WebStatusConfig {\n")
+		buf.WriteString(fmt.Sprintf("  nginx = %s\n", stringList(cfg.WebStatus.Nginx)))
+		buf.WriteString(fmt.Sprintf("  apache = %s\n", stringList(cfg.WebStatus.Apache)))
+		buf.WriteString(fmt.Sprintf("  timeoutSeconds = %d\n", cfg.WebStatus.TimeoutSeconds))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional redis block.
+	if cfg.Redis != nil {
+		buf.WriteString("redis = new RedisConfig {\n")
+		buf.WriteString(fmt.Sprintf("  addresses = %s\n", stringList(cfg.Redis.Addresses)))
+		writeOptionalString(&buf, "password", cfg.Redis.Password)
+		buf.WriteString(fmt.Sprintf("  timeoutSeconds = %d\n", cfg.Redis.TimeoutSeconds))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional memcached block.
+	if cfg.Memcached != nil {
+		buf.WriteString("memcached = new MemcachedConfig {\n")
+		buf.WriteString(fmt.Sprintf("  addresses = %s\n", stringList(cfg.Memcached.Addresses)))
+		buf.WriteString(fmt.Sprintf("  timeoutSeconds = %d\n", cfg.Memcached.TimeoutSeconds))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional diskScan block.
+	if cfg.DiskScan != nil {
+		buf.WriteString("diskScan = new DiskScanConfig {\n")
+		buf.WriteString(fmt.Sprintf("  paths = %s\n", stringList(cfg.DiskScan.Paths)))
+		buf.WriteString(fmt.Sprintf("  topN = %d\n", cfg.DiskScan.TopN))
+		buf.WriteString(fmt.Sprintf("  maxDepth = %d\n", cfg.DiskScan.MaxDepth))
+		buf.WriteString(fmt.Sprintf("  intervalSeconds = %d\n", cfg.DiskScan.IntervalSeconds))
+		buf.WriteString(fmt.Sprintf("  maxEntriesPerSecond = %d\n", cfg.DiskScan.MaxEntriesPerSecond))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional integrity block.
+	if cfg.Integrity != nil {
+		buf.WriteString("integrity = new IntegrityConfig {\n")
+		buf.WriteString(fmt.Sprintf("  paths = %s\n", stringList(cfg.Integrity.Paths)))
+		writeOptionalString(&buf, "baselinePath", cfg.Integrity.BaselinePath)
+		buf.WriteString(fmt.Sprintf("  maxHashBytes = %d\n", cfg.Integrity.MaxHashBytes))
+		buf.WriteString("}\n")
+	}
+
+	// Write the optional certificates block.
+	if cfg.Certificates != nil {
+		buf.WriteString("certificates = new CertificateConfig {\n")
+		buf.WriteString(fmt.Sprintf("  files = %s\n", stringList(cfg.Certificates.Files)))
+		buf.WriteString(fmt.Sprintf("  endpoints = %s\n", stringList(cfg.Certificates.Endpoints)))
+		writeOptionalString(&buf, "caFile", cfg.Certificates.CaFile)
+		buf.WriteString(fmt.Sprintf("  timeoutSeconds = %d\n", cfg.Certificates.TimeoutSeconds))
+		buf.WriteString("}\n")
+	}
+
 	return buf.Bytes(), nil
 }
+
+// stringList renders a slice of strings as a PKL List literal.
+func stringList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", v))
+	}
+	return "List(" + strings.Join(quoted, ", ") + ")"
+}
+
+// writeOptionalString writes a nested "name = value" line when value is set.
+func writeOptionalString(buf *bytes.Buffer, name string, value *string) {
+	if value != nil {
+		buf.WriteString(fmt.Sprintf("  %s = %q\n", name, *value))
+	}
+}
+
+// writeMapping writes a PKL Mapping block with sorted keys when m is non-empty.
+func writeMapping(buf *bytes.Buffer, indent, name string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf.WriteString(fmt.Sprintf("%s%s {\n", indent, name))
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf("%s  [%q] = %q\n", indent, k, m[k]))
+	}
+	buf.WriteString(indent + "}\n")
+}
@@ -3,12 +3,13 @@ module github.com/SailfinIO/agent
 go 1.24.0
 
 require (
+	github.com/apple/pkl-go v0.9.0
 	github.com/shirou/gopsutil v3.21.11+incompatible
 	github.com/spf13/cobra v1.9.1
+	golang.org/x/sys v0.31.0
 )
 
 require (
-	github.com/apple/pkl-go v0.9.0 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/magiconair/properties v1.8.7 // indirect
@@ -38,5 +39,4 @@ require (
 	github.com/tklauser/go-sysconf v0.3.15 // indirect
 	github.com/tklauser/numcpus v0.10.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
-	golang.org/x/sys v0.31.0 // indirect
 )
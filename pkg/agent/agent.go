@@ -3,14 +3,21 @@
 package agent
 
 import (
+	"context"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/SailfinIO/agent/pkg/collector"
 	"github.com/SailfinIO/agent/pkg/config"
+	"github.com/SailfinIO/agent/pkg/ingest"
+	"github.com/SailfinIO/agent/pkg/inventory"
+	"github.com/SailfinIO/agent/pkg/jobs"
 	"github.com/SailfinIO/agent/pkg/server"
 	"github.com/SailfinIO/agent/pkg/storage"
 	"github.com/SailfinIO/agent/pkg/utils"
@@ -28,6 +35,20 @@ type Agent struct {
 	collectors []collector.Collector // Use collector.Collector from the collector package.
 	storage    storage.Storage
 	logger     utils.Logger
+	dirScanner *collector.DirSizeScanner
+	integrity  *collector.IntegrityMonitor
+	packages   *collector.PackageCollector
+	inventory  *inventory.Inventory
+	jobs       *jobs.History
+	statsd     *collector.StatsDServer
+	ingested   *ingest.Buffer
+
+	// saveMu orders collected snapshots after imported ones; storage
+	// expects timestamps that do not decrease.
+	saveMu sync.Mutex
+
+	labelsMu   sync.RWMutex
+	hostLabels map[string]string // Instance metadata attached to snapshots.
 }
 
 // NewAgent creates a new Agent instance.
@@ -48,12 +69,115 @@ func NewAgent(cfg *config.Config) (*Agent, error) {
 		}
 	}
 
+	// Point collectors at the host filesystems before creating them.
+	logger := utils.New().WithContext("agent")
+	roots := ConfigureHostRoots(cfg)
+	if c := collector.DetectContainer(); c.Containerized {
+		if roots.Root == "/" && roots.Proc == "/proc" {
+			logger.Warn(fmt.Sprintf("Agent is running in a %s container without a host root; metrics describe the container, not the host", c.Runtime))
+		} else {
+			logger.Info(fmt.Sprintf("Agent is running in a %s container, reading host filesystems from %s", c.Runtime, roots.Root))
+		}
+	}
+
 	// Initialize collectors.
+	packages := collector.NewPackageCollector()
 	collectors := []collector.Collector{
 		collector.NewMemoryCollector(),
 		collector.NewSpy(),
 		collector.NewCPUCollector(),
 		collector.NewSystemStatsCollector(),
+		collector.NewCgroupCollector(),
+		packages,
+	}
+	if cfg.Docker != nil {
+		collectors = append(collectors, collector.NewDockerCollector(cfg.Docker.SocketPath))
+	}
+	if cfg.WebStatus != nil {
+		timeout := time.Duration(cfg.WebStatus.TimeoutSeconds) * time.Second
+		if len(cfg.WebStatus.Nginx) > 0 {
+			collectors = append(collectors, collector.NewNginxCollector(cfg.WebStatus.Nginx, timeout))
+		}
+		if len(cfg.WebStatus.Apache) > 0 {
+			collectors = append(collectors, collector.NewApacheCollector(cfg.WebStatus.Apache, timeout))
+		}
+	}
+	if cfg.Redis != nil {
+		collectors = append(collectors, collector.NewRedisCollector(cfg.Redis.Addresses, deref(cfg.Redis.Password), time.Duration(cfg.Redis.TimeoutSeconds)*time.Second))
+	}
+	if cfg.Memcached != nil {
+		collectors = append(collectors, collector.NewMemcachedCollector(cfg.Memcached.Addresses, time.Duration(cfg.Memcached.TimeoutSeconds)*time.Second))
+	}
+	var integrity *collector.IntegrityMonitor
+	if cfg.Integrity != nil {
+		var err error
+		if integrity, err = NewIntegrityMonitor(cfg); err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, integrity)
+	}
+	if len(cfg.JsonScrapers) > 0 {
+		c, err := collector.NewJSONScrapeCollector(jsonScrapeTargets(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON scraper configuration: %v", err)
+		}
+		collectors = append(collectors, c)
+	}
+	if len(cfg.DnsProbes) > 0 {
+		probes := make([]collector.DNSProbeSpec, 0, len(cfg.DnsProbes))
+		for _, p := range cfg.DnsProbes {
+			probes = append(probes, collector.DNSProbeSpec{
+				Name:      p.Name,
+				Type:      p.Type,
+				Resolvers: p.Resolvers,
+				Expected:  p.Expected,
+				Timeout:   time.Duration(p.TimeoutSeconds) * time.Second,
+			})
+		}
+		c, err := collector.NewDNSProbeCollector(probes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS probe configuration: %v", err)
+		}
+		collectors = append(collectors, c)
+	}
+	if cfg.Ntp != nil {
+		collectors = append(collectors, collector.NewNTPCollector(
+			cfg.Ntp.Servers,
+			time.Duration(cfg.Ntp.TimeoutSeconds)*time.Second,
+			time.Duration(cfg.Ntp.MaxOffsetMilliseconds)*time.Millisecond,
+			time.Duration(cfg.Ntp.PollIntervalSeconds)*time.Second,
+		))
+	}
+	if cfg.Certificates != nil {
+		var roots *x509.CertPool
+		if cfg.Certificates.CaFile != nil && *cfg.Certificates.CaFile != "" {
+			pool, err := collector.LoadCertPool(*cfg.Certificates.CaFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load certificate roots: %v", err)
+			}
+			roots = pool
+		}
+		timeout := time.Duration(cfg.Certificates.TimeoutSeconds) * time.Second
+		collectors = append(collectors, collector.NewCertificateCollector(cfg.Certificates.Files, cfg.Certificates.Endpoints, roots, timeout))
+	}
+
+	var statsd *collector.StatsDServer
+	if cfg.Statsd != nil {
+		statsd = collector.NewStatsDServer(cfg.Statsd.Address, deref(cfg.Statsd.SocketPath),
+			time.Duration(cfg.Statsd.FlushIntervalSeconds)*time.Second, cfg.Statsd.MaxSeries, cfg.Statsd.GaugeExpiryFlushes)
+	}
+
+	store, err := newStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot storage: %v", err)
+	}
+	if d, ok := store.(interface{ Recovered() int }); ok && d.Recovered() > 0 {
+		logger.Info(fmt.Sprintf("Recovered %d snapshots from the storage write-ahead log", d.Recovered()))
+	}
+
+	history, err := newJobHistory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job history: %v", err)
 	}
 
 	// Create an HTTP mux that will serve the /metrics endpoint.
@@ -61,10 +185,34 @@ func NewAgent(cfg *config.Config) (*Agent, error) {
 	a := &Agent{
 		cfg:        cfg,
 		collectors: collectors,
-		storage:    storage.NewInMemoryStorage(),
-		logger:     utils.New().WithContext("agent"),
+		storage:    store,
+		logger:     logger,
+		packages:   packages,
+		integrity:  integrity,
+		inventory:  inventory.New(),
+		jobs:       history,
+		statsd:     statsd,
+		ingested:   ingest.NewBuffer(ingest.DefaultCapacity),
 	}
 	mux.HandleFunc("/metrics", a.handleMetrics)
+	mux.HandleFunc("/packages", a.handlePackages)
+	mux.HandleFunc("/inventory", a.handleInventory)
+	mux.HandleFunc("/jobs", a.handleJobs)
+	mux.HandleFunc("/jobs/runs", a.handleJobRuns)
+	mux.HandleFunc("/write", a.handleWrite)
+	mux.HandleFunc("/storage", a.handleStorage)
+	mux.HandleFunc("/query", a.handleQuery)
+	mux.HandleFunc("/export", a.handleExport)
+	mux.HandleFunc("/import", a.handleImport)
+
+	if cfg.DiskScan != nil {
+		a.dirScanner = collector.NewDirSizeScanner(cfg.DiskScan.Paths, cfg.DiskScan.TopN, cfg.DiskScan.MaxDepth, cfg.DiskScan.MaxEntriesPerSecond)
+		mux.HandleFunc("/diskscan", a.handleDiskScan)
+	}
+
+	if integrity != nil {
+		mux.HandleFunc("/integrity/baseline", a.handleIntegrityBaseline)
+	}
 
 	srv := server.NewHTTPServer(cfg.ServerAddress, mux)
 	a.httpServer = srv
@@ -73,6 +221,24 @@ func NewAgent(cfg *config.Config) (*Agent, error) {
 
 // Start runs the agent, including periodic metric collection and the HTTP server.
 func (a *Agent) Start() error {
+	// Gather host facts once at startup; they are refreshed on demand.
+	facts := a.inventory.Refresh()
+	for _, e := range facts.Errors {
+		a.logger.Warn("Inventory: " + e)
+	}
+	if a.cfg.Cloud != nil {
+		go a.detectCloud()
+	}
+	if a.statsd != nil {
+		go func() {
+			if err := a.statsd.Run(context.Background()); err != nil {
+				a.logger.Error("Error starting StatsD receiver: " + err.Error())
+			}
+		}()
+	}
+	a.importSpooledRuns()
+	go a.watchJobs(time.Minute)
+
 	// Launch a goroutine that collects and stores snapshots every 30 seconds.
 	go func() {
 		for {
@@ -80,11 +246,21 @@ func (a *Agent) Start() error {
 			if err != nil {
 				a.logger.Error(fmt.Sprintf("Error collecting metrics: %v", err))
 			} else {
+				if custom := a.customMetrics(); custom != nil {
+					metrics["custom"] = custom
+				}
+				if flushes := a.statsdMetrics(); flushes != nil {
+					metrics["statsd"] = flushes
+				}
 				snapshot := storage.Snapshot{
-					Timestamp: time.Now(),
-					Metrics:   metrics,
+					Labels:  a.labels(),
+					Metrics: metrics,
 				}
-				if err := a.storage.Save(snapshot); err != nil {
+				a.saveMu.Lock()
+				snapshot.Timestamp = time.Now()
+				err := a.storage.Save(snapshot)
+				a.saveMu.Unlock()
+				if err != nil {
 					a.logger.Error(fmt.Sprintf("Error saving snapshot: %v", err))
 				} else {
 					a.logger.Info(fmt.Sprintf("Saved snapshot at %v", snapshot.Timestamp))
@@ -93,6 +269,9 @@ func (a *Agent) Start() error {
 			time.Sleep(30 * time.Second)
 		}
 	}()
+	if a.dirScanner != nil {
+		go a.dirScanner.Run(context.Background(), diskScanInterval(a.cfg))
+	}
 	return a.httpServer.Start()
 }
 
@@ -135,6 +314,78 @@ func aggregateMetrics(collectors []collector.Collector) (map[string]interface{},
 				return nil, err
 			}
 			aggregated["system"] = data
+		case *collector.CgroupCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["cgroups"] = data
+		case *collector.DockerCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["docker"] = data
+		case *collector.NginxCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["nginx"] = data
+		case *collector.ApacheCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["apache"] = data
+		case *collector.RedisCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["redis"] = data
+		case *collector.MemcachedCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["memcached"] = data
+		case *collector.IntegrityMonitor:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["integrity"] = data
+		case *collector.PackageCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["packages"] = data
+		case *collector.CertificateCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["certificates"] = data
+		case *collector.DNSProbeCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["dns"] = data
+		case *collector.NTPCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["ntp"] = data
+		case *collector.JSONScrapeCollector:
+			data, err := v.Collect()
+			if err != nil {
+				return nil, err
+			}
+			aggregated["scrapers"] = data
 		default:
 			aggregated["unknown"] = "collector type not recognized"
 		}
@@ -143,37 +394,53 @@ func aggregateMetrics(collectors []collector.Collector) (map[string]interface{},
 }
 
 // handleMetrics serves HTTP requests to /metrics.
-// It supports query parameters:
-//   - limit: number of latest snapshots to return.
-//   - from and to: Unix timestamps to define a time window.
+// Without parameters it returns the latest snapshot. It supports query parameters:
+//   - limit: number of latest snapshots to return, or the page size of a
+//     paged query. Limited to maxPageSize.
+//   - from and to: Unix timestamps bounding a time range query, exclusive.
+//     Either may be omitted.
+//   - order: "asc" (the default) or "desc" for a time range query.
+//   - cursor: the page token of a paged query's next link.
+//   - resolution: "raw" or the resolution of a rollup tier such as "1m".
+//     By default the tier is chosen from the window.
+//
+// Any of from, to or order makes a time range query, which is streamed as
+// an array of every snapshot in the range. With a limit or cursor as well
+// it is a paged query, streamed as {"snapshots": [...], "links": {"self":
+// ..., "next": ...}}. The next link is omitted on the last page.
 func (a *Agent) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	// If both "from" and "to" are provided, use a time range query.
-	if fromStr, toStr := query.Get("from"), query.Get("to"); fromStr != "" && toStr != "" {
-		fromUnix, err1 := parseUnix(fromStr)
-		toUnix, err2 := parseUnix(toStr)
-		if err1 != nil || err2 != nil {
-			http.Error(w, "Invalid from/to parameters", http.StatusBadRequest)
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconvAtoi(limitStr); err != nil || limit < 1 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
 			return
 		}
-		from := time.Unix(fromUnix, 0)
-		to := time.Unix(toUnix, 0)
-		snaps, err := a.GetSnapshotsByTime(from, to)
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+
+	if query.Has("from") || query.Has("to") || query.Has("order") || query.Has("cursor") {
+		opts, err := a.cursorOptions(query)
 		if err != nil {
-			http.Error(w, "Error querying snapshots", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		json.NewEncoder(w).Encode(snaps)
+		if !query.Has("limit") && !query.Has("cursor") {
+			a.streamRange(w, opts)
+			return
+		}
+		if limit == 0 {
+			limit = defaultPageSize
+		}
+		a.streamSnapshots(w, r, opts, limit)
 		return
 	}
 
 	// If "limit" is provided, return the latest N snapshots.
-	if limitStr := query.Get("limit"); limitStr != "" {
-		limit, err := strconvAtoi(limitStr)
-		if err != nil {
-			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
-			return
-		}
+	if limit > 0 {
 		snaps, err := a.GetSnapshotsByLimit(limit)
 		if err != nil {
 			http.Error(w, "Error retrieving snapshots", http.StatusInternalServerError)
@@ -192,33 +459,106 @@ func (a *Agent) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(snap)
 }
 
+// handlePackages serves HTTP requests to /packages.
+// It supports query parameters:
+//   - name: only return packages with this exact name.
+//   - events: when "true", include recorded install/remove/upgrade events.
+func (a *Agent) handlePackages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pkgs, scannedAt := a.packages.Inventory(query.Get("name"))
+	resp := map[string]interface{}{
+		"scannedAt": scannedAt,
+		"packages":  pkgs,
+	}
+	if query.Get("events") == "true" {
+		resp["events"] = a.packages.Events()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleInventory serves HTTP requests to /inventory.
+// It supports query parameters:
+//   - refresh: when "true", gather the host facts again before responding.
+func (a *Agent) handleInventory(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("refresh") == "true" {
+		json.NewEncoder(w).Encode(a.inventory.Refresh())
+		return
+	}
+	json.NewEncoder(w).Encode(a.inventory.Get())
+}
+
 // GetSnapshotsByTime returns snapshots collected between the given times.
 func (a *Agent) GetSnapshotsByTime(from, to time.Time) ([]storage.Snapshot, error) {
 	return a.storage.Query(from, to)
 }
 
-// GetSnapshotsByLimit returns the latest 'limit' snapshots.
+// GetSnapshotsByLimit returns the latest 'limit' snapshots, oldest first.
 func (a *Agent) GetSnapshotsByLimit(limit int) ([]storage.Snapshot, error) {
-	all, err := a.storage.GetAll()
+	c, err := a.storage.Cursor(storage.CursorOptions{Reverse: true})
 	if err != nil {
 		return nil, err
 	}
-	if limit < len(all) {
-		return all[len(all)-limit:], nil
+	defer c.Close()
+	var snaps []storage.Snapshot
+	for len(snaps) < limit && c.Next() {
+		snaps = append(snaps, c.Snapshot())
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(snaps)-1; i < j; i, j = i+1, j-1 {
+		snaps[i], snaps[j] = snaps[j], snaps[i]
 	}
-	return all, nil
+	return snaps, nil
 }
 
 // GetLatestSnapshot returns the most recent snapshot.
 func (a *Agent) GetLatestSnapshot() (storage.Snapshot, error) {
-	all, err := a.storage.GetAll()
+	snaps, err := a.GetSnapshotsByLimit(1)
 	if err != nil {
 		return storage.Snapshot{}, err
 	}
-	if len(all) == 0 {
+	if len(snaps) == 0 {
 		return storage.Snapshot{}, fmt.Errorf("no snapshots available")
 	}
-	return all[len(all)-1], nil
+	return snaps[0], nil
+}
+
+// jsonScrapeTargets converts the configured JSON scrapers into collector targets.
+func jsonScrapeTargets(cfg *config.Config) []collector.JSONScrapeTarget {
+	targets := make([]collector.JSONScrapeTarget, 0, len(cfg.JsonScrapers))
+	for _, s := range cfg.JsonScrapers {
+		t := collector.JSONScrapeTarget{
+			Name:    s.Name,
+			URL:     s.Url,
+			Labels:  s.Labels,
+			Headers: s.Headers,
+			Timeout: time.Duration(s.TimeoutSeconds) * time.Second,
+		}
+		for _, m := range s.Metrics {
+			t.Metrics = append(t.Metrics, collector.JSONMetricSpec{
+				Name:           m.Name,
+				Selector:       m.Path,
+				Labels:         m.Labels,
+				WildcardLabels: m.WildcardLabels,
+			})
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// integrityBaselinePath returns the configured baseline path or the default
+// location in the Sailfin data directory.
+func integrityBaselinePath(cfg *config.Config) (string, error) {
+	if cfg.Integrity.BaselinePath != nil && *cfg.Integrity.BaselinePath != "" {
+		return *cfg.Integrity.BaselinePath, nil
+	}
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "integrity-baseline.json"), nil
 }
 
 // parseUnix converts a string to an int64 Unix timestamp.
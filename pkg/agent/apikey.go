@@ -2,7 +2,12 @@ package agent
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/SailfinIO/agent/pkg/jobs"
 )
 
 // generateAPIKey generates a new 32-byte secure random API key encoded as a hex string.
@@ -14,3 +19,19 @@ func generateAPIKey() (string, error) {
 	}
 	return hex.EncodeToString(key), nil
 }
+
+// authorized reports whether the request carries the agent's API key, either
+// in the X-API-Key header or as a bearer token. The "Token" scheme used by
+// InfluxDB clients is accepted too.
+func (a *Agent) authorized(r *http.Request) bool {
+	key := r.Header.Get(jobs.APIKeyHeader)
+	if key == "" {
+		auth := r.Header.Get("Authorization")
+		if k, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			key = k
+		} else {
+			key, _ = strings.CutPrefix(auth, "Token ")
+		}
+	}
+	return key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.cfg.ApiKey)) == 1
+}
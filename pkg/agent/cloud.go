@@ -0,0 +1,94 @@
+// pkg/agent/cloud.go
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SailfinIO/agent/gen/agentconfig"
+	"github.com/SailfinIO/agent/pkg/cloud"
+	"github.com/SailfinIO/agent/pkg/utils"
+)
+
+// cloudAttempts is how many times detection is tried. Metadata services
+// can be unreachable for a while after boot, before networking settles.
+const cloudAttempts = 4
+
+// cloudBackoff is the delay before the first retry; it doubles after each.
+const cloudBackoff = 2 * time.Second
+
+// detectCloud queries the configured instance metadata services and, on
+// success, labels subsequent snapshots with the instance metadata.
+func (a *Agent) detectCloud() {
+	md, err := fetchCloudMetadata(context.Background(), a.cfg.Cloud, cloudBackoff, a.logger)
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("Cloud metadata unavailable: %v", err))
+		return
+	}
+	a.logger.Info(fmt.Sprintf("Detected %s instance %s in %s", md.Provider, md.InstanceID, md.Zone))
+	a.labelsMu.Lock()
+	a.hostLabels = md.Labels()
+	a.labelsMu.Unlock()
+}
+
+// fetchCloudMetadata tries the configured providers up to cloudAttempts
+// times, waiting backoff before the first retry and twice as long before
+// each further one.
+func fetchCloudMetadata(ctx context.Context, cfg *agentconfig.CloudConfig, backoff time.Duration, logger utils.Logger) (*cloud.Metadata, error) {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	// Metadata services are link-local; never send their requests, or the
+	// session tokens they return, through an HTTP proxy.
+	client := &http.Client{Timeout: timeout, Transport: &http.Transport{Proxy: nil}}
+	baseURLs := map[string]string{
+		"aws":   cfg.AwsBaseUrl,
+		"gcp":   cfg.GcpBaseUrl,
+		"azure": cfg.AzureBaseUrl,
+	}
+
+	var providers []cloud.Provider
+	for _, name := range cfg.Providers {
+		p, err := cloud.NewProvider(name, baseURLs[name], client)
+		if err != nil {
+			logger.Warn(err.Error())
+			continue
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		return nil, cloud.ErrNotDetected
+	}
+
+	for attempt := 1; ; attempt++ {
+		md, err := cloud.Detect(ctx, providers, timeout)
+		if err == nil || attempt == cloudAttempts {
+			return md, err
+		}
+		logger.Debug(fmt.Sprintf("Cloud metadata attempt %d failed, retrying in %s: %v", attempt, backoff, err))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// labels returns a copy of the host labels attached to snapshots.
+func (a *Agent) labels() map[string]string {
+	a.labelsMu.RLock()
+	defer a.labelsMu.RUnlock()
+	if len(a.hostLabels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(a.hostLabels))
+	for k, v := range a.hostLabels {
+		out[k] = v
+	}
+	return out
+}
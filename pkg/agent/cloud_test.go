@@ -0,0 +1,85 @@
+// pkg/agent/cloud_test.go
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SailfinIO/agent/gen/agentconfig"
+	"github.com/SailfinIO/agent/pkg/cloud"
+	"github.com/SailfinIO/agent/pkg/utils"
+)
+
+// fakeIMDS serves EC2 instance metadata, failing the first failures
+// token requests as a metadata service still starting up would.
+func fakeIMDS(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var tokens atomic.Int32
+	fields := map[string]string{
+		"/latest/meta-data/instance-id":                 "i-0123456789",
+		"/latest/meta-data/instance-type":               "m5.large",
+		"/latest/meta-data/placement/region":            "eu-west-1",
+		"/latest/meta-data/placement/availability-zone": "eu-west-1a",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			if tokens.Add(1) <= failures {
+				http.Error(w, "starting", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("token"))
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+			http.Error(w, "no token", http.StatusUnauthorized)
+			return
+		}
+		v, ok := fields[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(v))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &tokens
+}
+
+func TestFetchCloudMetadataRetries(t *testing.T) {
+	srv, tokens := fakeIMDS(t, 2)
+	cfg := &agentconfig.CloudConfig{Providers: []string{"aws"}, AwsBaseUrl: srv.URL, TimeoutSeconds: 1}
+	md, err := fetchCloudMetadata(context.Background(), cfg, time.Millisecond, utils.New())
+	if err != nil {
+		t.Fatalf("fetchCloudMetadata: %v", err)
+	}
+	if md.InstanceID != "i-0123456789" || md.Zone != "eu-west-1a" {
+		t.Errorf("metadata = %+v", md)
+	}
+	if n := tokens.Load(); n != 3 {
+		t.Errorf("%d token requests, want 3", n)
+	}
+}
+
+func TestFetchCloudMetadataUnavailable(t *testing.T) {
+	srv, tokens := fakeIMDS(t, cloudAttempts)
+	cfg := &agentconfig.CloudConfig{Providers: []string{"aws", "unknown"}, AwsBaseUrl: srv.URL, TimeoutSeconds: 1}
+	_, err := fetchCloudMetadata(context.Background(), cfg, time.Millisecond, utils.New())
+	if !errors.Is(err, cloud.ErrNotDetected) {
+		t.Errorf("error = %v, want %v", err, cloud.ErrNotDetected)
+	}
+	if n := tokens.Load(); n != cloudAttempts {
+		t.Errorf("%d token requests, want %d", n, cloudAttempts)
+	}
+
+	// Cancelling stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetchCloudMetadata(ctx, cfg, time.Hour, utils.New()); err == nil {
+		t.Error("fetchCloudMetadata succeeded after cancellation")
+	}
+}
@@ -0,0 +1,60 @@
+// pkg/agent/diskscan.go
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/collector"
+	"github.com/SailfinIO/agent/pkg/config"
+)
+
+// minDiskScanGap is the minimum time between on-demand directory scans.
+const minDiskScanGap = time.Minute
+
+// diskScanInterval returns the configured scan interval, defaulting to one hour.
+func diskScanInterval(cfg *config.Config) time.Duration {
+	if cfg.DiskScan == nil || cfg.DiskScan.IntervalSeconds <= 0 {
+		return time.Hour
+	}
+	return time.Duration(cfg.DiskScan.IntervalSeconds) * time.Second
+}
+
+// handleDiskScan serves HTTP requests to /diskscan.
+//   - GET returns the results of the latest directory scan.
+//   - POST starts a new scan in the background, at most once per minute.
+func (a *Agent) handleDiskScan(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		results, last := a.dirScanner.Latest()
+		resp := map[string]interface{}{
+			"running": a.dirScanner.Running(),
+			"results": results,
+		}
+		if !last.IsZero() {
+			resp["lastScan"] = last
+		}
+		json.NewEncoder(w).Encode(resp)
+	case http.MethodPost:
+		if a.dirScanner.Running() {
+			http.Error(w, collector.ErrScanInProgress.Error(), http.StatusConflict)
+			return
+		}
+		if _, last := a.dirScanner.Latest(); time.Since(last) < minDiskScanGap {
+			http.Error(w, "Directory scan was run less than a minute ago", http.StatusTooManyRequests)
+			return
+		}
+		go func() {
+			if _, err := a.dirScanner.Scan(context.Background()); err != nil && !errors.Is(err, collector.ErrScanInProgress) {
+				a.logger.Error("Error scanning directories: " + err.Error())
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,138 @@
+// pkg/agent/export.go
+
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/export"
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// maxImportBytes caps the size of an import request body.
+const maxImportBytes = 256 << 20
+
+// handleExport serves HTTP requests to /export with a time range of
+// snapshots as a file download.
+// It supports query parameters:
+//   - format: "jsonl" (default), "csv" or "parquet".
+//   - from and to: Unix timestamps of the range, exclusive. Default to all
+//     retained snapshots up to now.
+//   - order: "asc" (the default) or "desc".
+//   - resolution: "raw" (the default) or the resolution of a rollup tier.
+//
+// CSV and Parquet have a column per label and metric path; see export.Flatten.
+func (a *Agent) handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "jsonl"
+	}
+	if !validExportFormat(format) {
+		http.Error(w, "Invalid format parameter", http.StatusBadRequest)
+		return
+	}
+	query.Del("cursor")
+	opts, err := a.cursorOptions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts = exportCursorOptions(opts, query.Get("resolution") != "")
+	// Fail before the response starts if the options are rejected.
+	c, err := a.storage.Cursor(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.Close()
+
+	cw := &countingWriter{w: w}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snapshots.%s"`, format))
+	_, err = export.Write(cw, format, func() (storage.Cursor, error) {
+		return a.storage.Cursor(opts)
+	})
+	if err != nil {
+		if cw.n == 0 {
+			http.Error(w, "Error exporting snapshots", http.StatusInternalServerError)
+			return
+		}
+		a.logger.Error("Error exporting snapshots: " + err.Error())
+	}
+}
+
+// exportCursorOptions completes the cursor options of an export. The range
+// ends now unless it has an end, so that formats reading it twice see the
+// same snapshots, and raw snapshots are exported unless a resolution was
+// requested.
+func exportCursorOptions(opts storage.CursorOptions, explicitResolution bool) storage.CursorOptions {
+	if opts.To.IsZero() {
+		opts.To = time.Now()
+	}
+	if !explicitResolution {
+		opts.Resolution = 0
+	}
+	return opts
+}
+
+// validExportFormat reports whether format is one of export.Formats.
+func validExportFormat(format string) bool {
+	for _, f := range export.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// handleImport serves HTTP requests to /import.
+//   - POST stores the snapshots of a JSON Lines body, as written by
+//     /export?format=jsonl, and requires the API key.
+//
+// Snapshots are saved in the order of the body, which must not go back in
+// time; see export.Import. On an invalid line the snapshots before it are
+// kept and the response reports how many were imported.
+func (a *Agent) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	a.saveMu.Lock()
+	n, err := export.Import(a.storage, body)
+	a.saveMu.Unlock()
+	resp := map[string]interface{}{"imported": n}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		resp["error"] = err.Error()
+		w.WriteHeader(status)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,35 @@
+// pkg/agent/hostroot.go
+
+package agent
+
+import (
+	"github.com/SailfinIO/agent/pkg/collector"
+	"github.com/SailfinIO/agent/pkg/config"
+)
+
+// ConfigureHostRoots points all collectors at the host filesystems described
+// by the configuration and the HOST_* environment variables. It must be
+// called before collectors are created.
+func ConfigureHostRoots(cfg *config.Config) collector.HostRoots {
+	var configured collector.HostRoots
+	if cfg.Host != nil {
+		configured = collector.HostRoots{
+			Root: deref(cfg.Host.Root),
+			Proc: deref(cfg.Host.Proc),
+			Sys:  deref(cfg.Host.Sys),
+			Etc:  deref(cfg.Host.Etc),
+			Run:  deref(cfg.Host.Run),
+		}
+	}
+	roots := collector.ResolveHostRoots(configured)
+	collector.SetHostRoots(roots)
+	return roots
+}
+
+// deref returns the value of an optional string, or "" when unset.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
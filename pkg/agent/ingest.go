@@ -0,0 +1,110 @@
+// pkg/agent/ingest.go
+
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/ingest"
+)
+
+// maxWriteBytes caps the size of a write request body.
+const maxWriteBytes = 5 << 20
+
+// handleWrite serves HTTP requests to /write.
+//   - POST accepts a batch of custom metrics and requires the API key. The
+//     body is InfluxDB line protocol, or JSON when the Content-Type is
+//     application/json or the format parameter is "json".
+//
+// It supports query parameters:
+//   - format: "influx" (default) or "json".
+//   - precision: line protocol timestamp unit, "ns" (default), "us", "ms" or "s".
+//
+// Accepted points are merged into the next stored snapshot under "custom".
+// A batch with any invalid point is rejected as a whole.
+func (a *Agent) handleWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "influx"
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" {
+			format = "json"
+		}
+	}
+	body := http.MaxBytesReader(w, r.Body, maxWriteBytes)
+
+	var points []ingest.Point
+	var err error
+	switch format {
+	case "influx":
+		points, err = ingest.ParseLineProtocol(body, query.Get("precision"), time.Now())
+	case "json":
+		points, err = ingest.ParseJSON(body, time.Now())
+	default:
+		http.Error(w, "Invalid format parameter", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp := map[string]interface{}{"error": err.Error()}
+		var batchErr *ingest.BatchError
+		if errors.As(err, &batchErr) {
+			resp["errors"] = batchErr.Errors
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	a.ingested.Add(points)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// statsdMetrics drains the completed StatsD flushes for inclusion in a
+// snapshot. It returns nil when StatsD is disabled or nothing was flushed.
+func (a *Agent) statsdMetrics() map[string]interface{} {
+	if a.statsd == nil {
+		return nil
+	}
+	flushes, dropped := a.statsd.Drain()
+	if len(flushes) == 0 && dropped == 0 {
+		return nil
+	}
+	out := map[string]interface{}{"flushes": flushes}
+	if dropped > 0 {
+		out["dropped"] = dropped
+	}
+	return out
+}
+
+// customMetrics drains the ingested points for inclusion in a snapshot. It
+// returns nil when nothing was written since the last snapshot.
+func (a *Agent) customMetrics() map[string]interface{} {
+	points, dropped := a.ingested.Drain()
+	if len(points) == 0 && dropped == 0 {
+		return nil
+	}
+	out := map[string]interface{}{"points": points}
+	if dropped > 0 {
+		out["dropped"] = dropped
+	}
+	return out
+}
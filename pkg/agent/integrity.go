@@ -0,0 +1,48 @@
+// pkg/agent/integrity.go
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/SailfinIO/agent/pkg/collector"
+	"github.com/SailfinIO/agent/pkg/config"
+)
+
+// NewIntegrityMonitor returns the file integrity monitor of the
+// configuration, which must have an integrity section.
+func NewIntegrityMonitor(cfg *config.Config) (*collector.IntegrityMonitor, error) {
+	if cfg.Integrity == nil {
+		return nil, fmt.Errorf("file integrity monitoring is not configured")
+	}
+	baselinePath, err := integrityBaselinePath(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve integrity baseline path: %v", err)
+	}
+	return collector.NewIntegrityMonitor(cfg.Integrity.Paths, baselinePath, int64(cfg.Integrity.MaxHashBytes)), nil
+}
+
+// handleIntegrityBaseline serves HTTP requests to /integrity/baseline.
+//   - POST replaces the integrity baseline with the current state of the
+//     monitored files, accepting the changes reported so far, and requires
+//     the API key.
+func (a *Agent) handleIntegrityBaseline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	files, errs, err := a.integrity.Rebaseline()
+	if err != nil {
+		a.logger.Error("Error saving integrity baseline: " + err.Error())
+		http.Error(w, "Error saving integrity baseline", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"files": files, "errors": errs})
+}
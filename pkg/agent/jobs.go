@@ -0,0 +1,127 @@
+// pkg/agent/jobs.go
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/config"
+	"github.com/SailfinIO/agent/pkg/jobs"
+)
+
+// maxJobRunBytes caps the size of a reported run.
+const maxJobRunBytes = 1 << 20
+
+// JobSpoolDir returns where `sailfin run` keeps runs the agent did not accept.
+func JobSpoolDir() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "spool", "jobs"), nil
+}
+
+// newJobHistory loads the persisted job history and applies the configured schedules.
+func newJobHistory(cfg *config.Config) (*jobs.History, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	history, err := jobs.NewHistory(filepath.Join(dir, "jobs-history.json"), jobs.DefaultHistorySize)
+	if err != nil {
+		return nil, err
+	}
+	expectations := make([]jobs.Expectation, 0, len(cfg.Jobs))
+	for _, j := range cfg.Jobs {
+		expectations = append(expectations, jobs.Expectation{
+			Job:      j.Name,
+			Schedule: j.Schedule,
+			Grace:    time.Duration(j.GraceSeconds) * time.Second,
+		})
+	}
+	if err := history.SetExpectations(expectations); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// importSpooledRuns records runs spooled while the agent was down.
+func (a *Agent) importSpooledRuns() {
+	dir, err := JobSpoolDir()
+	if err != nil {
+		return
+	}
+	n, err := jobs.NewSpool(dir).Drain(a.jobs.Record)
+	if err != nil {
+		a.logger.Error("Error importing spooled job runs: " + err.Error())
+	}
+	if n > 0 {
+		a.logger.Info(fmt.Sprintf("Imported %d spooled job runs", n))
+	}
+}
+
+// watchJobs logs a warning once each time a scheduled job misses a run.
+func (a *Agent) watchJobs(interval time.Duration) {
+	reported := make(map[string]time.Time)
+	for {
+		for _, st := range a.jobs.Status(time.Now()) {
+			if !st.Missed || st.NextExpectedAt == nil {
+				continue
+			}
+			if reported[st.Job].Equal(*st.NextExpectedAt) {
+				continue
+			}
+			reported[st.Job] = *st.NextExpectedAt
+			a.logger.Warn(fmt.Sprintf("Job %s missed its run expected at %v (schedule %q)", st.Job, st.NextExpectedAt.Format(time.RFC3339), st.Schedule))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// handleJobs serves HTTP requests to /jobs.
+// It supports query parameters:
+//   - name: return the run history of this job instead of the status of all jobs.
+func (a *Agent) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if name := r.URL.Query().Get("name"); name != "" {
+		json.NewEncoder(w).Encode(a.jobs.Runs(name))
+		return
+	}
+	json.NewEncoder(w).Encode(a.jobs.Status(time.Now()))
+}
+
+// handleJobRuns serves HTTP requests to /jobs/runs.
+//   - POST records a run reported by `sailfin run`. It requires the API key.
+func (a *Agent) handleJobRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var run jobs.Run
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRunBytes)).Decode(&run); err != nil {
+		http.Error(w, "Invalid job run: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if run.Job == "" {
+		http.Error(w, "Invalid job run: missing job name", http.StatusBadRequest)
+		return
+	}
+	if err := a.jobs.Record(run); err != nil {
+		a.logger.Error("Error saving job history: " + err.Error())
+	}
+	if !run.Success {
+		a.logger.Warn(fmt.Sprintf("Job %s failed with exit code %d", run.Job, run.ExitCode))
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
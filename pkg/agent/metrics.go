@@ -0,0 +1,175 @@
+// pkg/agent/metrics.go
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+const (
+	// defaultPageSize is the page size of paged /metrics queries without a limit.
+	defaultPageSize = 100
+	// maxPageSize bounds the snapshots returned by one /metrics request.
+	maxPageSize = 1000
+	// flushEvery is how many snapshots are written between flushes while
+	// streaming a page.
+	flushEvery = 20
+)
+
+// cursorOptions converts the parameters of a time range or paged /metrics
+// query.
+func (a *Agent) cursorOptions(query url.Values) (storage.CursorOptions, error) {
+	var opts storage.CursorOptions
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &opts.From}, {"to", &opts.To}} {
+		if s := query.Get(p.name); s != "" {
+			unix, err := parseUnix(s)
+			if err != nil {
+				return opts, fmt.Errorf("Invalid %s parameter", p.name)
+			}
+			*p.dst = time.Unix(unix, 0)
+		}
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Reverse = true
+	default:
+		return opts, fmt.Errorf("Invalid order parameter")
+	}
+	opts.Token = query.Get("cursor")
+
+	if s := query.Get("resolution"); s != "" {
+		resolution, err := a.parseResolution(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Resolution = resolution
+	} else if d, ok := a.storage.(storage.Downsampler); ok && !opts.From.IsZero() {
+		opts.Resolution = d.ResolutionFor(opts.From)
+	}
+	return opts, nil
+}
+
+// streamRange writes every snapshot of a storage cursor as a JSON array.
+// An error after the first snapshot was written is logged and leaves the
+// array unterminated, so clients do not mistake it for the whole range.
+func (a *Agent) streamRange(w http.ResponseWriter, opts storage.CursorOptions) {
+	c, err := a.storage.Cursor(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer c.Close()
+
+	flusher, _ := w.(http.Flusher)
+	written := 0
+	var streamErr error
+	for c.Next() {
+		data, err := json.Marshal(c.Snapshot())
+		if err != nil {
+			streamErr = err
+			break
+		}
+		sep := ","
+		if written == 0 {
+			sep = "["
+		}
+		io.WriteString(w, sep)
+		w.Write(data)
+		written++
+		if flusher != nil && written%flushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	if streamErr == nil {
+		streamErr = c.Err()
+	}
+	if streamErr != nil {
+		a.logger.Error("Error querying snapshots: " + streamErr.Error())
+		if written == 0 {
+			http.Error(w, "Error querying snapshots", http.StatusInternalServerError)
+		}
+		return
+	}
+	if written == 0 {
+		io.WriteString(w, "[")
+	}
+	io.WriteString(w, "]\n")
+}
+
+// streamSnapshots writes one page of snapshots as they are read from a
+// storage cursor, followed by the page's links. The next link resumes after
+// the last snapshot written. An error after the first snapshot was written
+// is reported in the body's "error" field.
+func (a *Agent) streamSnapshots(w http.ResponseWriter, r *http.Request, opts storage.CursorOptions, limit int) {
+	c, err := a.storage.Cursor(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer c.Close()
+
+	flusher, _ := w.(http.Flusher)
+	written, token, more := 0, "", false
+	var streamErr error
+	for c.Next() {
+		if written == limit {
+			more = true
+			break
+		}
+		data, err := json.Marshal(c.Snapshot())
+		if err != nil {
+			streamErr = err
+			break
+		}
+		sep := ","
+		if written == 0 {
+			sep = `{"snapshots":[`
+		}
+		io.WriteString(w, sep)
+		w.Write(data)
+		token = c.Token()
+		written++
+		if flusher != nil && written%flushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	if streamErr == nil {
+		streamErr = c.Err()
+	}
+	if streamErr != nil && written == 0 {
+		http.Error(w, "Error querying snapshots", http.StatusInternalServerError)
+		return
+	}
+	if written == 0 {
+		io.WriteString(w, `{"snapshots":[`)
+	}
+
+	tail := struct {
+		Links map[string]string `json:"links"`
+		Error string            `json:"error,omitempty"`
+	}{Links: map[string]string{"self": r.URL.RequestURI()}}
+	if more {
+		query := r.URL.Query()
+		query.Set("cursor", token)
+		tail.Links["next"] = r.URL.Path + "?" + query.Encode()
+	}
+	if streamErr != nil {
+		tail.Error = "Error querying snapshots: " + streamErr.Error()
+	}
+	data, _ := json.Marshal(tail)
+	// Splice the links into the object opened above.
+	io.WriteString(w, "],")
+	w.Write(data[1:])
+	io.WriteString(w, "\n")
+}
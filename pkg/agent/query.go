@@ -0,0 +1,164 @@
+// pkg/agent/query.go
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/query"
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+const (
+	// defaultQueryRange is the range of a /query request without from.
+	defaultQueryRange = time.Hour
+	// defaultQuerySteps is the number of steps a /query request without a
+	// step is divided into.
+	defaultQuerySteps = 120
+)
+
+// handleQuery serves HTTP requests to /query with selected series
+// aggregated per step.
+// It supports query parameters:
+//   - metric: a selector such as cpu.usage or disks.*.usedPercent{mount="/"}.
+//     May be repeated.
+//   - from and to: Unix timestamps of the range. Default to the last hour.
+//   - step: seconds or a duration such as "5m". Defaults to a 120th of the range.
+//   - agg: comma-separated aggregations; see query.Aggregations. Defaults to avg.
+//   - resolution: "raw" or the resolution of a rollup tier such as "1m".
+//     By default the tier is chosen from the range. Over a rollup tier
+//     each aggregation reads the bucket statistic of
+//     query.RollupStatistic, and percentiles are rejected.
+func (a *Agent) handleQuery(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q, opts, err := a.parseQuery(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	eval, err := query.NewEvaluator(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Over a rollup tier, each statistic the aggregations need is read
+	// in a pass of its own.
+	for _, stat := range q.Statistics() {
+		opts.Statistic = stat
+		status, err := a.evaluate(eval, opts)
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				a.logger.Error("Error querying snapshots: " + err.Error())
+				http.Error(w, "Error querying snapshots", status)
+			} else {
+				http.Error(w, err.Error(), status)
+			}
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(eval.Result())
+}
+
+// evaluate feeds the snapshots of a cursor to eval, returning the HTTP
+// status of an error.
+func (a *Agent) evaluate(eval *query.Evaluator, opts storage.CursorOptions) (int, error) {
+	c, err := a.storage.Cursor(opts)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	defer c.Close()
+	for c.Next() {
+		if err := eval.Add(c.Snapshot(), opts.Statistic); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	if err := c.Err(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 0, nil
+}
+
+// parseQuery converts the parameters of a /query request into a query and
+// the storage cursor that feeds it.
+func (a *Agent) parseQuery(params map[string][]string) (query.Query, storage.CursorOptions, error) {
+	var q query.Query
+	var opts storage.CursorOptions
+	get := func(name string) string {
+		if v := params[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	for _, s := range params["metric"] {
+		sel, err := query.ParseSelector(s)
+		if err != nil {
+			return q, opts, err
+		}
+		q.Selectors = append(q.Selectors, sel)
+	}
+
+	q.To = time.Now()
+	if s := get("to"); s != "" {
+		unix, err := parseUnix(s)
+		if err != nil {
+			return q, opts, fmt.Errorf("Invalid to parameter")
+		}
+		q.To = time.Unix(unix, 0)
+	}
+	q.From = q.To.Add(-defaultQueryRange)
+	if s := get("from"); s != "" {
+		unix, err := parseUnix(s)
+		if err != nil {
+			return q, opts, fmt.Errorf("Invalid from parameter")
+		}
+		q.From = time.Unix(unix, 0)
+	}
+	q.From, q.To = q.From.Truncate(time.Second), q.To.Truncate(time.Second)
+
+	if s := get("step"); s != "" {
+		step, err := parseStep(s)
+		if err != nil {
+			return q, opts, fmt.Errorf("Invalid step parameter")
+		}
+		q.Step = step
+	} else {
+		q.Step = (q.To.Sub(q.From)/defaultQuerySteps + time.Second - 1).Truncate(time.Second)
+		if q.Step < time.Second {
+			q.Step = time.Second
+		}
+	}
+
+	q.Aggregations = []string{"avg"}
+	if s := get("agg"); s != "" {
+		q.Aggregations = strings.Split(s, ",")
+	}
+
+	// The range is exclusive in storage and half-open in queries.
+	opts.From, opts.To = q.From.Add(-time.Nanosecond), q.To
+	if s := get("resolution"); s != "" {
+		resolution, err := a.parseResolution(s)
+		if err != nil {
+			return q, opts, err
+		}
+		opts.Resolution = resolution
+	} else if d, ok := a.storage.(storage.Downsampler); ok {
+		opts.Resolution = d.ResolutionFor(q.From)
+	}
+	q.Rollup = opts.Resolution != 0
+	return q, opts, nil
+}
+
+// parseStep parses a step given in seconds or as a duration.
+func parseStep(s string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
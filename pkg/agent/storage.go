@@ -0,0 +1,130 @@
+// pkg/agent/storage.go
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/config"
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// storageRetention returns the configured retention limits, or the defaults.
+func storageRetention(cfg *config.Config) storage.Retention {
+	if cfg.Storage == nil {
+		return storage.DefaultRetention
+	}
+	return storage.Retention{
+		MaxSnapshots: cfg.Storage.MaxSnapshots,
+		MaxAge:       time.Duration(cfg.Storage.MaxAgeSeconds) * time.Second,
+		MaxBytes:     int64(cfg.Storage.MaxBytes),
+	}
+}
+
+// newStorage creates the configured snapshot storage backend.
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	retention := storageRetention(cfg)
+	if cfg.Storage == nil || cfg.Storage.Backend == "" || cfg.Storage.Backend == "memory" {
+		if cfg.Storage != nil && cfg.Storage.Encryption != nil {
+			return nil, fmt.Errorf("storage encryption requires the disk or tsdb backend")
+		}
+		return storage.NewInMemoryStorageWithRetention(retention), nil
+	}
+	var keyring *storage.Keyring
+	if e := cfg.Storage.Encryption; e != nil {
+		var err error
+		if keyring, err = storage.LoadKeyring(deref(e.KeyFile), deref(e.KeyEnv)); err != nil {
+			return nil, err
+		}
+	}
+	dir := deref(cfg.Storage.Path)
+	if dir == "" {
+		dataDir, err := config.DataDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(dataDir, "snapshots")
+		if cfg.Storage.Backend == "tsdb" {
+			dir = filepath.Join(dataDir, "tsdb")
+		}
+	}
+	switch cfg.Storage.Backend {
+	case "disk":
+	case "tsdb":
+		var rollups []storage.RollupTier
+		for _, r := range cfg.Storage.Rollups {
+			rollups = append(rollups, storage.RollupTier{
+				Resolution: time.Duration(r.ResolutionSeconds) * time.Second,
+				Retention:  time.Duration(r.RetentionSeconds) * time.Second,
+			})
+		}
+		return storage.OpenTSDB(storage.TSDBOptions{
+			Dir:           dir,
+			Retention:     retention,
+			Fsync:         storage.FsyncPolicy(cfg.Storage.Fsync),
+			FsyncInterval: time.Duration(cfg.Storage.FsyncIntervalSeconds) * time.Second,
+			BlockDuration: time.Duration(cfg.Storage.BlockDurationSeconds) * time.Second,
+			Rollups:       rollups,
+			Keyring:       keyring,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+	return storage.OpenDiskStorage(storage.DiskOptions{
+		Dir:                 dir,
+		Retention:           retention,
+		Fsync:               storage.FsyncPolicy(cfg.Storage.Fsync),
+		FsyncInterval:       time.Duration(cfg.Storage.FsyncIntervalSeconds) * time.Second,
+		SegmentMaxSnapshots: cfg.Storage.SegmentMaxSnapshots,
+		SegmentMaxBytes:     int64(cfg.Storage.SegmentMaxBytes),
+		Keyring:             keyring,
+	})
+}
+
+// handleStorage serves HTTP requests to /storage with the storage backend's statistics.
+func (a *Agent) handleStorage(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := a.storage.(storage.StatsReporter)
+	if !ok {
+		http.Error(w, "Storage statistics are not available", http.StatusNotImplemented)
+		return
+	}
+	json.NewEncoder(w).Encode(reporter.Stats())
+}
+
+// parseResolution parses the resolution query parameter: "raw", or a
+// duration naming one of the storage backend's rollup tiers.
+func (a *Agent) parseResolution(s string) (time.Duration, error) {
+	if s == "raw" {
+		return 0, nil
+	}
+	resolution, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resolution %q", s)
+	}
+	var available []string
+	if d, ok := a.storage.(storage.Downsampler); ok {
+		for _, r := range d.Resolutions() {
+			if r == resolution {
+				return resolution, nil
+			}
+			available = append(available, r.String())
+		}
+	}
+	if len(available) == 0 {
+		return 0, fmt.Errorf("the storage backend keeps no rollups")
+	}
+	return 0, fmt.Errorf("no rollup tier with resolution %v; available: raw, %s", resolution, strings.Join(available, ", "))
+}
+
+// OpenStorage opens the configured snapshot storage outside a running
+// agent, for commands such as export and import. The disk and tsdb
+// backends lock their directory, so they cannot be opened while the agent
+// is running.
+func OpenStorage(cfg *config.Config) (storage.Storage, error) {
+	return newStorage(cfg)
+}
@@ -2,11 +2,20 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/SailfinIO/agent/pkg/agent"
+	"github.com/SailfinIO/agent/pkg/collector"
 	"github.com/SailfinIO/agent/pkg/config"
+	"github.com/SailfinIO/agent/pkg/export"
+	"github.com/SailfinIO/agent/pkg/inventory"
+	"github.com/SailfinIO/agent/pkg/storage"
 	"github.com/SailfinIO/agent/pkg/utils"
 	"github.com/spf13/cobra"
 )
@@ -98,6 +107,281 @@ func newAgentCmd(cfg *config.Config) *cobra.Command {
 	metricsCmd.Flags().String("from", "", "Unix timestamp start for snapshot query")
 	metricsCmd.Flags().String("to", "", "Unix timestamp end for snapshot query")
 
-	agentCmd.AddCommand(startCmd, stopCmd, metricsCmd)
+	// "diskscan" command to find the largest directories and files.
+	diskScanCmd := &cobra.Command{
+		Use:   "diskscan [paths...]",
+		Short: "Report the largest directories and files below the given or configured paths",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("agent")
+			top, _ := cmd.Flags().GetInt("top")
+			depth, _ := cmd.Flags().GetInt("depth")
+			rate, _ := cmd.Flags().GetInt("rate")
+
+			paths := args
+			if len(paths) == 0 && cfg.DiskScan != nil {
+				paths = cfg.DiskScan.Paths
+			}
+			if len(paths) == 0 {
+				fmt.Println("No paths given and none configured in diskScan.paths.")
+				os.Exit(1)
+			}
+
+			agent.ConfigureHostRoots(cfg)
+			scanner := collector.NewDirSizeScanner(paths, top, depth, rate)
+			results, err := scanner.Scan(context.Background())
+			if err != nil {
+				logger.Error("Error scanning directories: " + err.Error())
+				os.Exit(1)
+			}
+			for _, res := range results {
+				if res.Error != "" {
+					fmt.Printf("%s: %s\n", res.Root, res.Error)
+					continue
+				}
+				fmt.Printf("%s: %s in %d files (%d errors, %d mounts skipped, %v)\n",
+					res.Root, humanBytes(res.TotalBytes), res.Files, res.Errors, res.SkippedMounts, res.Duration.Round(time.Millisecond))
+				fmt.Println("  Largest directories:")
+				for _, e := range res.TopDirs {
+					fmt.Printf("    %10s  %s\n", humanBytes(e.Size), e.Path)
+				}
+				fmt.Println("  Largest files:")
+				for _, e := range res.TopFiles {
+					fmt.Printf("    %10s  %s\n", humanBytes(e.Size), e.Path)
+				}
+			}
+		},
+	}
+	diskScanCmd.Flags().Int("top", 20, "Number of largest directories and files to report")
+	diskScanCmd.Flags().Int("depth", 3, "Maximum directory depth to report")
+	diskScanCmd.Flags().Int("rate", 0, "Maximum filesystem entries visited per second (0 for unlimited)")
+
+	// "inventory" command to display host facts.
+	inventoryCmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Display host facts such as OS, kernel, CPU, disks and network interfaces",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("agent")
+			agent.ConfigureHostRoots(cfg)
+			facts := inventory.Gather()
+			for _, e := range facts.Errors {
+				logger.Warn(e)
+			}
+			data, err := json.MarshalIndent(facts, "", "  ")
+			if err != nil {
+				logger.Error("Error encoding inventory: " + err.Error())
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		},
+	}
+
+	// "export" command to dump stored snapshots to a file.
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export stored snapshots as JSON Lines, CSV or Parquet",
+		Long: "Export stored snapshots as JSON Lines, CSV or Parquet. The configured disk or tsdb storage is\n" +
+			"read directly; stop the agent first or use the /export endpoint instead. Snapshots of the memory\n" +
+			"backend are only available from the /export endpoint of the running agent.",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("agent")
+			if !persistsSnapshots(cfg) {
+				fmt.Println("The memory storage backend keeps snapshots only in the running agent; use its /export endpoint.")
+				os.Exit(1)
+			}
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			fromStr, _ := cmd.Flags().GetString("from")
+			toStr, _ := cmd.Flags().GetString("to")
+
+			var opts storage.CursorOptions
+			for _, p := range []struct {
+				name, value string
+				dst         *time.Time
+			}{{"from", fromStr, &opts.From}, {"to", toStr, &opts.To}} {
+				if p.value == "" {
+					continue
+				}
+				unix, err := strconv.ParseInt(p.value, 10, 64)
+				if err != nil {
+					fmt.Printf("Invalid --%s timestamp: %s\n", p.name, p.value)
+					os.Exit(1)
+				}
+				*p.dst = time.Unix(unix, 0)
+			}
+			if opts.To.IsZero() {
+				opts.To = time.Now()
+			}
+
+			store, err := agent.OpenStorage(cfg)
+			if err != nil {
+				logger.Error("Error opening snapshot storage: " + err.Error())
+				os.Exit(1)
+			}
+			defer closeStorage(store)
+
+			out := io.Writer(os.Stdout)
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					logger.Error("Error creating output file: " + err.Error())
+					os.Exit(1)
+				}
+				defer f.Close()
+				out = f
+			}
+			n, err := export.Write(out, format, func() (storage.Cursor, error) {
+				return store.Cursor(opts)
+			})
+			if err != nil {
+				logger.Error("Error exporting snapshots: " + err.Error())
+				closeStorage(store)
+				os.Exit(1)
+			}
+			if out != io.Writer(os.Stdout) {
+				fmt.Printf("Exported %d snapshots to %s\n", n, output)
+			}
+		},
+	}
+	exportCmd.Flags().String("format", "jsonl", "Export format: jsonl, csv or parquet")
+	exportCmd.Flags().StringP("output", "o", "", "File to write (default standard output)")
+	exportCmd.Flags().String("from", "", "Unix timestamp start of the exported range, exclusive")
+	exportCmd.Flags().String("to", "", "Unix timestamp end of the exported range, exclusive (default now)")
+
+	// "import" command to load snapshots from a JSON Lines file.
+	importCmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import snapshots from a JSON Lines file into storage",
+		Long: "Import snapshots from a JSON Lines file, as written by \"agent export\", into the configured disk\n" +
+			"or tsdb storage. Reads standard input without a file. Stop the agent first or use the /import\n" +
+			"endpoint instead, which is also the only way to import into the memory backend.",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("agent")
+			if !persistsSnapshots(cfg) {
+				fmt.Println("The memory storage backend keeps snapshots only in the running agent; use its /import endpoint.")
+				os.Exit(1)
+			}
+			in := io.Reader(os.Stdin)
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					logger.Error("Error opening input file: " + err.Error())
+					os.Exit(1)
+				}
+				defer f.Close()
+				in = f
+			}
+
+			store, err := agent.OpenStorage(cfg)
+			if err != nil {
+				logger.Error("Error opening snapshot storage: " + err.Error())
+				os.Exit(1)
+			}
+			n, err := export.Import(store, in)
+			if cerr := closeStorage(store); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				logger.Error(fmt.Sprintf("Error importing snapshots after %d were imported: %v", n, err))
+				os.Exit(1)
+			}
+			fmt.Printf("Imported %d snapshots\n", n)
+		},
+	}
+
+	// "reencrypt" command to rewrite stored data with the active encryption key.
+	reencryptCmd := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "Re-encrypt stored snapshots with the active storage encryption key",
+		Long: "Rewrite every storage file that is unencrypted or encrypted with a previous key using the first key\n" +
+			"of storage.encryption. Run it after adding a new key in front of the old one; the old key can be\n" +
+			"removed once it completes. Stop the agent first.",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("agent")
+			if cfg.Storage == nil || cfg.Storage.Encryption == nil {
+				fmt.Println("Storage encryption is not configured in storage.encryption.")
+				os.Exit(1)
+			}
+			store, err := agent.OpenStorage(cfg)
+			if err != nil {
+				logger.Error("Error opening snapshot storage: " + err.Error())
+				os.Exit(1)
+			}
+			r, ok := store.(storage.Reencrypter)
+			if !ok {
+				fmt.Println("The storage backend does not persist snapshots.")
+				closeStorage(store)
+				os.Exit(1)
+			}
+			n, err := r.Reencrypt()
+			if cerr := closeStorage(store); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				logger.Error(fmt.Sprintf("Error re-encrypting storage after %d files were rewritten: %v", n, err))
+				os.Exit(1)
+			}
+			fmt.Printf("Re-encrypted %d storage files\n", n)
+		},
+	}
+
+	// "rebaseline" command to accept the current state of monitored files.
+	rebaselineCmd := &cobra.Command{
+		Use:   "rebaseline",
+		Short: "Accept the current state of integrity-monitored files as the new baseline",
+		Long: "Scan the paths of integrity.paths and replace the saved baseline, so the changes reported\n" +
+			"so far are no longer reported. A running agent picks up the new baseline on its next scan.",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("agent")
+			if cfg.Integrity == nil {
+				fmt.Println("File integrity monitoring is not configured in integrity.")
+				os.Exit(1)
+			}
+			monitor, err := agent.NewIntegrityMonitor(cfg)
+			if err != nil {
+				logger.Error(err.Error())
+				os.Exit(1)
+			}
+			files, errs, err := monitor.Rebaseline()
+			for _, e := range errs {
+				logger.Warn(e)
+			}
+			if err != nil {
+				logger.Error("Error saving integrity baseline: " + err.Error())
+				os.Exit(1)
+			}
+			fmt.Printf("Saved integrity baseline of %d files\n", files)
+		},
+	}
+
+	agentCmd.AddCommand(startCmd, stopCmd, metricsCmd, diskScanCmd, inventoryCmd, exportCmd, importCmd, reencryptCmd, rebaselineCmd)
 	return agentCmd
 }
+
+// persistsSnapshots reports whether the configured storage backend keeps
+// snapshots on disk, where commands other than the agent can read them.
+func persistsSnapshots(cfg *config.Config) bool {
+	return cfg.Storage != nil && (cfg.Storage.Backend == "disk" || cfg.Storage.Backend == "tsdb")
+}
+
+// closeStorage closes a storage backend that holds files open.
+func closeStorage(store storage.Storage) error {
+	if c, ok := store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// humanBytes formats a byte count using binary units.
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -28,6 +28,7 @@ func NewRootCmd() *cobra.Command {
 	// Add agent and remote commands.
 	rootCmd.AddCommand(newAgentCmd(cfg))
 	rootCmd.AddCommand(newRemoteCmd())
+	rootCmd.AddCommand(newRunCmd(cfg))
 	// Add the new version command.
 	rootCmd.AddCommand(NewVersionCmd())
 	return rootCmd
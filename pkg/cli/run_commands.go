@@ -0,0 +1,87 @@
+// pkg/cli/run_commands.go
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/SailfinIO/agent/pkg/agent"
+	"github.com/SailfinIO/agent/pkg/config"
+	"github.com/SailfinIO/agent/pkg/jobs"
+	"github.com/SailfinIO/agent/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// newRunCmd creates the "run" command, which wraps a scheduled job and
+// reports its outcome to the local agent.
+func newRunCmd(cfg *config.Config) *cobra.Command {
+	runCmd := &cobra.Command{
+		Use:   "run [flags] -- command [args...]",
+		Short: "Run a job and report its exit code, duration and output to the agent",
+		Long: "Run a job, typically from cron, and report its exit code, duration and the tail of its\n" +
+			"output to the local agent. Runs are spooled and delivered later if the agent is down.\n" +
+			"The command's exit code is passed through.",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := utils.New().WithContext("run")
+			name, _ := cmd.Flags().GetString("name")
+			tail, _ := cmd.Flags().GetInt("tail")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			run := jobs.Execute(ctx, name, args, os.Stdout, os.Stderr, tail)
+			if ctx.Err() == context.DeadlineExceeded {
+				run.Error = fmt.Sprintf("killed after exceeding timeout of %v", timeout)
+			}
+			reportRun(cfg, run, logger)
+
+			switch {
+			case run.Success:
+				os.Exit(0)
+			case run.ExitCode > 0:
+				os.Exit(run.ExitCode)
+			default:
+				os.Exit(1)
+			}
+		},
+	}
+	// Flags after the command name belong to the command.
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().String("name", "", "Job name (defaults to the command's base name)")
+	runCmd.Flags().Int("tail", jobs.DefaultTailBytes, "Bytes of trailing output to keep")
+	runCmd.Flags().Duration("timeout", 0, "Kill the command after this long (0 for no limit)")
+	return runCmd
+}
+
+// reportRun sends run to the agent, spooling it if the agent cannot be
+// reached. After a successful report, previously spooled runs are sent too.
+func reportRun(cfg *config.Config, run jobs.Run, logger utils.Logger) {
+	client := jobs.NewClient(cfg.ServerAddress, cfg.ApiKey)
+	dir, err := agent.JobSpoolDir()
+	if err != nil {
+		logger.Error("Error locating job spool: " + err.Error())
+		return
+	}
+	spool := jobs.NewSpool(dir)
+
+	if err := client.Report(run); err != nil {
+		logger.Warn(fmt.Sprintf("Agent unavailable (%v); spooling run of %s", err, run.Job))
+		if err := spool.Add(run); err != nil {
+			logger.Error("Error spooling job run: " + err.Error())
+		}
+		return
+	}
+	if _, err := spool.Drain(client.Report); err != nil {
+		logger.Warn("Error sending spooled job runs: " + err.Error())
+	}
+}
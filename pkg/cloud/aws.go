@@ -0,0 +1,114 @@
+// pkg/cloud/aws.go
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DefaultAWSBaseURL is the EC2 instance metadata service endpoint.
+const DefaultAWSBaseURL = "http://169.254.169.254"
+
+// awsTokenTTL is the lifetime requested for IMDSv2 session tokens, in seconds.
+const awsTokenTTL = "60"
+
+// AWSProvider reads EC2 instance metadata using the IMDSv2 token flow.
+type AWSProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewAWSProvider returns a provider querying baseURL, or DefaultAWSBaseURL when empty.
+func NewAWSProvider(baseURL string, client *http.Client) *AWSProvider {
+	if baseURL == "" {
+		baseURL = DefaultAWSBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &AWSProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// Name returns "aws".
+func (p *AWSProvider) Name() string { return "aws" }
+
+// Fetch obtains a session token and reads the instance identity and tags.
+// Tags are only available when "instance metadata tags" are enabled on the
+// instance; a 404 for them is not an error.
+func (p *AWSProvider) Fetch(ctx context.Context) (*Metadata, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &Metadata{Provider: "aws"}
+	fields := []struct {
+		path string
+		dst  *string
+	}{
+		{"instance-id", &md.InstanceID},
+		{"instance-type", &md.InstanceType},
+		{"placement/region", &md.Region},
+		{"placement/availability-zone", &md.Zone},
+	}
+	for _, f := range fields {
+		v, err := p.get(ctx, token, f.path)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = v
+	}
+	if v, err := p.get(ctx, token, "identity-credentials/ec2/info"); err == nil {
+		var info struct {
+			AccountID string `json:"AccountId"`
+		}
+		if json.Unmarshal([]byte(v), &info) == nil {
+			md.AccountID = info.AccountID
+		}
+	}
+
+	keys, err := p.get(ctx, token, "tags/instance")
+	switch {
+	case err == nil:
+		md.Tags = map[string]string{}
+		for _, key := range strings.Fields(keys) {
+			if v, err := p.get(ctx, token, "tags/instance/"+key); err == nil {
+				md.Tags[key] = v
+			}
+		}
+	case !isNotFound(err):
+		return nil, err
+	}
+	return md, nil
+}
+
+// token requests an IMDSv2 session token.
+func (p *AWSProvider) token(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.baseURL+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", awsTokenTTL)
+	body, err := doRequest(p.client, req)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// get reads a meta-data path using the session token.
+func (p *AWSProvider) get(ctx context.Context, token, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/latest/meta-data/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	body, err := doRequest(p.client, req)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,88 @@
+// pkg/cloud/azure.go
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DefaultAzureBaseURL is the Azure Instance Metadata Service endpoint.
+const DefaultAzureBaseURL = "http://169.254.169.254"
+
+// azureAPIVersion is the IMDS API version requested.
+const azureAPIVersion = "2021-02-01"
+
+// AzureProvider reads Azure virtual machine metadata.
+type AzureProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewAzureProvider returns a provider querying baseURL, or DefaultAzureBaseURL when empty.
+func NewAzureProvider(baseURL string, client *http.Client) *AzureProvider {
+	if baseURL == "" {
+		baseURL = DefaultAzureBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &AzureProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// Name returns "azure".
+func (p *AzureProvider) Name() string { return "azure" }
+
+// azureInstance is the subset of the instance document we use.
+type azureInstance struct {
+	Compute struct {
+		VMID           string `json:"vmId"`
+		VMSize         string `json:"vmSize"`
+		Location       string `json:"location"`
+		Zone           string `json:"zone"`
+		SubscriptionID string `json:"subscriptionId"`
+		TagsList       []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tagsList"`
+	} `json:"compute"`
+}
+
+// Fetch reads the instance document.
+func (p *AzureProvider) Fetch(ctx context.Context) (*Metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/metadata/instance?api-version="+azureAPIVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	body, err := doRequest(p.client, req)
+	if err != nil {
+		return nil, err
+	}
+	var inst azureInstance
+	if err := json.Unmarshal(body, &inst); err != nil {
+		return nil, err
+	}
+	if inst.Compute.VMID == "" {
+		return nil, errors.New("metadata service returned no VM ID")
+	}
+
+	md := &Metadata{
+		Provider:     "azure",
+		InstanceID:   inst.Compute.VMID,
+		InstanceType: inst.Compute.VMSize,
+		Region:       inst.Compute.Location,
+		Zone:         inst.Compute.Zone,
+		AccountID:    inst.Compute.SubscriptionID,
+	}
+	if len(inst.Compute.TagsList) > 0 {
+		md.Tags = map[string]string{}
+		for _, t := range inst.Compute.TagsList {
+			md.Tags[t.Name] = t.Value
+		}
+	}
+	return md, nil
+}
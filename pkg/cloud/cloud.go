@@ -0,0 +1,136 @@
+// pkg/cloud/cloud.go
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotDetected is returned when no provider's metadata service responded.
+var ErrNotDetected = errors.New("no cloud instance metadata service detected")
+
+// Metadata describes the cloud instance the agent runs on.
+type Metadata struct {
+	Provider     string            `json:"provider"`
+	InstanceID   string            `json:"instanceId"`
+	InstanceType string            `json:"instanceType"`
+	Region       string            `json:"region"`
+	Zone         string            `json:"zone"`
+	AccountID    string            `json:"accountId,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// Labels flattens the metadata into host labels. Tags are prefixed with
+// "tag_" so they cannot collide with the fixed labels.
+func (m *Metadata) Labels() map[string]string {
+	labels := map[string]string{
+		"cloud_provider": m.Provider,
+		"instance_id":    m.InstanceID,
+		"instance_type":  m.InstanceType,
+		"region":         m.Region,
+		"zone":           m.Zone,
+	}
+	if m.AccountID != "" {
+		labels["cloud_account"] = m.AccountID
+	}
+	for k, v := range m.Tags {
+		labels["tag_"+k] = v
+	}
+	for k, v := range labels {
+		if v == "" {
+			delete(labels, k)
+		}
+	}
+	return labels
+}
+
+// Provider fetches instance metadata from one cloud's metadata service.
+type Provider interface {
+	// Name returns the provider identifier, e.g. "aws".
+	Name() string
+	// Fetch queries the metadata service.
+	Fetch(ctx context.Context) (*Metadata, error)
+}
+
+// Detect tries each provider in order and returns the first successful
+// result. Each attempt is bounded by timeout.
+func Detect(ctx context.Context, providers []Provider, timeout time.Duration) (*Metadata, error) {
+	var errs []string
+	for _, p := range providers {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		md, err := p.Fetch(attemptCtx)
+		cancel()
+		if err == nil {
+			return md, nil
+		}
+		errs = append(errs, p.Name()+": "+err.Error())
+	}
+	if len(errs) == 0 {
+		return nil, ErrNotDetected
+	}
+	return nil, fmt.Errorf("%w (%s)", ErrNotDetected, strings.Join(errs, "; "))
+}
+
+// NewProvider returns the provider with the given name using baseURL, or
+// the provider's standard endpoint when baseURL is empty.
+func NewProvider(name, baseURL string, client *http.Client) (Provider, error) {
+	switch name {
+	case "aws":
+		return NewAWSProvider(baseURL, client), nil
+	case "gcp":
+		return NewGCPProvider(baseURL, client), nil
+	case "azure":
+		return NewAzureProvider(baseURL, client), nil
+	default:
+		return nil, fmt.Errorf("unknown cloud provider %q", name)
+	}
+}
+
+// doRequest performs a metadata request and returns the body of a 200 response.
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Metadata responses are small; cap reads in case something else answers.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{url: req.URL.String(), code: resp.StatusCode}
+	}
+	return body, nil
+}
+
+// statusError reports an unexpected HTTP status from a metadata service.
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.url, e.code)
+}
+
+// isNotFound reports whether err is a 404 from the metadata service.
+func isNotFound(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == http.StatusNotFound
+}
+
+// lastSegment returns the part of s after the final slash, e.g. the zone
+// name from "projects/123/zones/us-central1-a".
+func lastSegment(s string) string {
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+// pkg/cloud/gcp.go
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DefaultGCPBaseURL is the Compute Engine metadata server endpoint.
+const DefaultGCPBaseURL = "http://metadata.google.internal"
+
+// GCPProvider reads Compute Engine instance metadata.
+type GCPProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewGCPProvider returns a provider querying baseURL, or DefaultGCPBaseURL when empty.
+func NewGCPProvider(baseURL string, client *http.Client) *GCPProvider {
+	if baseURL == "" {
+		baseURL = DefaultGCPBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &GCPProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// Name returns "gcp".
+func (p *GCPProvider) Name() string { return "gcp" }
+
+// gcpInstance is the subset of the recursive instance document we use.
+type gcpInstance struct {
+	ID          json.Number `json:"id"`
+	MachineType string      `json:"machineType"`
+	Zone        string      `json:"zone"`
+	Tags        []string    `json:"tags"`
+}
+
+// Fetch reads the instance document and project ID. Network tags have no
+// value and are reported as tags set to "true".
+func (p *GCPProvider) Fetch(ctx context.Context) (*Metadata, error) {
+	body, err := p.get(ctx, "/computeMetadata/v1/instance/?recursive=true")
+	if err != nil {
+		return nil, err
+	}
+	var inst gcpInstance
+	if err := json.Unmarshal(body, &inst); err != nil {
+		return nil, err
+	}
+	if inst.ID == "" {
+		return nil, errors.New("metadata server returned no instance ID")
+	}
+
+	zone := lastSegment(inst.Zone)
+	md := &Metadata{
+		Provider:     "gcp",
+		InstanceID:   inst.ID.String(),
+		InstanceType: lastSegment(inst.MachineType),
+		Zone:         zone,
+		Region:       gcpRegion(zone),
+	}
+	if project, err := p.get(ctx, "/computeMetadata/v1/project/project-id"); err == nil {
+		md.AccountID = strings.TrimSpace(string(project))
+	}
+	if len(inst.Tags) > 0 {
+		md.Tags = map[string]string{}
+		for _, t := range inst.Tags {
+			md.Tags[t] = "true"
+		}
+	}
+	return md, nil
+}
+
+// get performs a metadata request with the required Metadata-Flavor header.
+func (p *GCPProvider) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doRequest(p.client, req)
+}
+
+// gcpRegion derives the region from a zone such as "us-central1-a".
+func gcpRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
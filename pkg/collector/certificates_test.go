@@ -0,0 +1,161 @@
+// pkg/collector/certificates_test.go
+
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a generated certificate with its key.
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+// pem returns the certificate in PEM form.
+func (c testCert) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+// newTestCert creates a certificate valid from notBefore to notAfter, signed
+// by parent or self-signed when parent is nil.
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert, notBefore, notAfter time.Time) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore, tmpl.NotAfter = notBefore, notAfter
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert: cert, der: der, key: key}
+}
+
+// newTestChain returns a CA and a leaf for localhost and 127.0.0.1 that
+// expires after validFor.
+func newTestChain(t *testing.T, validFor time.Duration) (ca, leaf testCert) {
+	t.Helper()
+	now := time.Now()
+	ca = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, now.Add(-time.Hour), now.Add(24*time.Hour))
+	leaf = newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca, now.Add(-time.Hour), now.Add(validFor))
+	return ca, leaf
+}
+
+func TestCertificateFiles(t *testing.T) {
+	ca, leaf := newTestChain(t, 48*time.Hour)
+	_, expired := newTestChain(t, -time.Minute)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a-chain.pem"), append(leaf.pem(), ca.pem()...), 0644)
+	os.WriteFile(filepath.Join(dir, "b-expired.pem"), expired.pem(), 0644)
+	os.WriteFile(filepath.Join(dir, "c-empty.pem"), []byte("not a certificate"), 0644)
+	os.WriteFile(filepath.Join(dir, "ca.crt"), ca.pem(), 0644)
+
+	roots, err := LoadCertPool(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(filepath.Join(dir, "c-empty.pem")); err == nil {
+		t.Error("LoadCertPool of a file without certificates succeeded")
+	}
+
+	v, err := NewCertificateCollector([]string{filepath.Join(dir, "*.pem")}, nil, roots, 0).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := v.([]map[string]interface{})
+	if len(results) != 3 {
+		t.Fatalf("Collect returned %d results, want 3: %v", len(results), results)
+	}
+	chain, old, empty := results[0], results[1], results[2]
+	if chain["chainValid"] != true || chain["expired"] != false || chain["subject"] != "CN=localhost" || chain["issuer"] != "CN=Test CA" {
+		t.Errorf("chain = %v", chain)
+	}
+	if days := chain["daysRemaining"].(float64); days < 1.9 || days > 2 {
+		t.Errorf("daysRemaining = %v, want about 2", days)
+	}
+	// The expired leaf is signed by a CA not in roots.
+	if old["expired"] != true || old["chainValid"] != false || old["chainError"] == nil {
+		t.Errorf("expired = %v", old)
+	}
+	if empty["error"] == nil || empty["source"] != filepath.Join(dir, "c-empty.pem") {
+		t.Errorf("empty = %v", empty)
+	}
+}
+
+func TestCertificateEndpoints(t *testing.T) {
+	ca, leaf := newTestChain(t, 24*time.Hour)
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.der, ca.der}, PrivateKey: leaf.key}}}
+	srv.StartTLS()
+	defer srv.Close()
+	endpoint := srv.Listener.Addr().String()
+
+	// An endpoint that refuses connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	v, err := NewCertificateCollector(nil, []string{endpoint, closed, "no-port"}, roots, time.Second).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := v.([]map[string]interface{})
+	if len(results) != 3 {
+		t.Fatalf("Collect returned %d results, want 3: %v", len(results), results)
+	}
+	if r := results[0]; r["source"] != endpoint || r["chainValid"] != true || r["subject"] != "CN=localhost" {
+		t.Errorf("endpoint = %v", r)
+	}
+	for _, r := range results[1:] {
+		if r["error"] == nil || r["type"] != "endpoint" {
+			t.Errorf("unreachable endpoint = %v, want an error", r)
+		}
+	}
+
+	// Without the CA the chain is still reported, but invalid.
+	v, _ = NewCertificateCollector(nil, []string{endpoint}, x509.NewCertPool(), time.Second).Collect()
+	if r := v.([]map[string]interface{})[0]; r["chainValid"] != false || r["notAfter"] == nil {
+		t.Errorf("endpoint with unknown CA = %v", r)
+	}
+}
@@ -0,0 +1,267 @@
+// pkg/collector/cgroup.go
+
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// containerIDPattern matches the 64 character hex IDs used by docker,
+	// containerd, cri-o and podman.
+	containerIDPattern = regexp.MustCompile(`([0-9a-f]{64})`)
+	// podUIDPattern matches Kubernetes pod UIDs in both the cgroupfs
+	// ("pod<uid>") and systemd ("pod<uid_with_underscores>.slice") layouts.
+	podUIDPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// CgroupInfo describes where a process lives in the cgroup hierarchy.
+type CgroupInfo struct {
+	Path        string `json:"cgroup"`
+	ContainerID string `json:"container_id,omitempty"`
+	Runtime     string `json:"container_runtime,omitempty"`
+	PodUID      string `json:"pod_uid,omitempty"`
+	SystemdUnit string `json:"systemd_unit,omitempty"`
+}
+
+// Labels returns the non-empty fields of the info as a label map.
+func (c CgroupInfo) Labels() map[string]string {
+	labels := map[string]string{"cgroup": c.Path}
+	if c.ContainerID != "" {
+		labels["container_id"] = c.ContainerID
+	}
+	if c.Runtime != "" {
+		labels["container_runtime"] = c.Runtime
+	}
+	if c.PodUID != "" {
+		labels["pod_uid"] = c.PodUID
+	}
+	if c.SystemdUnit != "" {
+		labels["systemd_unit"] = c.SystemdUnit
+	}
+	return labels
+}
+
+// CgroupResolver maps process IDs to cgroup metadata by reading
+// <procRoot>/<pid>/cgroup.
+type CgroupResolver struct {
+	procRoot string
+}
+
+// NewCgroupResolver returns a resolver reading from the given proc root.
+// An empty root defaults to the host's /proc.
+func NewCgroupResolver(procRoot string) *CgroupResolver {
+	if procRoot == "" {
+		procRoot = HostProc()
+	}
+	return &CgroupResolver{procRoot: procRoot}
+}
+
+// Resolve reads and parses the cgroup file of the given process.
+func (r *CgroupResolver) Resolve(pid int32) (CgroupInfo, error) {
+	f, err := os.Open(filepath.Join(r.procRoot, strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return CgroupInfo{}, err
+	}
+	defer f.Close()
+
+	var v2Path, systemdPath, firstPath string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Each line is "hierarchy-ID:controller-list:cgroup-path".
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			v2Path = parts[2]
+		case parts[1] == "name=systemd":
+			systemdPath = parts[2]
+		case firstPath == "" && parts[2] != "/":
+			firstPath = parts[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return CgroupInfo{}, err
+	}
+
+	path := v2Path
+	if path == "" || path == "/" {
+		path = systemdPath
+	}
+	if path == "" {
+		path = firstPath
+	}
+	return ParseCgroupPath(path), nil
+}
+
+// ParseCgroupPath extracts container, pod and systemd unit information from
+// a cgroup path such as "/system.slice/docker-<id>.scope" or
+// "/kubepods/burstable/pod<uid>/<id>".
+func ParseCgroupPath(path string) CgroupInfo {
+	info := CgroupInfo{Path: path}
+	if path == "" {
+		return info
+	}
+
+	if m := podUIDPattern.FindStringSubmatch(path); m != nil {
+		info.PodUID = strings.ReplaceAll(m[1], "_", "-")
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	// Walk from the leaf upwards so the innermost container wins.
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if id := containerIDPattern.FindString(seg); id != "" {
+			info.ContainerID = id
+			info.Runtime = containerRuntime(segments[:i+1])
+			break
+		}
+	}
+
+	if info.ContainerID == "" {
+		for i := len(segments) - 1; i >= 0; i-- {
+			seg := segments[i]
+			if strings.HasSuffix(seg, ".service") || strings.HasSuffix(seg, ".scope") {
+				info.SystemdUnit = seg
+				break
+			}
+		}
+	}
+	return info
+}
+
+// containerRuntime guesses the runtime that created a container from the
+// cgroup path segments leading to (and including) its ID.
+func containerRuntime(segments []string) string {
+	leaf := segments[len(segments)-1]
+	switch {
+	case strings.HasPrefix(leaf, "docker-"):
+		return "docker"
+	case strings.HasPrefix(leaf, "cri-containerd-"):
+		return "containerd"
+	case strings.HasPrefix(leaf, "crio-"):
+		return "cri-o"
+	case strings.HasPrefix(leaf, "libpod-"):
+		return "podman"
+	}
+	for i := len(segments) - 2; i >= 0; i-- {
+		switch {
+		case segments[i] == "docker":
+			return "docker"
+		case segments[i] == "lxc.payload" || strings.HasPrefix(segments[i], "lxc"):
+			return "lxc"
+		case strings.HasPrefix(segments[i], "kubepods"):
+			return "kubernetes"
+		}
+	}
+	return ""
+}
+
+// CgroupCollector reports resource usage per cgroup that currently holds at
+// least one process, labelled with container, pod and unit metadata.
+type CgroupCollector struct {
+	resolver *CgroupResolver
+	procRoot string
+	sysRoot  string
+}
+
+// NewCgroupCollector returns a CgroupCollector reading the host's /proc and /sys.
+func NewCgroupCollector() *CgroupCollector {
+	return NewCgroupCollectorWithRoots(HostProc(), HostSys())
+}
+
+// NewCgroupCollectorWithRoots returns a CgroupCollector reading from the given
+// proc and sys roots, which allows running against fixture trees.
+func NewCgroupCollectorWithRoots(procRoot, sysRoot string) *CgroupCollector {
+	return &CgroupCollector{
+		resolver: NewCgroupResolver(procRoot),
+		procRoot: procRoot,
+		sysRoot:  sysRoot,
+	}
+}
+
+// Collect groups processes by cgroup and reads cgroup v2 accounting files.
+// Hosts without a proc filesystem, such as macOS and Windows, report no
+// cgroups.
+func (c *CgroupCollector) Collect() (interface{}, error) {
+	entries, err := os.ReadDir(c.procRoot)
+	if os.IsNotExist(err) {
+		return []map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]map[string]interface{})
+	order := []string{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		info, err := c.resolver.Resolve(int32(pid))
+		if err != nil || info.Path == "" {
+			// The process may have exited between listing and reading.
+			continue
+		}
+		g, ok := groups[info.Path]
+		if !ok {
+			g = map[string]interface{}{
+				"labels":    info.Labels(),
+				"processes": 0,
+			}
+			for k, v := range c.readAccounting(info.Path) {
+				g[k] = v
+			}
+			groups[info.Path] = g
+			order = append(order, info.Path)
+		}
+		g["processes"] = g["processes"].(int) + 1
+	}
+
+	results := make([]map[string]interface{}, 0, len(order))
+	for _, path := range order {
+		results = append(results, groups[path])
+	}
+	return results, nil
+}
+
+// readAccounting reads memory and CPU usage from the unified (v2) hierarchy.
+// Missing files are skipped so hosts on cgroup v1 still report labels.
+func (c *CgroupCollector) readAccounting(path string) map[string]interface{} {
+	dir := filepath.Join(c.sysRoot, "fs", "cgroup", path)
+	out := map[string]interface{}{}
+	if v, err := readUintFile(filepath.Join(dir, "memory.current")); err == nil {
+		out["memoryBytes"] = v
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "cpu.stat")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+					out["cpuUsageUsec"] = v
+				}
+			}
+		}
+	}
+	if v, err := readUintFile(filepath.Join(dir, "pids.current")); err == nil {
+		out["pids"] = v
+	}
+	return out
+}
+
+// readUintFile reads a file holding a single unsigned integer.
+func readUintFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,123 @@
+// pkg/collector/cgroup_test.go
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testContainerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testPodUID      = "12345678-1234-1234-1234-123456789abc"
+)
+
+func TestParseCgroupPath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want CgroupInfo
+	}{
+		{"/system.slice/docker-" + testContainerID + ".scope", CgroupInfo{ContainerID: testContainerID, Runtime: "docker"}},
+		{"/kubepods/burstable/pod" + testPodUID + "/" + testContainerID, CgroupInfo{ContainerID: testContainerID, Runtime: "kubernetes", PodUID: testPodUID}},
+		{"/kubepods.slice/kubepods-pod12345678_1234_1234_1234_123456789abc.slice/cri-containerd-" + testContainerID + ".scope",
+			CgroupInfo{ContainerID: testContainerID, Runtime: "containerd", PodUID: testPodUID}},
+		{"/docker/" + testContainerID, CgroupInfo{ContainerID: testContainerID, Runtime: "docker"}},
+		{"/system.slice/sshd.service", CgroupInfo{SystemdUnit: "sshd.service"}},
+		{"/user.slice/user-1000.slice/session-2.scope", CgroupInfo{SystemdUnit: "session-2.scope"}},
+		{"/", CgroupInfo{}},
+		{"", CgroupInfo{}},
+	} {
+		tc.want.Path = tc.path
+		if got := ParseCgroupPath(tc.path); got != tc.want {
+			t.Errorf("ParseCgroupPath(%q) = %+v, want %+v", tc.path, got, tc.want)
+		}
+	}
+}
+
+// writeFixture writes files below root, creating directories as needed.
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCgroupResolver(t *testing.T) {
+	proc := t.TempDir()
+	writeFixture(t, proc, map[string]string{
+		// cgroup v2: the unified entry wins.
+		"1/cgroup": "0::/system.slice/sshd.service\n",
+		// Hybrid: the unified entry is the root, so name=systemd is used.
+		"2/cgroup": "12:memory:/docker/" + testContainerID + "\n1:name=systemd:/system.slice/cron.service\n0::/\n",
+		// cgroup v1 without systemd: the first non-root path.
+		"3/cgroup": "4:cpu:/\n3:memory:/docker/" + testContainerID + "\n",
+	})
+	r := NewCgroupResolver(proc)
+	for pid, want := range map[int32]string{1: "/system.slice/sshd.service", 2: "/system.slice/cron.service", 3: "/docker/" + testContainerID} {
+		info, err := r.Resolve(pid)
+		if err != nil || info.Path != want {
+			t.Errorf("Resolve(%d) = %+v, %v; want path %s", pid, info, err, want)
+		}
+	}
+	if _, err := r.Resolve(4); err == nil {
+		t.Error("Resolve of a missing process succeeded")
+	}
+}
+
+func TestCgroupCollector(t *testing.T) {
+	root := t.TempDir()
+	proc, sys := filepath.Join(root, "proc"), filepath.Join(root, "sys")
+	docker := "/system.slice/docker-" + testContainerID + ".scope"
+	writeFixture(t, root, map[string]string{
+		"proc/10/cgroup":   "0::" + docker + "\n",
+		"proc/11/cgroup":   "0::" + docker + "\n",
+		"proc/20/cgroup":   "0::/system.slice/sshd.service\n",
+		"proc/self/cgroup": "0::/ignored\n",
+		"proc/30/status":   "no cgroup file, as if the process exited\n",
+		"sys/fs/cgroup" + docker + "/memory.current": "4096\n",
+		"sys/fs/cgroup" + docker + "/cpu.stat":       "usage_usec 1500\nuser_usec 1000\n",
+		"sys/fs/cgroup" + docker + "/pids.current":   "2\n",
+	})
+
+	v, err := NewCgroupCollectorWithRoots(proc, sys).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := v.([]map[string]interface{})
+	if len(groups) != 2 {
+		t.Fatalf("Collect returned %d cgroups, want 2: %v", len(groups), groups)
+	}
+	byPath := map[string]map[string]interface{}{}
+	for _, g := range groups {
+		byPath[g["labels"].(map[string]string)["cgroup"]] = g
+	}
+	g := byPath[docker]
+	if g == nil || g["processes"] != 2 || g["memoryBytes"] != uint64(4096) || g["cpuUsageUsec"] != uint64(1500) || g["pids"] != uint64(2) {
+		t.Errorf("docker cgroup = %v", g)
+	}
+	if labels := g["labels"].(map[string]string); labels["container_runtime"] != "docker" || labels["container_id"] != testContainerID {
+		t.Errorf("docker cgroup labels = %v", labels)
+	}
+	// Without v2 accounting files only labels and the process count remain.
+	if g := byPath["/system.slice/sshd.service"]; len(g) != 2 || g["processes"] != 1 {
+		t.Errorf("sshd cgroup = %v", g)
+	}
+}
+
+func TestCgroupCollectorNoProc(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewCgroupCollectorWithRoots(filepath.Join(dir, "proc"), filepath.Join(dir, "sys")).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups := v.([]map[string]interface{}); len(groups) != 0 {
+		t.Errorf("Collect without /proc = %v, want no cgroups", groups)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+// pkg/collector/clocksync_linux.go
+
+package collector
+
+import (
+	"syscall"
+	"time"
+)
+
+// Kernel clock states returned by adjtimex(2).
+var adjtimexStates = map[int]string{
+	0: "ok",
+	1: "insert-leap",
+	2: "delete-leap",
+	3: "leap-in-progress",
+	4: "leap-occurred",
+	5: "error",
+}
+
+const (
+	staUnsync = 0x0040 // STA_UNSYNC: clock not synchronized.
+	staNano   = 0x2000 // STA_NANO: offset is in nanoseconds.
+)
+
+// readClockSyncStatus reads the kernel clock discipline with a read-only
+// adjtimex call.
+func readClockSyncStatus() (*ClockSyncStatus, error) {
+	var tx syscall.Timex
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := adjtimexStates[state]
+	if !ok {
+		name = "unknown"
+	}
+	offset := time.Duration(tx.Offset) * time.Microsecond
+	if tx.Status&staNano != 0 {
+		offset = time.Duration(tx.Offset)
+	}
+	return &ClockSyncStatus{
+		Synchronized: state != 5 && tx.Status&staUnsync == 0,
+		State:        name,
+		Offset:       offset,
+		MaxError:     time.Duration(tx.Maxerror) * time.Microsecond,
+		EstError:     time.Duration(tx.Esterror) * time.Microsecond,
+	}, nil
+}
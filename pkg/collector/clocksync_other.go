@@ -0,0 +1,12 @@
+//go:build !linux
+
+// pkg/collector/clocksync_other.go
+
+package collector
+
+import "errors"
+
+// readClockSyncStatus is only implemented on Linux.
+func readClockSyncStatus() (*ClockSyncStatus, error) {
+	return nil, errors.New("kernel clock status is not supported on this platform")
+}
@@ -0,0 +1,280 @@
+// pkg/collector/dirsize.go
+
+package collector
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrScanInProgress is returned when a scan is requested while one is running.
+var ErrScanInProgress = errors.New("directory scan already in progress")
+
+// SizeEntry is a path together with its size in bytes.
+type SizeEntry struct {
+	Path string `json:"path"`
+	Size uint64 `json:"size"`
+}
+
+// DirScanResult holds the outcome of scanning a single root path.
+type DirScanResult struct {
+	Root       string        `json:"root"`
+	StartedAt  time.Time     `json:"startedAt"`
+	Duration   time.Duration `json:"duration"`
+	TotalBytes uint64        `json:"totalBytes"`
+	Files      int           `json:"files"`
+	Dirs       int           `json:"dirs"`
+	Errors     int           `json:"errors"`
+	// SkippedMounts counts directories that were not entered because they
+	// live on a different filesystem than the root.
+	SkippedMounts int         `json:"skippedMounts"`
+	TopDirs       []SizeEntry `json:"topDirs"`
+	TopFiles      []SizeEntry `json:"topFiles"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// DirSizeScanner finds the largest directories and files below a set of
+// paths. Scans stay on the filesystem of each root and are throttled to a
+// maximum number of entries per second.
+type DirSizeScanner struct {
+	paths      []string
+	topN       int
+	maxDepth   int
+	maxPerSec  int
+	mu         sync.Mutex
+	running    bool
+	lastScan   time.Time
+	lastResult []DirScanResult
+}
+
+// NewDirSizeScanner returns a scanner for the given paths. topN and maxDepth
+// fall back to 20 and 3 when not positive; maxPerSec <= 0 disables throttling.
+func NewDirSizeScanner(paths []string, topN, maxDepth, maxPerSec int) *DirSizeScanner {
+	if topN <= 0 {
+		topN = 20
+	}
+	if maxDepth <= 0 {
+		maxDepth = 3
+	}
+	return &DirSizeScanner{
+		paths:     paths,
+		topN:      topN,
+		maxDepth:  maxDepth,
+		maxPerSec: maxPerSec,
+	}
+}
+
+// Latest returns the results of the most recent completed scan and when it ran.
+func (s *DirSizeScanner) Latest() ([]DirScanResult, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastResult, s.lastScan
+}
+
+// Running reports whether a scan is currently in progress.
+func (s *DirSizeScanner) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// Scan scans all configured paths and stores the results. Only one scan may
+// run at a time; concurrent calls return ErrScanInProgress.
+func (s *DirSizeScanner) Scan(ctx context.Context) ([]DirScanResult, error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrScanInProgress
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	results := make([]DirScanResult, 0, len(s.paths))
+	for _, p := range s.paths {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, s.scanRoot(ctx, p))
+	}
+
+	s.mu.Lock()
+	s.running = false
+	s.lastScan = time.Now()
+	s.lastResult = results
+	s.mu.Unlock()
+	return results, ctx.Err()
+}
+
+// Run scans immediately and then every interval until ctx is cancelled.
+func (s *DirSizeScanner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Scan(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dirScan carries the state of a single root scan.
+type dirScan struct {
+	ctx      context.Context
+	scanner  *DirSizeScanner
+	rootDev  uint64
+	checkDev bool
+	visited  int
+	start    time.Time
+	inodes   map[fileID]bool
+	dirs     *sizeHeap
+	files    *sizeHeap
+	result   *DirScanResult
+}
+
+// scanRoot scans a single root path.
+func (s *DirSizeScanner) scanRoot(ctx context.Context, root string) DirScanResult {
+	result := DirScanResult{Root: root, StartedAt: time.Now()}
+	localRoot := HostPath(root)
+	info, err := os.Lstat(localRoot)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !info.IsDir() {
+		result.Error = "not a directory"
+		return result
+	}
+
+	st := &dirScan{
+		ctx:     ctx,
+		scanner: s,
+		start:   result.StartedAt,
+		inodes:  make(map[fileID]bool),
+		dirs:    &sizeHeap{},
+		files:   &sizeHeap{},
+		result:  &result,
+	}
+	st.rootDev, st.checkDev = deviceOf(info)
+
+	result.TotalBytes = st.walk(localRoot, 0)
+	result.TopDirs = st.dirs.sorted(localRoot, root)
+	result.TopFiles = st.files.sorted(localRoot, root)
+	result.Duration = time.Since(result.StartedAt)
+	if ctx.Err() != nil {
+		result.Error = ctx.Err().Error()
+	}
+	return result
+}
+
+// walk returns the total size of dir, recording directories up to maxDepth
+// and every regular file in the top-N heaps.
+func (st *dirScan) walk(dir string, depth int) uint64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		st.result.Errors++
+		return 0
+	}
+	st.result.Dirs++
+
+	var total uint64
+	for _, e := range entries {
+		if st.ctx.Err() != nil {
+			return total
+		}
+		st.throttle()
+
+		path := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			st.result.Errors++
+			continue
+		}
+		switch {
+		case info.IsDir():
+			if dev, ok := deviceOf(info); st.checkDev && ok && dev != st.rootDev {
+				st.result.SkippedMounts++
+				continue
+			}
+			total += st.walk(path, depth+1)
+		case info.Mode().IsRegular():
+			// Count hard-linked files only once.
+			if id, ok := fileIDOf(info); ok {
+				if st.inodes[id] {
+					continue
+				}
+				st.inodes[id] = true
+			}
+			size := diskUsageOf(info)
+			st.result.Files++
+			total += size
+			st.files.offer(SizeEntry{Path: path, Size: size}, st.scanner.topN)
+		}
+	}
+
+	if depth <= st.scanner.maxDepth {
+		st.dirs.offer(SizeEntry{Path: dir, Size: total}, st.scanner.topN)
+	}
+	return total
+}
+
+// throttle sleeps as needed to keep the scan under maxPerSec entries.
+func (st *dirScan) throttle() {
+	st.visited++
+	limit := st.scanner.maxPerSec
+	if limit <= 0 || st.visited%100 != 0 {
+		return
+	}
+	expected := time.Duration(float64(st.visited) / float64(limit) * float64(time.Second))
+	if elapsed := time.Since(st.start); elapsed < expected {
+		time.Sleep(expected - elapsed)
+	}
+}
+
+// sizeHeap is a min-heap of SizeEntry used to keep the N largest entries.
+type sizeHeap []SizeEntry
+
+func (h sizeHeap) Len() int            { return len(h) }
+func (h sizeHeap) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h sizeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sizeHeap) Push(x interface{}) { *h = append(*h, x.(SizeEntry)) }
+func (h *sizeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// offer adds e if the heap holds fewer than n entries or e is larger than
+// the current smallest entry.
+func (h *sizeHeap) offer(e SizeEntry, n int) {
+	if h.Len() < n {
+		heap.Push(h, e)
+		return
+	}
+	if e.Size > (*h)[0].Size {
+		(*h)[0] = e
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted returns the entries ordered from largest to smallest, with paths
+// below localRoot rewritten to be below root.
+func (h *sizeHeap) sorted(localRoot, root string) []SizeEntry {
+	out := append([]SizeEntry(nil), (*h)...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Size > out[j].Size })
+	for i := range out {
+		if rel, err := filepath.Rel(localRoot, out[i].Path); err == nil {
+			out[i].Path = filepath.Join(root, rel)
+		}
+	}
+	return out
+}
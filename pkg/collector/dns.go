@@ -0,0 +1,438 @@
+// pkg/collector/dns.go
+
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dnsTypes maps supported query type names to their numeric codes.
+var dnsTypes = map[string]uint16{
+	"A":     1,
+	"NS":    2,
+	"CNAME": 5,
+	"PTR":   12,
+	"MX":    15,
+	"TXT":   16,
+	"AAAA":  28,
+}
+
+// dnsRcodes names the response codes defined by RFC 1035 and RFC 2136.
+var dnsRcodes = map[int]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+// DNSAnswer is a resource record from the answer section of a response.
+type DNSAnswer struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	TTL   uint32 `json:"ttl"`
+	Value string `json:"value"`
+}
+
+// DNSResponse is a decoded DNS response.
+type DNSResponse struct {
+	Rcode     int
+	Truncated bool
+	Answers   []DNSAnswer
+}
+
+// RcodeName returns the mnemonic of the response code, e.g. "NXDOMAIN".
+func (r *DNSResponse) RcodeName() string {
+	if name, ok := dnsRcodes[r.Rcode]; ok {
+		return name
+	}
+	return strconv.Itoa(r.Rcode)
+}
+
+// DNSQuery sends a recursive query for name and qtype to server (host:port)
+// over UDP, retrying over TCP when the response is truncated.
+func DNSQuery(server, name, qtype string, timeout time.Duration) (*DNSResponse, error) {
+	code, ok := dnsTypes[strings.ToUpper(qtype)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported query type %q", qtype)
+	}
+	id := uint16(rand.Intn(1 << 16))
+	msg, err := buildDNSQuery(id, name, code)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dnsExchangeUDP(server, msg, timeout)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseDNSResponse(resp, id)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Truncated {
+		if resp, err = dnsExchangeTCP(server, msg, timeout); err != nil {
+			return nil, err
+		}
+		return parseDNSResponse(resp, id)
+	}
+	return parsed, nil
+}
+
+// buildDNSQuery encodes a query message with recursion desired.
+func buildDNSQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, 1) // IN
+	return msg, nil
+}
+
+// dnsExchangeUDP sends msg and waits for the response with the same ID.
+func dnsExchangeUDP(server string, msg []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Ignore stray datagrams that do not answer this query.
+		if n >= 2 && buf[0] == msg[0] && buf[1] == msg[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+// dnsExchangeTCP sends msg with the two-byte length prefix used over TCP.
+func dnsExchangeTCP(server string, msg []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(framed, msg...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// errDNSShort is returned for responses that end in the middle of a field.
+var errDNSShort = errors.New("truncated DNS message")
+
+// parseDNSResponse decodes the header and answer section of a response.
+func parseDNSResponse(msg []byte, id uint16) (*DNSResponse, error) {
+	if len(msg) < 12 {
+		return nil, errDNSShort
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, fmt.Errorf("response ID mismatch")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 {
+		return nil, fmt.Errorf("message is not a response")
+	}
+	resp := &DNSResponse{
+		Rcode:     int(flags & 0x000f),
+		Truncated: flags&0x0200 != 0,
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		_, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+	for i := 0; i < ancount; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+		if off+10 > len(msg) {
+			return nil, errDNSShort
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, errDNSShort
+		}
+		value, err := decodeDNSRData(msg, off, rdlen, rtype)
+		if err != nil {
+			return nil, err
+		}
+		off += rdlen
+		resp.Answers = append(resp.Answers, DNSAnswer{Name: name, Type: dnsTypeName(rtype), TTL: ttl, Value: value})
+	}
+	return resp, nil
+}
+
+// readDNSName reads a possibly compressed domain name at off and returns it
+// with the offset just past it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errDNSShort
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errDNSShort
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			if jumps++; jumps > 32 {
+				return "", 0, fmt.Errorf("DNS name compression loop")
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errDNSShort
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// decodeDNSRData renders the record data of supported types as text.
+func decodeDNSRData(msg []byte, off, length int, rtype uint16) (string, error) {
+	rdata := msg[off : off+length]
+	switch rtype {
+	case dnsTypes["A"], dnsTypes["AAAA"]:
+		if length != net.IPv4len && length != net.IPv6len {
+			return "", fmt.Errorf("invalid address length %d", length)
+		}
+		return net.IP(rdata).String(), nil
+	case dnsTypes["NS"], dnsTypes["CNAME"], dnsTypes["PTR"]:
+		name, _, err := readDNSName(msg, off)
+		return name, err
+	case dnsTypes["MX"]:
+		if length < 3 {
+			return "", errDNSShort
+		}
+		name, _, err := readDNSName(msg, off+2)
+		return fmt.Sprintf("%d %s", binary.BigEndian.Uint16(rdata), name), err
+	case dnsTypes["TXT"]:
+		var parts []string
+		for i := 0; i < len(rdata); {
+			n := int(rdata[i])
+			if i+1+n > len(rdata) {
+				return "", errDNSShort
+			}
+			parts = append(parts, string(rdata[i+1:i+1+n]))
+			i += 1 + n
+		}
+		return strings.Join(parts, ""), nil
+	}
+	return fmt.Sprintf("%x", rdata), nil
+}
+
+// dnsTypeName returns the mnemonic for a record type code.
+func dnsTypeName(code uint16) string {
+	for name, c := range dnsTypes {
+		if c == code {
+			return name
+		}
+	}
+	return "TYPE" + strconv.Itoa(int(code))
+}
+
+// SystemResolvers returns the nameservers listed in /etc/resolv.conf as
+// host:port addresses, falling back to the local resolver like the Go and
+// libc resolvers do.
+func SystemResolvers() []string {
+	var servers []string
+	if f, err := os.Open("/etc/resolv.conf"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, withDNSPort(fields[1]))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1:53"}
+	}
+	return servers
+}
+
+// withDNSPort appends port 53 to addresses that have no port.
+func withDNSPort(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+}
+
+// DNSProbeSpec is a name to resolve and what to expect back.
+type DNSProbeSpec struct {
+	Name string
+	Type string // Defaults to "A".
+	// Resolvers are host or host:port addresses. The system resolvers are
+	// used when empty.
+	Resolvers []string
+	// Expected answer values. When set, the probe reports whether every
+	// expected value was returned.
+	Expected []string
+	Timeout  time.Duration // Defaults to 2 seconds.
+}
+
+// DNSProbeCollector resolves configured names against their resolvers.
+type DNSProbeCollector struct {
+	probes []DNSProbeSpec
+}
+
+// NewDNSProbeCollector validates the probes and returns a collector.
+func NewDNSProbeCollector(probes []DNSProbeSpec) (*DNSProbeCollector, error) {
+	out := make([]DNSProbeSpec, 0, len(probes))
+	for _, p := range probes {
+		if p.Type == "" {
+			p.Type = "A"
+		}
+		p.Type = strings.ToUpper(p.Type)
+		if _, ok := dnsTypes[p.Type]; !ok {
+			return nil, fmt.Errorf("probe %s: unsupported query type %q", p.Name, p.Type)
+		}
+		if p.Timeout <= 0 {
+			p.Timeout = 2 * time.Second
+		}
+		resolvers := make([]string, 0, len(p.Resolvers))
+		for _, r := range p.Resolvers {
+			resolvers = append(resolvers, withDNSPort(r))
+		}
+		p.Resolvers = resolvers
+		out = append(out, p)
+	}
+	return &DNSProbeCollector{probes: out}, nil
+}
+
+// Collect runs every probe against each of its resolvers. Failures are
+// reported per result rather than failing the collection.
+func (d *DNSProbeCollector) Collect() (interface{}, error) {
+	results := []map[string]interface{}{}
+	for _, p := range d.probes {
+		resolvers := p.Resolvers
+		if len(resolvers) == 0 {
+			resolvers = SystemResolvers()
+		}
+		for _, server := range resolvers {
+			results = append(results, runDNSProbe(p, server))
+		}
+	}
+	return results, nil
+}
+
+// runDNSProbe performs one query and describes the outcome.
+func runDNSProbe(p DNSProbeSpec, server string) map[string]interface{} {
+	entry := map[string]interface{}{
+		"name":     p.Name,
+		"type":     p.Type,
+		"resolver": server,
+	}
+	start := time.Now()
+	resp, err := DNSQuery(server, p.Name, p.Type, p.Timeout)
+	entry["latencyMs"] = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		entry["ok"] = false
+		entry["error"] = err.Error()
+		return entry
+	}
+
+	var values []string
+	for _, a := range resp.Answers {
+		if a.Type == p.Type {
+			values = append(values, a.Value)
+		}
+	}
+	entry["rcode"] = resp.RcodeName()
+	entry["answerCount"] = len(resp.Answers)
+	entry["answers"] = resp.Answers
+	ok := resp.Rcode == 0 && len(values) > 0
+	if len(p.Expected) > 0 {
+		matched := dnsAnswersMatch(values, p.Expected)
+		entry["matched"] = matched
+		ok = ok && matched
+	}
+	entry["ok"] = ok
+	return entry
+}
+
+// dnsAnswersMatch reports whether every expected value is among the answers.
+// Names compare case-insensitively and without a trailing dot.
+func dnsAnswersMatch(values, expected []string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	}
+	got := make(map[string]bool, len(values))
+	for _, v := range values {
+		got[normalize(v)] = true
+	}
+	for _, e := range expected {
+		if !got[normalize(e)] {
+			return false
+		}
+	}
+	return true
+}
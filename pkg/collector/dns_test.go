@@ -0,0 +1,248 @@
+// pkg/collector/dns_test.go
+
+package collector
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dnsRR encodes an answer record owned by the question name.
+func dnsRR(rtype string, ttl uint32, rdata []byte) []byte {
+	rr := []byte{0xc0, 12} // Pointer to the question name.
+	rr = binary.BigEndian.AppendUint16(rr, dnsTypes[rtype])
+	rr = binary.BigEndian.AppendUint16(rr, 1)
+	rr = binary.BigEndian.AppendUint32(rr, ttl)
+	rr = binary.BigEndian.AppendUint16(rr, uint16(len(rdata)))
+	return append(rr, rdata...)
+}
+
+// dnsName encodes a name without compression.
+func dnsName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		b = append(append(b, byte(len(label))), label...)
+	}
+	return append(b, 0)
+}
+
+// dnsReply answers query with the given rcode, truncation flag and records.
+func dnsReply(query []byte, rcode uint16, truncated bool, answers ...[]byte) []byte {
+	_, end, _ := readDNSName(query, 12)
+	msg := append([]byte(nil), query[:end+4]...)
+	flags := 0x8180 | rcode
+	if truncated {
+		flags |= 0x0200
+	}
+	binary.BigEndian.PutUint16(msg[2:], flags)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	for _, a := range answers {
+		msg = append(msg, a...)
+	}
+	return msg
+}
+
+// testDNSAnswer answers a query from a small test zone. Over UDP, the
+// answer for big.example.test is truncated.
+func testDNSAnswer(query []byte, udp bool) []byte {
+	name, end, err := readDNSName(query, 12)
+	if err != nil || end+4 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end:])
+	switch {
+	case name == "example.test" && qtype == dnsTypes["A"]:
+		return dnsReply(query, 0, false, dnsRR("A", 300, []byte{192, 0, 2, 1}), dnsRR("A", 300, []byte{192, 0, 2, 2}))
+	case name == "www.example.test" && qtype == dnsTypes["A"]:
+		return dnsReply(query, 0, false, dnsRR("CNAME", 60, dnsName("Example.test")), dnsRR("A", 300, []byte{192, 0, 2, 1}))
+	case name == "example.test" && qtype == dnsTypes["MX"]:
+		return dnsReply(query, 0, false, dnsRR("MX", 3600, append([]byte{0, 10}, dnsName("mail.example.test")...)))
+	case name == "example.test" && qtype == dnsTypes["TXT"]:
+		return dnsReply(query, 0, false, dnsRR("TXT", 60, []byte("\x06hello \x05world")))
+	case name == "example.test" && qtype == dnsTypes["AAAA"]:
+		return dnsReply(query, 0, false, dnsRR("AAAA", 60, net.ParseIP("2001:db8::1")))
+	case name == "big.example.test" && udp:
+		return dnsReply(query, 0, true)
+	case name == "big.example.test":
+		return dnsReply(query, 0, false, dnsRR("A", 60, []byte{192, 0, 2, 9}))
+	}
+	return dnsReply(query, 3, false)
+}
+
+// fakeDNS runs the test zone over UDP and TCP on one local port.
+func fakeDNS(t *testing.T) string {
+	t.Helper()
+	var pc net.PacketConn
+	var l net.Listener
+	for attempt := 0; l == nil; attempt++ {
+		var err error
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err != nil {
+			pc.Close()
+			if attempt == 10 {
+				t.Fatal(err)
+			}
+		}
+	}
+	t.Cleanup(func() { pc.Close(); l.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply := testDNSAnswer(buf[:n], true); reply != nil {
+				pc.WriteTo(reply, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					reply := testDNSAnswer(query, false)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestDNSQuery(t *testing.T) {
+	server := fakeDNS(t)
+	for _, tc := range []struct {
+		name, qtype string
+		rcode       string
+		want        []DNSAnswer
+	}{
+		{"example.test", "A", "NOERROR", []DNSAnswer{{"example.test", "A", 300, "192.0.2.1"}, {"example.test", "A", 300, "192.0.2.2"}}},
+		{"www.example.test.", "a", "NOERROR", []DNSAnswer{{"www.example.test", "CNAME", 60, "Example.test"}, {"www.example.test", "A", 300, "192.0.2.1"}}},
+		{"example.test", "MX", "NOERROR", []DNSAnswer{{"example.test", "MX", 3600, "10 mail.example.test"}}},
+		{"example.test", "TXT", "NOERROR", []DNSAnswer{{"example.test", "TXT", 60, "hello world"}}},
+		{"example.test", "AAAA", "NOERROR", []DNSAnswer{{"example.test", "AAAA", 60, "2001:db8::1"}}},
+		// Truncated over UDP, so retried over TCP.
+		{"big.example.test", "A", "NOERROR", []DNSAnswer{{"big.example.test", "A", 60, "192.0.2.9"}}},
+		{"missing.example.test", "A", "NXDOMAIN", nil},
+	} {
+		resp, err := DNSQuery(server, tc.name, tc.qtype, time.Second)
+		if err != nil {
+			t.Errorf("%s %s: %v", tc.qtype, tc.name, err)
+			continue
+		}
+		if resp.RcodeName() != tc.rcode || !reflect.DeepEqual(resp.Answers, tc.want) {
+			t.Errorf("%s %s = %s %+v, want %s %+v", tc.qtype, tc.name, resp.RcodeName(), resp.Answers, tc.rcode, tc.want)
+		}
+	}
+
+	for _, tc := range [][2]string{{"example.test", "SRV"}, {"bad..name", "A"}} {
+		if _, err := DNSQuery(server, tc[0], tc[1], time.Second); err == nil {
+			t.Errorf("DNSQuery(%q, %s) succeeded", tc[0], tc[1])
+		}
+	}
+}
+
+func TestParseDNSResponseMalformed(t *testing.T) {
+	query, _ := buildDNSQuery(7, "example.test", dnsTypes["A"])
+	good := dnsReply(query, 0, false, dnsRR("A", 60, []byte{192, 0, 2, 1}))
+	loop := dnsReply(query, 0, false)
+	loop[12], loop[13] = 0xc0, 12 // The question name points at itself.
+	for name, msg := range map[string][]byte{
+		"short header":    good[:11],
+		"cut answer":      good[:len(good)-2],
+		"query":           query,
+		"compression":     loop,
+		"bad address len": dnsReply(query, 0, false, dnsRR("A", 60, []byte{1, 2, 3})),
+	} {
+		if _, err := parseDNSResponse(msg, 7); err == nil {
+			t.Errorf("%s: parseDNSResponse succeeded", name)
+		}
+	}
+	if _, err := parseDNSResponse(good, 8); err == nil {
+		t.Error("parseDNSResponse accepted a mismatched ID")
+	}
+}
+
+func TestDNSProbeCollector(t *testing.T) {
+	server := fakeDNS(t)
+	// A resolver that receives queries but never answers.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	c, err := NewDNSProbeCollector([]DNSProbeSpec{
+		{Name: "www.example.test", Resolvers: []string{server}, Expected: []string{"192.0.2.1"}},
+		{Name: "example.test", Type: "mx", Resolvers: []string{server}, Expected: []string{"10 MAIL.example.test."}},
+		{Name: "example.test", Resolvers: []string{server}, Expected: []string{"192.0.2.3"}},
+		{Name: "missing.example.test", Resolvers: []string{server}},
+		{Name: "example.test", Resolvers: []string{silent.LocalAddr().String()}, Timeout: 50 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := v.([]map[string]interface{})
+	if len(results) != 5 {
+		t.Fatalf("Collect returned %d results, want 5", len(results))
+	}
+	want := []struct {
+		ok      bool
+		matched interface{}
+		rcode   interface{}
+	}{
+		{true, true, "NOERROR"},
+		{true, true, "NOERROR"},
+		{false, false, "NOERROR"},
+		{false, nil, "NXDOMAIN"},
+		{false, nil, nil},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r["ok"] != w.ok || r["matched"] != w.matched || r["rcode"] != w.rcode {
+			t.Errorf("probe %d = %v, want ok %v, matched %v, rcode %v", i, r, w.ok, w.matched, w.rcode)
+		}
+	}
+	if results[4]["error"] == nil {
+		t.Errorf("unanswered probe has no error: %v", results[4])
+	}
+
+	if _, err := NewDNSProbeCollector([]DNSProbeSpec{{Name: "example.test", Type: "SRV"}}); err == nil {
+		t.Error("NewDNSProbeCollector accepted an unsupported type")
+	}
+}
+
+func TestWithDNSPort(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.1":        "10.0.0.1:53",
+		"10.0.0.1:5353":   "10.0.0.1:5353",
+		"2001:db8::1":     "[2001:db8::1]:53",
+		"[2001:db8::1]":   "[2001:db8::1]:53",
+		"[::1]:5353":      "[::1]:5353",
+		"dns.example.com": "dns.example.com:53",
+	} {
+		if got := withDNSPort(in); got != want {
+			t.Errorf("withDNSPort(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// pkg/collector/docker.go
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDockerSocket is the standard location of the Docker Engine API socket.
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// dockerContainer is the subset of /containers/json we use.
+type dockerContainer struct {
+	ID     string   `json:"Id"`
+	Names  []string `json:"Names"`
+	Image  string   `json:"Image"`
+	State  string   `json:"State"`
+	Status string   `json:"Status"`
+}
+
+// dockerInspect is the subset of /containers/{id}/json we use.
+type dockerInspect struct {
+	RestartCount int `json:"RestartCount"`
+	State        struct {
+		Status    string `json:"Status"`
+		StartedAt string `json:"StartedAt"`
+		ExitCode  int    `json:"ExitCode"`
+		OOMKilled bool   `json:"OOMKilled"`
+		Health    *struct {
+			Status        string `json:"Status"`
+			FailingStreak int    `json:"FailingStreak"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// dockerStats is the subset of /containers/{id}/stats we use.
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs  uint32 `json:"online_cpus"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	PidsStats struct {
+		Current uint64 `json:"current"`
+	} `json:"pids_stats"`
+}
+
+// cpuSample keeps the previous CPU counters of a container so usage can be
+// computed from one-shot stats.
+type cpuSample struct {
+	total  uint64
+	system uint64
+}
+
+// DockerCollector collects container state and resource usage from the
+// Docker Engine API over a unix socket.
+type DockerCollector struct {
+	socketPath string
+	client     *http.Client
+
+	mu   sync.Mutex
+	prev map[string]cpuSample
+}
+
+// NewDockerCollector returns a DockerCollector talking to the given socket
+// on the host. An empty path defaults to DefaultDockerSocket.
+func NewDockerCollector(socketPath string) *DockerCollector {
+	if socketPath == "" {
+		socketPath = DefaultDockerSocket
+	}
+	localPath := HostPath(socketPath)
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", localPath)
+		},
+	}
+	return &DockerCollector{
+		socketPath: socketPath,
+		client:     &http.Client{Transport: transport, Timeout: 10 * time.Second},
+		prev:       make(map[string]cpuSample),
+	}
+}
+
+// Collect lists all containers with their state, restart count, health and,
+// for running containers, resource usage. If the daemon is unreachable the
+// result reports it as unavailable instead of failing the whole snapshot.
+func (d *DockerCollector) Collect() (interface{}, error) {
+	var containers []dockerContainer
+	if err := d.get("/containers/json?all=1", &containers); err != nil {
+		return map[string]interface{}{
+			"available": false,
+			"socket":    d.socketPath,
+			"error":     err.Error(),
+		}, nil
+	}
+
+	results := make([]map[string]interface{}, 0, len(containers))
+	seen := make(map[string]bool, len(containers))
+	states := map[string]int{}
+	for _, c := range containers {
+		seen[c.ID] = true
+		states[c.State]++
+		entry := map[string]interface{}{
+			"id":     c.ID,
+			"name":   containerName(c.Names),
+			"image":  c.Image,
+			"state":  c.State,
+			"status": c.Status,
+		}
+
+		var inspect dockerInspect
+		if err := d.get("/containers/"+url.PathEscape(c.ID)+"/json", &inspect); err == nil {
+			entry["restartCount"] = inspect.RestartCount
+			entry["exitCode"] = inspect.State.ExitCode
+			entry["oomKilled"] = inspect.State.OOMKilled
+			entry["startedAt"] = inspect.State.StartedAt
+			if inspect.State.Health != nil {
+				entry["health"] = inspect.State.Health.Status
+				entry["failingStreak"] = inspect.State.Health.FailingStreak
+			}
+		}
+
+		if c.State == "running" {
+			var stats dockerStats
+			if err := d.get("/containers/"+url.PathEscape(c.ID)+"/stats?stream=false&one-shot=true", &stats); err == nil {
+				entry["stats"] = d.statsMap(c.ID, stats)
+			}
+		}
+		results = append(results, entry)
+	}
+	d.forget(seen)
+
+	return map[string]interface{}{
+		"available":  true,
+		"socket":     d.socketPath,
+		"states":     states,
+		"containers": results,
+	}, nil
+}
+
+// get performs a GET against the Docker API and decodes the JSON response.
+func (d *DockerCollector) get(path string, out interface{}) error {
+	// The host part is ignored because the transport always dials the socket.
+	resp, err := d.client.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker API %s returned status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statsMap converts raw stats into a metrics map, computing CPU usage against
+// the previous sample of the same container.
+func (d *DockerCollector) statsMap(id string, s dockerStats) map[string]interface{} {
+	// Page cache is reclaimable, so report usage without it like `docker stats`.
+	memUsed := s.MemoryStats.Usage
+	if cache, ok := s.MemoryStats.Stats["inactive_file"]; ok && cache < memUsed {
+		memUsed -= cache
+	} else if cache, ok := s.MemoryStats.Stats["cache"]; ok && cache < memUsed {
+		memUsed -= cache
+	}
+	var rx, tx uint64
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+
+	out := map[string]interface{}{
+		"memoryUsed":  memUsed,
+		"memoryLimit": s.MemoryStats.Limit,
+		"netRxBytes":  rx,
+		"netTxBytes":  tx,
+		"pids":        s.PidsStats.Current,
+	}
+	if s.MemoryStats.Limit > 0 {
+		out["memoryPercent"] = float64(memUsed) / float64(s.MemoryStats.Limit) * 100
+	}
+
+	cur := cpuSample{total: s.CPUStats.CPUUsage.TotalUsage, system: s.CPUStats.SystemUsage}
+	d.mu.Lock()
+	prev, ok := d.prev[id]
+	d.prev[id] = cur
+	d.mu.Unlock()
+	if ok && cur.system > prev.system && cur.total >= prev.total {
+		cpus := float64(s.CPUStats.OnlineCPUs)
+		if cpus == 0 {
+			cpus = 1
+		}
+		out["cpuPercent"] = float64(cur.total-prev.total) / float64(cur.system-prev.system) * cpus * 100
+	}
+	return out
+}
+
+// forget drops CPU samples of containers that no longer exist.
+func (d *DockerCollector) forget(seen map[string]bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id := range d.prev {
+		if !seen[id] {
+			delete(d.prev, id)
+		}
+	}
+}
+
+// containerName returns the primary name of a container without the leading slash.
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
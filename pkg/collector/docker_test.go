@@ -0,0 +1,124 @@
+// pkg/collector/docker_test.go
+
+package collector
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDocker serves a Docker Engine API on a unix socket with one running
+// and one exited container. Each stats request reports more CPU time.
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	// Unix socket paths are limited to about 100 bytes, too short for t.TempDir.
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var statsCalls atomic.Uint64
+	reply := func(w http.ResponseWriter, v interface{}) { json.NewEncoder(w).Encode(v) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("all") != "1" {
+			t.Errorf("containers listed without all=1: %s", r.URL)
+		}
+		w.Write([]byte(`[
+			{"Id": "web1", "Names": ["/web"], "Image": "nginx", "State": "running", "Status": "Up 2 hours"},
+			{"Id": "job1", "Names": ["/job"], "Image": "busybox", "State": "exited", "Status": "Exited (1)"}
+		]`))
+	})
+	mux.HandleFunc("/containers/web1/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"RestartCount": 3, "State": {"Status": "running", "StartedAt": "2024-01-01T00:00:00Z",
+			"Health": {"Status": "healthy", "FailingStreak": 0}}}`))
+	})
+	mux.HandleFunc("/containers/job1/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"State": {"Status": "exited", "ExitCode": 1, "OOMKilled": true}}`))
+	})
+	mux.HandleFunc("/containers/web1/stats", func(w http.ResponseWriter, r *http.Request) {
+		n := statsCalls.Add(1)
+		s := dockerStats{}
+		s.CPUStats.CPUUsage.TotalUsage = n * 100
+		s.CPUStats.SystemUsage = n * 1000
+		s.CPUStats.OnlineCPUs = 2
+		s.MemoryStats.Usage = 300
+		s.MemoryStats.Limit = 1000
+		s.MemoryStats.Stats = map[string]uint64{"inactive_file": 100}
+		s.PidsStats.Current = 4
+		reply(w, s)
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return socket
+}
+
+func TestDockerCollector(t *testing.T) {
+	d := NewDockerCollector(fakeDocker(t))
+	collect := func() map[string]interface{} {
+		t.Helper()
+		v, err := d.Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := v.(map[string]interface{})
+		if res["available"] != true {
+			t.Fatalf("Collect = %v", res)
+		}
+		return res
+	}
+
+	res := collect()
+	if states := res["states"].(map[string]int); states["running"] != 1 || states["exited"] != 1 {
+		t.Errorf("states = %v", states)
+	}
+	containers := res["containers"].([]map[string]interface{})
+	if len(containers) != 2 {
+		t.Fatalf("containers = %v", containers)
+	}
+	web, job := containers[0], containers[1]
+	if web["name"] != "web" || web["restartCount"] != 3 || web["health"] != "healthy" {
+		t.Errorf("web = %v", web)
+	}
+	if job["exitCode"] != 1 || job["oomKilled"] != true || job["stats"] != nil {
+		t.Errorf("job = %v", job)
+	}
+	stats := web["stats"].(map[string]interface{})
+	if stats["memoryUsed"] != uint64(200) || stats["memoryPercent"] != 20.0 || stats["pids"] != uint64(4) {
+		t.Errorf("stats = %v", stats)
+	}
+	// CPU usage needs a previous sample.
+	if _, ok := stats["cpuPercent"]; ok {
+		t.Errorf("cpuPercent reported from a single sample: %v", stats)
+	}
+	stats = collect()["containers"].([]map[string]interface{})[0]["stats"].(map[string]interface{})
+	if stats["cpuPercent"] != 20.0 {
+		t.Errorf("cpuPercent = %v, want 20", stats["cpuPercent"])
+	}
+}
+
+func TestDockerCollectorUnavailable(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	v, err := NewDockerCollector(socket).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := v.(map[string]interface{})
+	if res["available"] != false || res["socket"] != socket || res["error"] == nil {
+		t.Errorf("Collect = %v, want an unavailable result", res)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+
+// pkg/collector/fileinfo_unix.go
+
+package collector
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file across hard links.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// deviceOf returns the device a file lives on.
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// fileIDOf returns the identity of a file that has more than one hard link.
+func fileIDOf(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink <= 1 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// diskUsageOf returns the space allocated to a file, like du does.
+func diskUsageOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Blocks) * 512
+	}
+	return uint64(info.Size())
+}
+
+// ownerOf returns the user and group owning a file.
+func ownerOf(info os.FileInfo) (uint32, uint32) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Uid, st.Gid
+	}
+	return 0, 0
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+// pkg/collector/fileinfo_windows.go
+
+package collector
+
+import "os"
+
+// fileID identifies a file across hard links.
+type fileID struct{}
+
+// deviceOf is not supported on Windows; scans do not check filesystem boundaries.
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileIDOf is not supported on Windows; hard links are counted once per path.
+func fileIDOf(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// diskUsageOf returns the apparent size of a file.
+func diskUsageOf(info os.FileInfo) uint64 {
+	return uint64(info.Size())
+}
+
+// ownerOf is not supported on Windows and always reports root ownership.
+func ownerOf(info os.FileInfo) (uint32, uint32) {
+	return 0, 0
+}
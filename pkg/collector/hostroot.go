@@ -0,0 +1,248 @@
+// pkg/collector/hostroot.go
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HostRoots holds the locations of the host's filesystems as seen by the
+// agent. When the agent runs in a container with the host filesystem
+// mounted (for example at /host), these point into that mount so that
+// collectors report on the host rather than the container.
+type HostRoots struct {
+	Root string `json:"root"`
+	Proc string `json:"proc"`
+	Sys  string `json:"sys"`
+	Etc  string `json:"etc"`
+	Run  string `json:"run"`
+	Var  string `json:"var"`
+}
+
+// Environment variables overriding the host roots. The per-directory names
+// match the ones gopsutil reads, so both stay in agreement.
+const (
+	EnvHostRoot = "HOST_ROOT"
+	EnvHostProc = "HOST_PROC"
+	EnvHostSys  = "HOST_SYS"
+	EnvHostEtc  = "HOST_ETC"
+	EnvHostRun  = "HOST_RUN"
+	EnvHostVar  = "HOST_VAR"
+)
+
+var (
+	hostRootsMu sync.RWMutex
+	hostRoots   = DerivedHostRoots("/")
+)
+
+// DerivedHostRoots returns roots for a host filesystem mounted at root.
+func DerivedHostRoots(root string) HostRoots {
+	if root == "" {
+		root = "/"
+	}
+	return HostRoots{
+		Root: root,
+		Proc: filepath.Join(root, "proc"),
+		Sys:  filepath.Join(root, "sys"),
+		Etc:  filepath.Join(root, "etc"),
+		Run:  filepath.Join(root, "run"),
+		Var:  filepath.Join(root, "var"),
+	}
+}
+
+// ResolveHostRoots combines configured roots with environment overrides.
+// Precedence, highest first: a per-directory environment variable, the
+// per-directory configured value, then a value derived from HOST_ROOT or
+// the configured Root.
+func ResolveHostRoots(configured HostRoots) HostRoots {
+	root := configured.Root
+	if v := os.Getenv(EnvHostRoot); v != "" {
+		root = v
+	}
+	r := DerivedHostRoots(root)
+	pick := func(dst *string, cfg, env string) {
+		if cfg != "" {
+			*dst = cfg
+		}
+		if v := os.Getenv(env); v != "" {
+			*dst = v
+		}
+	}
+	pick(&r.Proc, configured.Proc, EnvHostProc)
+	pick(&r.Sys, configured.Sys, EnvHostSys)
+	pick(&r.Etc, configured.Etc, EnvHostEtc)
+	pick(&r.Run, configured.Run, EnvHostRun)
+	pick(&r.Var, configured.Var, EnvHostVar)
+	return r
+}
+
+// SetHostRoots sets the roots used by all collectors. It also exports them
+// to the environment so gopsutil-based collectors read the same locations.
+func SetHostRoots(r HostRoots) {
+	hostRootsMu.Lock()
+	hostRoots = r
+	hostRootsMu.Unlock()
+	os.Setenv(EnvHostProc, r.Proc)
+	os.Setenv(EnvHostSys, r.Sys)
+	os.Setenv(EnvHostEtc, r.Etc)
+	os.Setenv(EnvHostRun, r.Run)
+	os.Setenv(EnvHostVar, r.Var)
+}
+
+// CurrentHostRoots returns the roots in use.
+func CurrentHostRoots() HostRoots {
+	hostRootsMu.RLock()
+	defer hostRootsMu.RUnlock()
+	return hostRoots
+}
+
+// HostProc joins parts onto the host's /proc.
+func HostProc(parts ...string) string {
+	return filepath.Join(append([]string{CurrentHostRoots().Proc}, parts...)...)
+}
+
+// HostSys joins parts onto the host's /sys.
+func HostSys(parts ...string) string {
+	return filepath.Join(append([]string{CurrentHostRoots().Sys}, parts...)...)
+}
+
+// hostMapping pairs a host directory with where it is visible to the agent.
+type hostMapping struct {
+	host  string
+	local string
+}
+
+// mappings returns the directory mappings, most specific first.
+func (r HostRoots) mappings() []hostMapping {
+	m := []hostMapping{
+		{"/proc", r.Proc},
+		{"/sys", r.Sys},
+		{"/etc", r.Etc},
+		{"/run", r.Run},
+		{"/var/run", r.Run},
+		{"/var", r.Var},
+		{"/", r.Root},
+	}
+	sort.SliceStable(m, func(i, j int) bool { return len(m[i].host) > len(m[j].host) })
+	return m
+}
+
+// HostPath translates an absolute path on the host into the path where the
+// agent can read it. Relative paths are returned unchanged.
+func HostPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	path = filepath.Clean(path)
+	for _, m := range CurrentHostRoots().mappings() {
+		if rel, ok := underDir(path, m.host); ok {
+			return filepath.Join(m.local, rel)
+		}
+	}
+	return path
+}
+
+// UnhostPath is the inverse of HostPath: it turns a path read by the agent
+// back into the path as seen on the host, for reporting.
+func UnhostPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	path = filepath.Clean(path)
+	best := ""
+	var bestMapping hostMapping
+	for _, m := range CurrentHostRoots().mappings() {
+		if _, ok := underDir(path, m.local); ok && len(m.local) > len(best) {
+			best, bestMapping = m.local, m
+		}
+	}
+	if best == "" {
+		return path
+	}
+	rel, _ := underDir(path, bestMapping.local)
+	return filepath.Join(bestMapping.host, rel)
+}
+
+// underDir reports whether path is dir or below it, returning the relative rest.
+func underDir(path, dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	if path == dir {
+		return "", true
+	}
+	if dir == "/" {
+		return strings.TrimPrefix(path, "/"), true
+	}
+	if strings.HasPrefix(path, dir+"/") {
+		return path[len(dir)+1:], true
+	}
+	return "", false
+}
+
+// ContainerInfo describes whether the agent process itself is containerized.
+type ContainerInfo struct {
+	Containerized bool   `json:"containerized"`
+	Runtime       string `json:"runtime,omitempty"`
+	ContainerID   string `json:"containerId,omitempty"`
+}
+
+// DetectContainer checks whether the agent runs inside a container. It
+// deliberately inspects the agent's own filesystem and /proc/self rather
+// than the host roots.
+func DetectContainer() ContainerInfo {
+	return detectContainer("/", os.Getenv)
+}
+
+// detectContainer implements DetectContainer against a filesystem root and
+// environment lookup so it can be exercised with fixture trees.
+func detectContainer(root string, getenv func(string) string) ContainerInfo {
+	exists := func(p string) bool {
+		_, err := os.Stat(filepath.Join(root, p))
+		return err == nil
+	}
+
+	info := ContainerInfo{}
+	if data, err := os.ReadFile(filepath.Join(root, "proc", "self", "cgroup")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			parts := strings.SplitN(line, ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			if c := ParseCgroupPath(parts[2]); c.ContainerID != "" {
+				info = ContainerInfo{Containerized: true, Runtime: c.Runtime, ContainerID: c.ContainerID}
+				break
+			}
+		}
+	}
+
+	switch {
+	case exists(".dockerenv"):
+		info.Containerized = true
+		if info.Runtime == "" {
+			info.Runtime = "docker"
+		}
+	case exists("run/.containerenv"):
+		info.Containerized = true
+		if info.Runtime == "" {
+			info.Runtime = "podman"
+		}
+	case getenv("container") != "":
+		// Set by systemd-nspawn, podman and LXC.
+		info.Containerized = true
+		if info.Runtime == "" {
+			info.Runtime = getenv("container")
+		}
+	case getenv("KUBERNETES_SERVICE_HOST") != "":
+		info.Containerized = true
+		if info.Runtime == "" {
+			info.Runtime = "kubernetes"
+		}
+	}
+	if info.Containerized && info.Runtime == "" {
+		info.Runtime = "unknown"
+	}
+	return info
+}
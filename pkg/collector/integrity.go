@@ -0,0 +1,326 @@
+// pkg/collector/integrity.go
+
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileRecord captures the attributes of a monitored file.
+type FileRecord struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	UID     uint32      `json:"uid"`
+	GID     uint32      `json:"gid"`
+	ModTime time.Time   `json:"modTime"`
+	SHA256  string      `json:"sha256,omitempty"`
+	Target  string      `json:"target,omitempty"` // Symlink target.
+}
+
+// IntegrityChange describes a difference between the baseline and a scan.
+type IntegrityChange struct {
+	Path   string      `json:"path"`
+	Type   string      `json:"type"`             // "added", "removed" or "modified".
+	Fields []string    `json:"fields,omitempty"` // Changed attributes for "modified".
+	Old    *FileRecord `json:"old,omitempty"`
+	New    *FileRecord `json:"new,omitempty"`
+}
+
+// integrityBaseline is the on-disk baseline format.
+type integrityBaseline struct {
+	CreatedAt time.Time              `json:"createdAt"`
+	Files     map[string]*FileRecord `json:"files"`
+}
+
+// IntegrityMonitor baselines configured files and directories and reports
+// additions, deletions and modifications on subsequent scans. The baseline
+// is persisted to disk so changes made while the agent was down are detected.
+// It is created by the first scan and only replaced by Rebaseline, so drift
+// is reported on every scan until an operator accepts it.
+type IntegrityMonitor struct {
+	paths        []string
+	baselinePath string
+	maxHashBytes int64
+
+	mu       sync.Mutex
+	baseline *integrityBaseline
+	loadedAt time.Time // Modification time of the baseline file when loaded.
+}
+
+// NewIntegrityMonitor returns a monitor for the given paths storing its
+// baseline at baselinePath. Files above maxHashBytes are not hashed.
+func NewIntegrityMonitor(paths []string, baselinePath string, maxHashBytes int64) *IntegrityMonitor {
+	return &IntegrityMonitor{
+		paths:        paths,
+		baselinePath: baselinePath,
+		maxHashBytes: maxHashBytes,
+	}
+}
+
+// Collect scans the monitored paths and compares them to the baseline. The
+// first scan, when there is no baseline yet, becomes the baseline. Paths
+// that could not be read are reported as errors, not as removed.
+func (m *IntegrityMonitor) Collect() (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refreshBaseline(); err != nil {
+		return map[string]interface{}{
+			"available": false,
+			"baseline":  m.baselinePath,
+			"error":     err.Error(),
+		}, nil
+	}
+
+	current, failed, errs := m.scan()
+	result := map[string]interface{}{
+		"files":  len(current),
+		"errors": errs,
+	}
+
+	if m.baseline == nil {
+		if err := m.saveBaseline(&integrityBaseline{CreatedAt: time.Now(), Files: current}); err != nil {
+			// Retried on the next scan.
+			result["available"] = false
+			result["baseline"] = m.baselinePath
+			result["error"] = "saving baseline: " + err.Error()
+			return result, nil
+		}
+		result["baselineCreated"] = true
+		result["changes"] = []IntegrityChange{}
+		return result, nil
+	}
+	keepFailed(m.baseline.Files, current, failed)
+	result["changes"] = diffRecords(m.baseline.Files, current)
+	result["baselineCreatedAt"] = m.baseline.CreatedAt
+	return result, nil
+}
+
+// Rebaseline scans the monitored paths and replaces the baseline with the
+// result, accepting every change reported so far. Paths that could not be
+// read keep their previous baseline records. It returns the number of
+// files in the new baseline and any per-file errors.
+func (m *IntegrityMonitor) Rebaseline() (int, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, failed, errs := m.scan()
+	// An unreadable baseline is replaced without carrying anything forward.
+	if m.refreshBaseline() == nil && m.baseline != nil {
+		keepFailed(m.baseline.Files, current, failed)
+	}
+	if err := m.saveBaseline(&integrityBaseline{CreatedAt: time.Now(), Files: current}); err != nil {
+		return 0, errs, err
+	}
+	return len(current), errs, nil
+}
+
+// refreshBaseline loads the persisted baseline when it has not been loaded
+// or the file was replaced, for example by another process re-baselining.
+// The caller holds m.mu.
+func (m *IntegrityMonitor) refreshBaseline() error {
+	info, err := os.Stat(m.baselinePath)
+	if os.IsNotExist(err) {
+		m.baseline = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if m.baseline != nil && info.ModTime().Equal(m.loadedAt) {
+		return nil
+	}
+	b, err := m.loadBaseline()
+	if err != nil {
+		return err
+	}
+	m.baseline, m.loadedAt = b, info.ModTime()
+	return nil
+}
+
+// scan walks all monitored paths and returns the records keyed by path,
+// the paths that could not be read and the errors encountered. A path that
+// no longer exists is not an error; it was removed.
+func (m *IntegrityMonitor) scan() (map[string]*FileRecord, []string, []string) {
+	records := make(map[string]*FileRecord)
+	var failed []string
+	errs := []string{}
+	for _, root := range m.paths {
+		localRoot := HostPath(root)
+		err := filepath.WalkDir(localRoot, func(local string, d fs.DirEntry, err error) error {
+			// Record paths as they appear on the host.
+			path := root
+			if rel, err := filepath.Rel(localRoot, local); err == nil {
+				path = filepath.Join(root, rel)
+			}
+			if err == nil {
+				var rec *FileRecord
+				if rec, err = m.record(local, path, d); err == nil {
+					records[path] = rec
+					return nil
+				}
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				// A directory that cannot be listed fails with its entries.
+				failed = append(failed, path)
+				errs = append(errs, err.Error())
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return records, failed, errs
+}
+
+// keepFailed copies into cur the baseline records of the failed paths and
+// of everything below them that cur lacks, so that files which could not
+// be read are not reported as removed.
+func keepFailed(base, cur map[string]*FileRecord, failed []string) {
+	for _, f := range failed {
+		prefix := strings.TrimSuffix(f, string(filepath.Separator)) + string(filepath.Separator)
+		for path, rec := range base {
+			if _, ok := cur[path]; !ok && (path == f || strings.HasPrefix(path, prefix)) {
+				cur[path] = rec
+			}
+		}
+	}
+}
+
+// record builds the FileRecord for the host path, read from local.
+func (m *IntegrityMonitor) record(local, path string, d fs.DirEntry) (*FileRecord, error) {
+	info, err := d.Info()
+	if err != nil {
+		return nil, err
+	}
+	rec := &FileRecord{
+		Path:    path,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime().UTC(),
+	}
+	rec.UID, rec.GID = ownerOf(info)
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		if rec.Target, err = os.Readlink(local); err != nil {
+			return nil, err
+		}
+	case info.Mode().IsRegular() && (m.maxHashBytes <= 0 || info.Size() <= m.maxHashBytes):
+		if rec.SHA256, err = hashFile(local); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// diffRecords compares two scans and returns the changes sorted by path.
+func diffRecords(old, cur map[string]*FileRecord) []IntegrityChange {
+	changes := []IntegrityChange{}
+	for path, n := range cur {
+		o, ok := old[path]
+		if !ok {
+			changes = append(changes, IntegrityChange{Path: path, Type: "added", New: n})
+			continue
+		}
+		if fields := changedFields(o, n); len(fields) > 0 {
+			changes = append(changes, IntegrityChange{Path: path, Type: "modified", Fields: fields, Old: o, New: n})
+		}
+	}
+	for path, o := range old {
+		if _, ok := cur[path]; !ok {
+			changes = append(changes, IntegrityChange{Path: path, Type: "removed", Old: o})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// changedFields lists the attributes that differ between two records.
+func changedFields(o, n *FileRecord) []string {
+	var fields []string
+	if o.SHA256 != n.SHA256 {
+		fields = append(fields, "sha256")
+	}
+	if o.Size != n.Size {
+		fields = append(fields, "size")
+	}
+	if o.Mode != n.Mode {
+		fields = append(fields, "mode")
+	}
+	if o.UID != n.UID || o.GID != n.GID {
+		fields = append(fields, "owner")
+	}
+	if o.Target != n.Target {
+		fields = append(fields, "target")
+	}
+	// Directory mtimes change whenever an entry is added or removed, which
+	// is already reported for the entry itself.
+	if !n.Mode.IsDir() && !o.ModTime.Equal(n.ModTime) {
+		fields = append(fields, "modTime")
+	}
+	return fields
+}
+
+// loadBaseline reads the persisted baseline.
+func (m *IntegrityMonitor) loadBaseline() (*integrityBaseline, error) {
+	data, err := os.ReadFile(m.baselinePath)
+	if err != nil {
+		return nil, err
+	}
+	var b integrityBaseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// saveBaseline atomically writes b to disk and makes it the baseline. The
+// caller holds m.mu.
+func (m *IntegrityMonitor) saveBaseline(b *integrityBaseline) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.baselinePath), 0700); err != nil {
+		return err
+	}
+	tmp := m.baselinePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.baselinePath); err != nil {
+		return err
+	}
+	m.baseline = b
+	if info, err := os.Stat(m.baselinePath); err == nil {
+		m.loadedAt = info.ModTime()
+	}
+	return nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of a file's contents.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,105 @@
+// pkg/collector/integrity_test.go
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func collectIntegrity(t *testing.T, m *IntegrityMonitor) map[string]interface{} {
+	t.Helper()
+	v, err := m.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	return v.(map[string]interface{})
+}
+
+func TestIntegrityReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "etc")
+	os.Mkdir(watched, 0755)
+	os.WriteFile(filepath.Join(watched, "a.conf"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(watched, "b.conf"), []byte("b"), 0644)
+	m := NewIntegrityMonitor([]string{watched}, filepath.Join(dir, "baseline.json"), 0)
+
+	if res := collectIntegrity(t, m); res["baselineCreated"] != true {
+		t.Fatalf("first scan = %v, want a new baseline", res)
+	}
+	os.WriteFile(filepath.Join(watched, "a.conf"), []byte("changed"), 0644)
+	os.Remove(filepath.Join(watched, "b.conf"))
+	os.WriteFile(filepath.Join(watched, "c.conf"), []byte("c"), 0644)
+
+	changes := collectIntegrity(t, m)["changes"].([]IntegrityChange)
+	want := map[string]string{"a.conf": "modified", "b.conf": "removed", "c.conf": "added"}
+	got := map[string]string{}
+	for _, c := range changes {
+		if c.Path != watched {
+			got[filepath.Base(c.Path)] = c.Type
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %+v, want %v", changes, want)
+	}
+	for name, typ := range want {
+		if got[name] != typ {
+			t.Errorf("%s: change %q, want %q", name, got[name], typ)
+		}
+	}
+
+	// Changes are reported until they are accepted.
+	if n := len(collectIntegrity(t, m)["changes"].([]IntegrityChange)); n == 0 {
+		t.Error("changes were not reported again before Rebaseline")
+	}
+	if _, _, err := m.Rebaseline(); err != nil {
+		t.Fatalf("Rebaseline: %v", err)
+	}
+	if changes := collectIntegrity(t, m)["changes"].([]IntegrityChange); len(changes) != 0 {
+		t.Errorf("changes after Rebaseline = %+v", changes)
+	}
+}
+
+func TestIntegrityCorruptBaseline(t *testing.T) {
+	dir := t.TempDir()
+	baseline := filepath.Join(dir, "baseline.json")
+	os.WriteFile(baseline, []byte("{"), 0600)
+	m := NewIntegrityMonitor([]string{dir}, baseline, 0)
+	res := collectIntegrity(t, m)
+	if res["available"] != false || res["error"] == nil {
+		t.Errorf("Collect = %v, want an unavailable result with an error", res)
+	}
+}
+
+func TestIntegrityUnwritableBaseline(t *testing.T) {
+	dir := t.TempDir()
+	// A file where the baseline's directory should be.
+	blocker := filepath.Join(dir, "state")
+	os.WriteFile(blocker, nil, 0600)
+	m := NewIntegrityMonitor([]string{dir}, filepath.Join(blocker, "baseline.json"), 0)
+	res := collectIntegrity(t, m)
+	if res["available"] != false || res["error"] == nil {
+		t.Errorf("Collect = %v, want an unavailable result with an error", res)
+	}
+}
+
+func TestKeepFailed(t *testing.T) {
+	base := map[string]*FileRecord{
+		"/etc":             {Path: "/etc"},
+		"/etc/ssh":         {Path: "/etc/ssh"},
+		"/etc/ssh/sshd":    {Path: "/etc/ssh/sshd"},
+		"/etc/shadow":      {Path: "/etc/shadow"},
+		"/etc/sshd-backup": {Path: "/etc/sshd-backup"},
+	}
+	// /etc/shadow could not be hashed and /etc/ssh could not be listed.
+	cur := map[string]*FileRecord{
+		"/etc":     {Path: "/etc"},
+		"/etc/ssh": {Path: "/etc/ssh"},
+	}
+	keepFailed(base, cur, []string{"/etc/shadow", "/etc/ssh"})
+	changes := diffRecords(base, cur)
+	if len(changes) != 1 || changes[0].Path != "/etc/sshd-backup" || changes[0].Type != "removed" {
+		t.Errorf("changes = %+v, want only /etc/sshd-backup removed", changes)
+	}
+}
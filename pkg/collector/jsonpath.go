@@ -0,0 +1,161 @@
+// pkg/collector/jsonpath.go
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// selectorStep is one element of a parsed JSON selector.
+type selectorStep struct {
+	key      string // Object key, when index < 0 and !wildcard.
+	index    int    // Array index, or -1.
+	wildcard bool   // Matches every key or element.
+}
+
+// JSONSelector is a compiled JSONPath-like expression. It supports dotted
+// keys ("$.memstats.HeapAlloc"), quoted keys ("$['my.key']"), array indexes
+// ("$.items[0]") and wildcards over objects or arrays ("$.queues.*.depth",
+// "$.items[*].size").
+type JSONSelector struct {
+	expr  string
+	steps []selectorStep
+}
+
+// JSONMatch is a value selected from a document. Captures holds the keys or
+// indexes matched by each wildcard, in order.
+type JSONMatch struct {
+	Value    interface{}
+	Captures []string
+}
+
+// CompileJSONSelector parses a selector expression. The leading "$" is optional.
+func CompileJSONSelector(expr string) (*JSONSelector, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+	sel := &JSONSelector{expr: expr}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("selector %q: empty key", expr)
+			}
+			if name == "*" {
+				sel.steps = append(sel.steps, selectorStep{index: -1, wildcard: true})
+			} else {
+				sel.steps = append(sel.steps, selectorStep{key: name, index: -1})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("selector %q: unterminated bracket", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				sel.steps = append(sel.steps, selectorStep{index: -1, wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				sel.steps = append(sel.steps, selectorStep{key: inner[1 : len(inner)-1], index: -1})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("selector %q: invalid index %q", expr, inner)
+				}
+				sel.steps = append(sel.steps, selectorStep{index: n})
+			}
+		default:
+			// Allow a bare leading key such as "memstats.HeapAlloc".
+			s = "." + s
+		}
+	}
+	return sel, nil
+}
+
+// String returns the original expression.
+func (sel *JSONSelector) String() string {
+	return sel.expr
+}
+
+// Select returns every value in doc matched by the selector.
+func (sel *JSONSelector) Select(doc interface{}) []JSONMatch {
+	matches := []JSONMatch{{Value: doc}}
+	for _, step := range sel.steps {
+		var next []JSONMatch
+		for _, m := range matches {
+			switch v := m.Value.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, JSONMatch{Value: v[k], Captures: appendCapture(m.Captures, k)})
+					}
+				} else if child, ok := v[step.key]; ok && step.index < 0 {
+					next = append(next, JSONMatch{Value: child, Captures: m.Captures})
+				}
+			case []interface{}:
+				if step.wildcard {
+					for i, child := range v {
+						next = append(next, JSONMatch{Value: child, Captures: appendCapture(m.Captures, strconv.Itoa(i))})
+					}
+				} else if step.index >= 0 && step.index < len(v) {
+					next = append(next, JSONMatch{Value: v[step.index], Captures: m.Captures})
+				}
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// appendCapture returns a copy of captures with c appended.
+func appendCapture(captures []string, c string) []string {
+	out := make([]string, len(captures), len(captures)+1)
+	copy(out, captures)
+	return append(out, c)
+}
+
+// numericValue converts a decoded JSON value into a float. Booleans map to
+// 0 and 1 and numeric strings are parsed. NaN and infinities, which strings
+// such as "NaN" and "Inf" parse to, are rejected because snapshots cannot
+// encode them as JSON.
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil && isFinite(f)
+	case float64:
+		return n, isFinite(n)
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil && isFinite(f)
+	}
+	return 0, false
+}
+
+// isFinite reports whether f is neither NaN nor an infinity.
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
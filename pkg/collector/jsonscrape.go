@@ -0,0 +1,181 @@
+// pkg/collector/jsonscrape.go
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// JSONMetricSpec maps a selector in a JSON document to a named metric.
+type JSONMetricSpec struct {
+	Name     string
+	Selector string
+	Labels   map[string]string
+	// WildcardLabels names the labels holding the keys or indexes matched
+	// by each wildcard in the selector. Unnamed wildcards use "key", "key2", ...
+	WildcardLabels []string
+}
+
+// JSONScrapeTarget is an HTTP endpoint returning JSON, such as expvar's
+// /debug/vars, and the metrics to extract from it.
+type JSONScrapeTarget struct {
+	Name    string
+	URL     string
+	Labels  map[string]string
+	Headers map[string]string
+	Timeout time.Duration // Defaults to 5 seconds.
+	Metrics []JSONMetricSpec
+}
+
+// compiledJSONMetric is a JSONMetricSpec with its selector parsed.
+type compiledJSONMetric struct {
+	spec     JSONMetricSpec
+	selector *JSONSelector
+}
+
+// compiledJSONTarget is a JSONScrapeTarget with its selectors parsed.
+type compiledJSONTarget struct {
+	target  JSONScrapeTarget
+	metrics []compiledJSONMetric
+}
+
+// JSONMetric is a single extracted value.
+type JSONMetric struct {
+	Name   string            `json:"name"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// JSONScrapeCollector fetches JSON documents from configured URLs and
+// extracts numeric fields into named metrics.
+type JSONScrapeCollector struct {
+	targets []compiledJSONTarget
+	client  *http.Client
+}
+
+// maxJSONScrapeBytes caps the size of a scraped document.
+const maxJSONScrapeBytes = 8 << 20
+
+// NewJSONScrapeCollector compiles the targets' selectors and returns a
+// collector. It fails if any selector is invalid.
+func NewJSONScrapeCollector(targets []JSONScrapeTarget) (*JSONScrapeCollector, error) {
+	c := &JSONScrapeCollector{client: &http.Client{}}
+	for _, t := range targets {
+		if t.Timeout <= 0 {
+			t.Timeout = 5 * time.Second
+		}
+		ct := compiledJSONTarget{target: t}
+		for _, m := range t.Metrics {
+			sel, err := CompileJSONSelector(m.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("scraper %s metric %s: %v", t.Name, m.Name, err)
+			}
+			ct.metrics = append(ct.metrics, compiledJSONMetric{spec: m, selector: sel})
+		}
+		c.targets = append(c.targets, ct)
+	}
+	return c, nil
+}
+
+// Collect scrapes every target. A failing target is reported with up=false
+// rather than failing the whole collection.
+func (c *JSONScrapeCollector) Collect() (interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(c.targets))
+	for _, t := range c.targets {
+		start := time.Now()
+		entry := map[string]interface{}{
+			"name": t.target.Name,
+			"url":  t.target.URL,
+		}
+		metrics, err := c.scrape(t)
+		entry["durationMs"] = float64(time.Since(start).Microseconds()) / 1000
+		entry["up"] = err == nil
+		if err != nil {
+			entry["error"] = err.Error()
+		} else {
+			entry["metrics"] = metrics
+		}
+		results = append(results, entry)
+	}
+	return results, nil
+}
+
+// scrape fetches one target and extracts its metrics.
+func (c *JSONScrapeCollector) scrape(t compiledJSONTarget) ([]JSONMetric, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.target.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.target.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range t.target.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxJSONScrapeBytes))
+	if err != nil {
+		return nil, err
+	}
+	return extractJSONMetrics(body, t.target.Labels, t.metrics)
+}
+
+// extractJSONMetrics decodes a JSON document and applies the compiled metric
+// selectors to it. Non-numeric matches are skipped.
+func extractJSONMetrics(body []byte, targetLabels map[string]string, metrics []compiledJSONMetric) ([]JSONMetric, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %v", err)
+	}
+
+	out := []JSONMetric{}
+	for _, m := range metrics {
+		for _, match := range m.selector.Select(doc) {
+			v, ok := numericValue(match.Value)
+			if !ok {
+				continue
+			}
+			labels := map[string]string{}
+			for k, v := range targetLabels {
+				labels[k] = v
+			}
+			for k, v := range m.spec.Labels {
+				labels[k] = v
+			}
+			for i, capture := range match.Captures {
+				labels[wildcardLabel(m.spec.WildcardLabels, i)] = capture
+			}
+			if len(labels) == 0 {
+				labels = nil
+			}
+			out = append(out, JSONMetric{Name: m.spec.Name, Value: v, Labels: labels})
+		}
+	}
+	return out, nil
+}
+
+// wildcardLabel returns the label name for the i-th wildcard capture.
+func wildcardLabel(names []string, i int) string {
+	if i < len(names) && names[i] != "" {
+		return names[i]
+	}
+	if i == 0 {
+		return "key"
+	}
+	return fmt.Sprintf("key%d", i+1)
+}
@@ -0,0 +1,113 @@
+// pkg/collector/memcached.go
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// MemcachedCollector queries Memcached servers with the text protocol
+// "stats" command.
+type MemcachedCollector struct {
+	addresses []string
+	timeout   time.Duration
+	rates     counterRates
+}
+
+// NewMemcachedCollector returns a collector for the given host:port addresses.
+func NewMemcachedCollector(addresses []string, timeout time.Duration) *MemcachedCollector {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &MemcachedCollector{addresses: addresses, timeout: timeout}
+}
+
+// Collect queries every configured server. An unreachable server is reported
+// with up=false rather than failing the collection.
+func (m *MemcachedCollector) Collect() (interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(m.addresses))
+	for _, addr := range m.addresses {
+		entry := map[string]interface{}{"address": addr}
+		stats, err := m.stats(addr)
+		entry["up"] = err == nil
+		if err != nil {
+			entry["error"] = err.Error()
+			results = append(results, entry)
+			continue
+		}
+
+		hits := infoUint(stats, "get_hits")
+		misses := infoUint(stats, "get_misses")
+		entry["version"] = stats["version"]
+		entry["uptimeSeconds"] = infoUint(stats, "uptime")
+		entry["memory"] = map[string]interface{}{
+			"used": infoUint(stats, "bytes"),
+			"max":  infoUint(stats, "limit_maxbytes"),
+		}
+		entry["clients"] = map[string]interface{}{
+			"connected": infoUint(stats, "curr_connections"),
+			"total":     infoUint(stats, "total_connections"),
+		}
+		entry["items"] = map[string]interface{}{
+			"current":   infoUint(stats, "curr_items"),
+			"hits":      hits,
+			"misses":    misses,
+			"hitRate":   hitRate(hits, misses),
+			"evictions": infoUint(stats, "evictions"),
+		}
+
+		rates := m.rates.update(addr, time.Now(), map[string]uint64{
+			"commands":  infoUint(stats, "cmd_get") + infoUint(stats, "cmd_set"),
+			"evictions": infoUint(stats, "evictions"),
+		})
+		if v, ok := rates["commands"]; ok {
+			entry["opsPerSec"] = v
+		}
+		if v, ok := rates["evictions"]; ok {
+			entry["evictionsPerSec"] = v
+		}
+		results = append(results, entry)
+	}
+	return results, nil
+}
+
+// stats connects to addr and returns the "STAT name value" lines of a stats reply.
+func (m *MemcachedCollector) stats(addr string) (map[string]string, error) {
+	conn, err := net.DialTimeout("tcp", addr, m.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(m.timeout))
+	if _, err := conn.Write([]byte("stats\r\n")); err != nil {
+		return nil, err
+	}
+	return readMemcachedStats(bufio.NewReader(conn))
+}
+
+// readMemcachedStats reads STAT lines until END.
+func readMemcachedStats(rd *bufio.Reader) (map[string]string, error) {
+	stats := map[string]string{}
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "END":
+			return stats, nil
+		case strings.HasPrefix(line, "STAT "):
+			fields := strings.SplitN(line, " ", 3)
+			if len(fields) == 3 {
+				stats[fields[1]] = fields[2]
+			}
+		case line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR"):
+			return nil, fmt.Errorf("server error: %s", line)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// pkg/collector/memcached_test.go
+
+package collector
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMemcachedStats is an abridged stats reply.
+const testMemcachedStats = "STAT pid 1\r\nSTAT uptime 7200\r\nSTAT version 1.6.21\r\n" +
+	"STAT curr_connections 10\r\nSTAT total_connections 250\r\nSTAT cmd_get 400\r\nSTAT cmd_set 100\r\n" +
+	"STAT get_hits 300\r\nSTAT get_misses 100\r\nSTAT bytes 65536\r\nSTAT limit_maxbytes 67108864\r\n" +
+	"STAT curr_items 42\r\nSTAT evictions 3\r\nEND\r\n"
+
+// fakeMemcached answers "stats" with reply and anything else with ERROR.
+func fakeMemcached(t *testing.T, reply string) string {
+	return fakeTCPServer(t, func(rd *bufio.Reader, conn net.Conn) {
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.TrimSpace(line) == "stats" {
+				conn.Write([]byte(reply))
+			} else {
+				conn.Write([]byte("ERROR\r\n"))
+			}
+		}
+	})
+}
+
+func TestMemcachedCollector(t *testing.T) {
+	c := NewMemcachedCollector([]string{fakeMemcached(t, testMemcachedStats)}, time.Second)
+	v, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := v.([]map[string]interface{})[0]
+	if r["up"] != true || r["version"] != "1.6.21" || r["uptimeSeconds"] != uint64(7200) {
+		t.Fatalf("memcached = %v", r)
+	}
+	items := r["items"].(map[string]interface{})
+	if items["current"] != uint64(42) || items["hitRate"] != 0.75 || items["evictions"] != uint64(3) {
+		t.Errorf("items = %v", items)
+	}
+	if mem := r["memory"].(map[string]interface{}); mem["used"] != uint64(65536) || mem["max"] != uint64(67108864) {
+		t.Errorf("memory = %v", mem)
+	}
+	v, _ = c.Collect()
+	if r := v.([]map[string]interface{})[0]; r["opsPerSec"] != 0.0 {
+		t.Errorf("opsPerSec = %v", r["opsPerSec"])
+	}
+}
+
+func TestMemcachedCollectorUnavailable(t *testing.T) {
+	failing := fakeMemcached(t, "SERVER_ERROR out of memory\r\n")
+	truncated := fakeTCPServer(t, func(rd *bufio.Reader, conn net.Conn) {
+		rd.ReadString('\n')
+		conn.Write([]byte("STAT pid 1\r\n"))
+	})
+	v, err := NewMemcachedCollector([]string{failing, truncated, closedAddress(t)}, time.Second).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range v.([]map[string]interface{}) {
+		if r["up"] != false || r["error"] == nil {
+			t.Errorf("unavailable memcached = %v", r)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// pkg/collector/ntp.go
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// minNTPPoll is the shortest interval between queries to the same servers,
+// the minimum poll interval of RFC 5905.
+const minNTPPoll = 64 * time.Second
+
+// NTPResult is the outcome of one SNTP exchange.
+type NTPResult struct {
+	Offset    time.Duration // Server clock minus local clock.
+	Delay     time.Duration // Round-trip network delay.
+	Stratum   int
+	Leap      int
+	Reference string // Reference ID: an IPv4 address or a four-letter code.
+}
+
+// toNTPTime encodes t as a 64-bit NTP timestamp.
+func toNTPTime(t time.Time) uint64 {
+	nsec := uint64(t.Sub(time.Unix(-ntpEpochOffset, 0)))
+	sec := nsec / 1e9
+	frac := (nsec - sec*1e9) << 32 / 1e9
+	return sec<<32 | frac
+}
+
+// fromNTPTime decodes a 64-bit NTP timestamp.
+func fromNTPTime(v uint64) time.Time {
+	sec := int64(v >> 32)
+	nsec := int64((v & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(sec-ntpEpochOffset, nsec)
+}
+
+// QueryNTP performs an SNTP (RFC 4330) client exchange with server, given as
+// host or host:port.
+func QueryNTP(server string, timeout time.Duration) (*NTPResult, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, 48)
+	req[0] = 0<<6 | 4<<3 | 3 // LI=0, VN=4, Mode=3 (client)
+	t1 := time.Now()
+	xmit := toNTPTime(t1)
+	binary.BigEndian.PutUint64(req[40:], xmit)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, 48)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		t4 := time.Now()
+		if n < 48 {
+			continue
+		}
+		// Discard replies that do not echo our transmit timestamp.
+		if binary.BigEndian.Uint64(resp[24:]) != xmit {
+			continue
+		}
+		return parseNTPResponse(resp, t1, t4)
+	}
+}
+
+// parseNTPResponse validates a server reply and computes offset and delay
+// from the four timestamps of the exchange.
+func parseNTPResponse(resp []byte, t1, t4 time.Time) (*NTPResult, error) {
+	leap := int(resp[0] >> 6)
+	mode := resp[0] & 0x7
+	stratum := int(resp[1])
+	if mode != 4 && mode != 5 {
+		return nil, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	refID := resp[12:16]
+	if stratum == 0 {
+		return nil, fmt.Errorf("kiss-of-death from server: %s", string(refID))
+	}
+	if leap == 3 {
+		return nil, fmt.Errorf("server clock is not synchronized")
+	}
+
+	t2 := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	t3 := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	result := &NTPResult{
+		Offset:  (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:   t4.Sub(t1) - t3.Sub(t2),
+		Stratum: stratum,
+		Leap:    leap,
+	}
+	if stratum == 1 {
+		result.Reference = string(refID)
+	} else {
+		result.Reference = net.IP(refID).String()
+	}
+	return result, nil
+}
+
+// ClockSyncStatus is the kernel's view of clock discipline.
+type ClockSyncStatus struct {
+	Synchronized bool          `json:"synchronized"`
+	State        string        `json:"state"`
+	Offset       time.Duration `json:"offsetNs"`
+	MaxError     time.Duration `json:"maxErrorNs"`
+	EstError     time.Duration `json:"estErrorNs"`
+}
+
+// NTPCollector measures the local clock against NTP servers and reports the
+// kernel's synchronization status.
+type NTPCollector struct {
+	servers   []string
+	timeout   time.Duration
+	maxOffset time.Duration
+	poll      time.Duration
+
+	mu       sync.Mutex
+	polledAt time.Time
+	results  []map[string]interface{}
+	drifted  bool
+}
+
+// NewNTPCollector returns a collector for servers, queried at most once per
+// poll interval and never more often than every 64 seconds. Offsets larger
+// than maxOffset in either direction are flagged as drifted.
+func NewNTPCollector(servers []string, timeout, maxOffset, poll time.Duration) *NTPCollector {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	if poll < minNTPPoll {
+		poll = minNTPPoll
+	}
+	return &NTPCollector{servers: servers, timeout: timeout, maxOffset: maxOffset, poll: poll}
+}
+
+// Collect reports the latest server measurements, querying the servers
+// again when the poll interval has passed, and the kernel status.
+func (n *NTPCollector) Collect() (interface{}, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.results == nil || time.Since(n.polledAt) >= n.poll {
+		n.results, n.drifted = n.query()
+		n.polledAt = time.Now()
+	}
+
+	out := map[string]interface{}{
+		"servers":  n.results,
+		"drifted":  n.drifted,
+		"polledAt": n.polledAt,
+	}
+	if status, err := readClockSyncStatus(); err == nil {
+		out["kernel"] = status
+	} else {
+		out["kernelError"] = err.Error()
+	}
+	return out, nil
+}
+
+// query queries every server. Failures are reported per server rather than
+// failing the collection.
+func (n *NTPCollector) query() ([]map[string]interface{}, bool) {
+	servers := make([]map[string]interface{}, 0, len(n.servers))
+	drifted := false
+	for _, s := range n.servers {
+		entry := map[string]interface{}{"server": s}
+		r, err := QueryNTP(s, n.timeout)
+		entry["ok"] = err == nil
+		if err != nil {
+			entry["error"] = err.Error()
+			servers = append(servers, entry)
+			continue
+		}
+		entry["offsetMs"] = float64(r.Offset.Microseconds()) / 1000
+		entry["delayMs"] = float64(r.Delay.Microseconds()) / 1000
+		entry["stratum"] = r.Stratum
+		entry["leap"] = r.Leap
+		entry["reference"] = r.Reference
+		if n.maxOffset > 0 && (r.Offset > n.maxOffset || r.Offset < -n.maxOffset) {
+			drifted = true
+		}
+		servers = append(servers, entry)
+	}
+	return servers, drifted
+}
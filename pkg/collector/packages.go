@@ -0,0 +1,407 @@
+// pkg/collector/packages.go
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default locations of the package databases.
+const (
+	DefaultDpkgStatusPath   = "/var/lib/dpkg/status"
+	DefaultAPKInstalledPath = "/lib/apk/db/installed"
+	DefaultRPMDBPath        = "/var/lib/rpm"
+)
+
+// rpmDBFiles are the files of the rpm database directory that change when
+// packages are installed or removed: the sqlite database of rpm 4.16 and
+// later with its write-ahead log, and the ndb and Berkeley DB package
+// tables of older releases.
+var rpmDBFiles = []string{"rpmdb.sqlite", "rpmdb.sqlite-wal", "Packages.db", "Packages"}
+
+// maxPackageEvents bounds the number of change events kept in memory.
+const maxPackageEvents = 500
+
+// Package is a single installed package.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+	Manager string `json:"manager"`
+}
+
+// key identifies a package independently of its version.
+func (p Package) key() string {
+	return p.Manager + "/" + p.Name + "/" + p.Arch
+}
+
+// PackageEvent records a package being installed, removed or changing version.
+type PackageEvent struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"` // "installed", "removed" or "upgraded".
+	Name       string    `json:"name"`
+	Arch       string    `json:"arch"`
+	Manager    string    `json:"manager"`
+	Version    string    `json:"version,omitempty"`
+	OldVersion string    `json:"oldVersion,omitempty"`
+}
+
+// PackageCollector inventories installed packages from the dpkg, rpm and
+// apk databases. Databases are only re-read when they change, and
+// differences between scans are recorded as events.
+type PackageCollector struct {
+	dpkgStatusPath   string
+	apkInstalledPath string
+	rpmDBPath        string
+	// rpmQuery returns `rpm -qa` output in the rpmQueryFormat layout.
+	rpmQuery func() ([]byte, error)
+
+	mu        sync.Mutex
+	stamps    map[string]string
+	packages  map[string]Package
+	errors    map[string]string // Last scan error of each manager.
+	events    []PackageEvent
+	scannedAt time.Time
+}
+
+// NewPackageCollector returns a PackageCollector using the default database
+// locations on the host.
+func NewPackageCollector() *PackageCollector {
+	return NewPackageCollectorWithPaths(HostPath(DefaultDpkgStatusPath), HostPath(DefaultAPKInstalledPath), HostPath(DefaultRPMDBPath), runRPMQuery)
+}
+
+// NewPackageCollectorWithPaths returns a PackageCollector reading the given
+// database files. rpmQuery may be nil to disable rpm support.
+func NewPackageCollectorWithPaths(dpkgStatus, apkInstalled, rpmDB string, rpmQuery func() ([]byte, error)) *PackageCollector {
+	return &PackageCollector{
+		dpkgStatusPath:   dpkgStatus,
+		apkInstalledPath: apkInstalled,
+		rpmDBPath:        rpmDB,
+		rpmQuery:         rpmQuery,
+		stamps:           map[string]string{},
+		errors:           map[string]string{},
+	}
+}
+
+// Collect refreshes the inventory if any database changed and returns a
+// summary with the changes found since the previous scan. A database that
+// cannot be read is reported under "errors" by manager, keeping the packages
+// of its previous scan, and is read again on the next collection.
+func (c *PackageCollector) Collect() (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stamps := c.currentStamps()
+	changes := []PackageEvent{}
+	if c.packages == nil || len(c.errors) > 0 || !sameStamps(stamps, c.stamps) {
+		pkgs, errs := c.scan(stamps)
+		for manager := range errs {
+			for k, p := range c.packages {
+				if p.Manager == manager {
+					pkgs[k] = p
+				}
+			}
+		}
+		c.errors = errs
+		if c.packages != nil {
+			changes = diffPackages(c.packages, pkgs, time.Now())
+			c.events = append(c.events, changes...)
+			if over := len(c.events) - maxPackageEvents; over > 0 {
+				c.events = c.events[over:]
+			}
+		}
+		c.packages = pkgs
+		c.stamps = stamps
+		c.scannedAt = time.Now()
+	}
+
+	managers := map[string]int{}
+	for _, p := range c.packages {
+		managers[p.Manager]++
+	}
+	errs := make(map[string]string, len(c.errors))
+	for manager, e := range c.errors {
+		errs[manager] = e
+	}
+	return map[string]interface{}{
+		"count":     len(c.packages),
+		"managers":  managers,
+		"changes":   changes,
+		"errors":    errs,
+		"scannedAt": c.scannedAt,
+	}, nil
+}
+
+// Inventory returns the installed packages sorted by name, optionally
+// filtered to an exact package name, along with the time of the last scan.
+func (c *PackageCollector) Inventory(name string) ([]Package, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Package, 0, len(c.packages))
+	for _, p := range c.packages {
+		if name == "" || p.Name == name {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].key() < out[j].key()
+	})
+	return out, c.scannedAt
+}
+
+// Events returns the recorded package change events, oldest first.
+func (c *PackageCollector) Events() []PackageEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]PackageEvent(nil), c.events...)
+}
+
+// scan reads every available database. Sources that do not exist on this
+// host are skipped, and the error of each source that cannot be read is
+// returned by manager.
+func (c *PackageCollector) scan(stamps map[string]string) (map[string]Package, map[string]string) {
+	pkgs := map[string]Package{}
+	errs := map[string]string{}
+	read := func(manager string, fn func() ([]Package, error)) {
+		if _, ok := stamps[manager]; !ok {
+			return
+		}
+		list, err := fn()
+		if err != nil {
+			errs[manager] = err.Error()
+			return
+		}
+		for _, p := range list {
+			pkgs[p.key()] = p
+		}
+	}
+
+	read("dpkg", func() ([]Package, error) {
+		return parsePackageFile(c.dpkgStatusPath, ParseDpkgStatus)
+	})
+	read("apk", func() ([]Package, error) {
+		return parsePackageFile(c.apkInstalledPath, ParseAPKInstalled)
+	})
+	read("rpm", func() ([]Package, error) {
+		out, err := c.rpmQuery()
+		if errors.Is(err, exec.ErrNotFound) {
+			// An rpm database without the rpm binary cannot be queried.
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("querying rpm database: %v", err)
+		}
+		return ParseRPMQuery(bytes.NewReader(out))
+	})
+	return pkgs, errs
+}
+
+// parsePackageFile parses a package database file with parse.
+func parsePackageFile(path string, parse func(io.Reader) ([]Package, error)) ([]Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return list, nil
+}
+
+// currentStamps returns a modification stamp for each database present.
+func (c *PackageCollector) currentStamps() map[string]string {
+	stamps := map[string]string{}
+	if s, ok := fileStamp(c.dpkgStatusPath); ok {
+		stamps["dpkg"] = s
+	}
+	if s, ok := fileStamp(c.apkInstalledPath); ok {
+		stamps["apk"] = s
+	}
+	if c.rpmQuery != nil {
+		if s, ok := rpmStamp(c.rpmDBPath); ok {
+			stamps["rpm"] = s
+		}
+	}
+	return stamps
+}
+
+// rpmStamp returns a stamp of the database files in the rpm database
+// directory. The directory itself is not modified when rpm updates its
+// files in place.
+func rpmStamp(dir string) (string, bool) {
+	if dir == "" {
+		return "", false
+	}
+	var parts []string
+	for _, name := range rpmDBFiles {
+		if s, ok := fileStamp(filepath.Join(dir, name)); ok {
+			parts = append(parts, name+"="+s)
+		}
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return strings.Join(parts, ","), true
+}
+
+// fileStamp returns a string that changes whenever the file is modified.
+func fileStamp(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()), true
+}
+
+// sameStamps reports whether two stamp sets are identical.
+func sameStamps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// diffPackages returns the events turning old into cur.
+func diffPackages(old, cur map[string]Package, now time.Time) []PackageEvent {
+	events := []PackageEvent{}
+	for k, p := range cur {
+		o, ok := old[k]
+		switch {
+		case !ok:
+			events = append(events, PackageEvent{Time: now, Type: "installed", Name: p.Name, Arch: p.Arch, Manager: p.Manager, Version: p.Version})
+		case o.Version != p.Version:
+			events = append(events, PackageEvent{Time: now, Type: "upgraded", Name: p.Name, Arch: p.Arch, Manager: p.Manager, Version: p.Version, OldVersion: o.Version})
+		}
+	}
+	for k, o := range old {
+		if _, ok := cur[k]; !ok {
+			events = append(events, PackageEvent{Time: now, Type: "removed", Name: o.Name, Arch: o.Arch, Manager: o.Manager, OldVersion: o.Version})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
+
+// ParseDpkgStatus parses a dpkg status database, returning installed packages.
+func ParseDpkgStatus(r io.Reader) ([]Package, error) {
+	var pkgs []Package
+	err := parseStanzas(r, func(fields map[string]string) {
+		status := strings.Fields(fields["Status"])
+		if fields["Package"] == "" || len(status) == 0 || status[len(status)-1] != "installed" {
+			return
+		}
+		pkgs = append(pkgs, Package{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			Arch:    fields["Architecture"],
+			Manager: "dpkg",
+		})
+	})
+	return pkgs, err
+}
+
+// ParseAPKInstalled parses an apk installed database.
+func ParseAPKInstalled(r io.Reader) ([]Package, error) {
+	var pkgs []Package
+	err := parseStanzas(r, func(fields map[string]string) {
+		if fields["P"] == "" {
+			return
+		}
+		pkgs = append(pkgs, Package{
+			Name:    fields["P"],
+			Version: fields["V"],
+			Arch:    fields["A"],
+			Manager: "apk",
+		})
+	})
+	return pkgs, err
+}
+
+// rpmQueryFormat is the --queryformat used to list rpm packages.
+const rpmQueryFormat = `%{NAME}\t%|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\t%{ARCH}\n`
+
+// runRPMQuery lists installed packages with the rpm binary, if present,
+// pointing it at the host root when the agent runs in a container.
+func runRPMQuery() ([]byte, error) {
+	path, err := exec.LookPath("rpm")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-qa", "--queryformat", rpmQueryFormat}
+	if root := CurrentHostRoots().Root; root != "/" {
+		args = append([]string{"--root", root}, args...)
+	}
+	return exec.Command(path, args...).Output()
+}
+
+// ParseRPMQuery parses tab separated name, version and arch lines as
+// produced by `rpm -qa --queryformat`.
+func ParseRPMQuery(r io.Reader) ([]Package, error) {
+	var pkgs []Package
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if len(parts) != 3 || parts[0] == "" {
+			continue
+		}
+		arch := parts[2]
+		if arch == "(none)" {
+			arch = ""
+		}
+		pkgs = append(pkgs, Package{Name: parts[0], Version: parts[1], Arch: arch, Manager: "rpm"})
+	}
+	return pkgs, scanner.Err()
+}
+
+// parseStanzas splits RFC822-like databases into blank-line separated
+// stanzas of "Key:Value" lines and calls fn for each. Continuation lines
+// starting with whitespace are ignored.
+func parseStanzas(r io.Reader, fn func(map[string]string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	fields := map[string]string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				fn(fields)
+				fields = map[string]string{}
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			fields[k] = strings.TrimSpace(v)
+		}
+	}
+	if len(fields) > 0 {
+		fn(fields)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,141 @@
+// pkg/collector/packages_test.go
+
+package collector
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// packageFixture returns the contents of a file in testdata/packages.
+func packageFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "packages", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParsePackageDatabases(t *testing.T) {
+	for _, tc := range []struct {
+		file  string
+		parse func([]byte) ([]Package, error)
+		want  []Package
+	}{
+		{"dpkg-status", func(b []byte) ([]Package, error) { return ParseDpkgStatus(strings.NewReader(string(b))) }, []Package{
+			{"bash", "5.2.15-2+b2", "amd64", "dpkg"},
+			{"libc6", "2.36-9+deb12u4", "amd64", "dpkg"},
+			{"libc6", "2.36-9+deb12u4", "i386", "dpkg"},
+		}},
+		{"apk-installed", func(b []byte) ([]Package, error) { return ParseAPKInstalled(strings.NewReader(string(b))) }, []Package{
+			{"musl", "1.2.4-r2", "x86_64", "apk"},
+			{"busybox", "1.36.1-r5", "x86_64", "apk"},
+		}},
+		{"rpm-qa.txt", func(b []byte) ([]Package, error) { return ParseRPMQuery(strings.NewReader(string(b))) }, []Package{
+			{"openssl", "1:3.0.7-25.el9", "x86_64", "rpm"},
+			{"gpg-pubkey", "3228467c-613798eb", "", "rpm"},
+		}},
+	} {
+		got, err := tc.parse(packageFixture(t, tc.file))
+		if err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: parsed %+v, want %+v", tc.file, got, tc.want)
+		}
+	}
+}
+
+func collectPackages(t *testing.T, c *PackageCollector) map[string]interface{} {
+	t.Helper()
+	v, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	return v.(map[string]interface{})
+}
+
+func TestPackageCollector(t *testing.T) {
+	dir := t.TempDir()
+	dpkg := filepath.Join(dir, "status")
+	rpmDB := filepath.Join(dir, "rpm")
+	os.WriteFile(dpkg, packageFixture(t, "dpkg-status"), 0644)
+	os.Mkdir(rpmDB, 0755)
+	os.WriteFile(filepath.Join(rpmDB, "rpmdb.sqlite"), []byte("v1"), 0644)
+	rpmQuery := func() ([]byte, error) { return packageFixture(t, "rpm-qa.txt"), nil }
+	c := NewPackageCollectorWithPaths(dpkg, filepath.Join(dir, "missing"), rpmDB, rpmQuery)
+
+	res := collectPackages(t, c)
+	if res["count"] != 5 || !reflect.DeepEqual(res["managers"], map[string]int{"dpkg": 3, "rpm": 2}) {
+		t.Errorf("first scan = %v", res)
+	}
+	if changes := res["changes"].([]PackageEvent); len(changes) != 0 {
+		t.Errorf("first scan reported changes %+v", changes)
+	}
+
+	// Upgrade bash, remove the i386 libc6 and install curl.
+	status := strings.Replace(string(packageFixture(t, "dpkg-status")), "5.2.15-2+b2", "5.2.21-2", 1)
+	status = status[:strings.LastIndex(status, "Package: libc6")] +
+		"Package: curl\nStatus: install ok installed\nArchitecture: amd64\nVersion: 7.88.1-10\n"
+	os.WriteFile(dpkg, []byte(status), 0644)
+
+	var got []string
+	for _, e := range collectPackages(t, c)["changes"].([]PackageEvent) {
+		got = append(got, e.Type+" "+e.Name+" "+e.Arch)
+	}
+	want := []string{"upgraded bash amd64", "installed curl amd64", "removed libc6 i386"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %q, want %q", got, want)
+	}
+	if len(c.Events()) != 3 {
+		t.Errorf("recorded %d events, want 3", len(c.Events()))
+	}
+	if pkgs, _ := c.Inventory("bash"); len(pkgs) != 1 || pkgs[0].Version != "5.2.21-2" {
+		t.Errorf("Inventory(bash) = %+v", pkgs)
+	}
+}
+
+func TestPackageCollectorUnavailable(t *testing.T) {
+	dir := t.TempDir()
+	// Hosts without any package database report nothing, without errors.
+	c := NewPackageCollectorWithPaths(filepath.Join(dir, "status"), filepath.Join(dir, "installed"), filepath.Join(dir, "rpm"), nil)
+	if res := collectPackages(t, c); res["count"] != 0 || len(res["errors"].(map[string]string)) != 0 {
+		t.Errorf("Collect without databases = %v", res)
+	}
+
+	// An rpm database without the rpm binary is skipped.
+	rpmDB := filepath.Join(dir, "rpm")
+	os.Mkdir(rpmDB, 0755)
+	os.WriteFile(filepath.Join(rpmDB, "Packages"), []byte("v1"), 0644)
+	rpmErr := exec.ErrNotFound
+	c = NewPackageCollectorWithPaths("", "", rpmDB, func() ([]byte, error) { return packageFixture(t, "rpm-qa.txt"), rpmErr })
+	if res := collectPackages(t, c); res["count"] != 0 || len(res["errors"].(map[string]string)) != 0 {
+		t.Errorf("Collect without the rpm binary = %v", res)
+	}
+
+	// A failing query is reported, keeping the packages of the last scan
+	// until the database can be read again.
+	rpmErr = nil
+	os.WriteFile(filepath.Join(rpmDB, "Packages"), []byte("v2"), 0644)
+	collectPackages(t, c)
+	rpmErr = errors.New("database locked")
+	os.WriteFile(filepath.Join(rpmDB, "Packages"), []byte("v03"), 0644)
+	res := collectPackages(t, c)
+	if errs := res["errors"].(map[string]string); res["count"] != 2 || !strings.Contains(errs["rpm"], "database locked") {
+		t.Errorf("Collect with a failing query = %v", res)
+	}
+	if changes := res["changes"].([]PackageEvent); len(changes) != 0 {
+		t.Errorf("failing query reported changes %+v", changes)
+	}
+	rpmErr = nil
+	if res := collectPackages(t, c); res["count"] != 2 || len(res["errors"].(map[string]string)) != 0 {
+		t.Errorf("Collect after recovery = %v", res)
+	}
+}
@@ -0,0 +1,231 @@
+// pkg/collector/redis.go
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisCollector queries Redis servers with the INFO command.
+type RedisCollector struct {
+	addresses []string
+	password  string
+	timeout   time.Duration
+	rates     counterRates
+}
+
+// NewRedisCollector returns a collector for the given host:port addresses.
+// When password is non-empty it is sent with AUTH before INFO.
+func NewRedisCollector(addresses []string, password string, timeout time.Duration) *RedisCollector {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &RedisCollector{addresses: addresses, password: password, timeout: timeout}
+}
+
+// Collect queries every configured server. An unreachable server is reported
+// with up=false rather than failing the collection.
+func (r *RedisCollector) Collect() (interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(r.addresses))
+	for _, addr := range r.addresses {
+		entry := map[string]interface{}{"address": addr}
+		info, err := r.info(addr)
+		entry["up"] = err == nil
+		if err != nil {
+			entry["error"] = err.Error()
+			results = append(results, entry)
+			continue
+		}
+		for k, v := range redisMetrics(info) {
+			entry[k] = v
+		}
+		rates := r.rates.update(addr, time.Now(), map[string]uint64{
+			"commands":  infoUint(info, "total_commands_processed"),
+			"evictions": infoUint(info, "evicted_keys"),
+		})
+		if v, ok := rates["commands"]; ok {
+			entry["opsPerSec"] = v
+		}
+		if v, ok := rates["evictions"]; ok {
+			entry["evictionsPerSec"] = v
+		}
+		results = append(results, entry)
+	}
+	return results, nil
+}
+
+// info connects to addr, authenticates if needed and returns the parsed INFO reply.
+func (r *RedisCollector) info(addr string) (map[string]string, error) {
+	conn, err := net.DialTimeout("tcp", addr, r.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(r.timeout))
+	rd := bufio.NewReader(conn)
+
+	if r.password != "" {
+		if _, err := conn.Write(encodeRESPCommand("AUTH", r.password)); err != nil {
+			return nil, err
+		}
+		if _, err := readRESPReply(rd); err != nil {
+			return nil, fmt.Errorf("AUTH: %v", err)
+		}
+	}
+	if _, err := conn.Write(encodeRESPCommand("INFO")); err != nil {
+		return nil, err
+	}
+	reply, err := readRESPReply(rd)
+	if err != nil {
+		return nil, fmt.Errorf("INFO: %v", err)
+	}
+	return ParseRedisInfo(reply), nil
+}
+
+// encodeRESPCommand encodes a command as a RESP array of bulk strings.
+func encodeRESPCommand(args ...string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return []byte(b.String())
+}
+
+// readRESPReply reads a simple string, integer, error or bulk string reply.
+// Error replies are returned as errors.
+func readRESPReply(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("server error: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return "", fmt.Errorf("unexpected reply %q", line)
+}
+
+// ParseRedisInfo parses the "key:value" lines of an INFO reply. Section
+// headers and blank lines are skipped.
+func ParseRedisInfo(s string) map[string]string {
+	info := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			info[k] = v
+		}
+	}
+	return info
+}
+
+// infoUint returns a numeric INFO field, or 0 when absent or malformed.
+func infoUint(info map[string]string, key string) uint64 {
+	v, _ := strconv.ParseUint(info[key], 10, 64)
+	return v
+}
+
+// infoFloat returns a floating point INFO field, or 0 when absent or malformed.
+func infoFloat(info map[string]string, key string) float64 {
+	v, _ := strconv.ParseFloat(info[key], 64)
+	return v
+}
+
+// redisMetrics extracts memory, cache, client and replication metrics from INFO.
+func redisMetrics(info map[string]string) map[string]interface{} {
+	hits := infoUint(info, "keyspace_hits")
+	misses := infoUint(info, "keyspace_misses")
+	out := map[string]interface{}{
+		"version":       info["redis_version"],
+		"uptimeSeconds": infoUint(info, "uptime_in_seconds"),
+		"memory": map[string]interface{}{
+			"used":               infoUint(info, "used_memory"),
+			"rss":                infoUint(info, "used_memory_rss"),
+			"peak":               infoUint(info, "used_memory_peak"),
+			"max":                infoUint(info, "maxmemory"),
+			"fragmentationRatio": infoFloat(info, "mem_fragmentation_ratio"),
+		},
+		"clients": map[string]interface{}{
+			"connected": infoUint(info, "connected_clients"),
+			"blocked":   infoUint(info, "blocked_clients"),
+		},
+		"keyspace": map[string]interface{}{
+			"hits":      hits,
+			"misses":    misses,
+			"hitRate":   hitRate(hits, misses),
+			"evictions": infoUint(info, "evicted_keys"),
+			"expired":   infoUint(info, "expired_keys"),
+			"keys":      redisKeyCount(info),
+		},
+		"instantaneousOpsPerSec": infoUint(info, "instantaneous_ops_per_sec"),
+		"totalCommands":          infoUint(info, "total_commands_processed"),
+	}
+
+	replication := map[string]interface{}{
+		"role":              info["role"],
+		"connectedReplicas": infoUint(info, "connected_slaves"),
+		"offset":            infoUint(info, "master_repl_offset"),
+	}
+	if info["role"] == "slave" {
+		replication["masterHost"] = info["master_host"]
+		replication["masterPort"] = info["master_port"]
+		replication["linkStatus"] = info["master_link_status"]
+		replication["lastIOSecondsAgo"] = infoFloat(info, "master_last_io_seconds_ago")
+		replication["syncInProgress"] = info["master_sync_in_progress"] == "1"
+	}
+	out["replication"] = replication
+	return out
+}
+
+// redisKeyCount sums the keys of every database line ("db0:keys=1,expires=0,...").
+func redisKeyCount(info map[string]string) uint64 {
+	var total uint64
+	for k, v := range info {
+		if !strings.HasPrefix(k, "db") {
+			continue
+		}
+		for _, field := range strings.Split(v, ",") {
+			if n, ok := strings.CutPrefix(field, "keys="); ok {
+				c, _ := strconv.ParseUint(n, 10, 64)
+				total += c
+			}
+		}
+	}
+	return total
+}
+
+// hitRate returns hits / (hits + misses), or 0 when there were no lookups.
+func hitRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
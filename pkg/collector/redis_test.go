@@ -0,0 +1,160 @@
+// pkg/collector/redis_test.go
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRedisInfo is an abridged INFO reply from a replica.
+const testRedisInfo = "# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:3600\r\n\r\n" +
+	"# Clients\r\nconnected_clients:12\r\nblocked_clients:1\r\n\r\n" +
+	"# Memory\r\nused_memory:1048576\r\nused_memory_rss:2097152\r\nused_memory_peak:1572864\r\nmaxmemory:0\r\nmem_fragmentation_ratio:2.00\r\n\r\n" +
+	"# Stats\r\ntotal_commands_processed:5000\r\ninstantaneous_ops_per_sec:7\r\nexpired_keys:4\r\nevicted_keys:2\r\nkeyspace_hits:90\r\nkeyspace_misses:10\r\n\r\n" +
+	"# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_port:6379\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:1\r\nmaster_sync_in_progress:0\r\nconnected_slaves:0\r\nmaster_repl_offset:42\r\n\r\n" +
+	"# Keyspace\r\ndb0:keys=30,expires=2,avg_ttl=0\r\ndb3:keys=5,expires=0,avg_ttl=0\r\n"
+
+// fakeTCPServer accepts connections on a local port and handles each with
+// serve until the test ends. It returns the listening address.
+func fakeTCPServer(t *testing.T, serve func(rd *bufio.Reader, conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(bufio.NewReader(conn), conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// closedAddress returns a local address nothing listens on.
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// fakeRedis serves AUTH with the given password and INFO, reading commands
+// as RESP arrays of bulk strings.
+func fakeRedis(t *testing.T, password string) string {
+	return fakeTCPServer(t, func(rd *bufio.Reader, conn net.Conn) {
+		for {
+			var n int
+			if _, err := fmt.Fscanf(rd, "*%d\r\n", &n); err != nil {
+				return
+			}
+			args := make([]string, n)
+			for i := range args {
+				var size int
+				if _, err := fmt.Fscanf(rd, "$%d\r\n", &size); err != nil {
+					return
+				}
+				buf := make([]byte, size+2)
+				if _, err := io.ReadFull(rd, buf); err != nil {
+					return
+				}
+				args[i] = string(buf[:size])
+			}
+			switch {
+			case args[0] == "AUTH" && len(args) == 2 && args[1] == password:
+				conn.Write([]byte("+OK\r\n"))
+			case args[0] == "AUTH":
+				conn.Write([]byte("-WRONGPASS invalid username-password pair\r\n"))
+			case args[0] == "INFO":
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(testRedisInfo), testRedisInfo)
+			default:
+				conn.Write([]byte("-ERR unknown command\r\n"))
+			}
+		}
+	})
+}
+
+func TestReadRESPReply(t *testing.T) {
+	for _, tc := range []struct {
+		reply, want string
+		ok          bool
+	}{
+		{"+OK\r\n", "OK", true},
+		{":42\r\n", "42", true},
+		{"$5\r\nhello\r\n", "hello", true},
+		{"$-1\r\n", "", true},
+		{"-ERR no\r\n", "", false},
+		{"$x\r\n", "", false},
+		{"$10\r\nshort\r\n", "", false},
+		{"\r\n", "", false},
+		{"?\r\n", "", false},
+	} {
+		got, err := readRESPReply(bufio.NewReader(strings.NewReader(tc.reply)))
+		if got != tc.want || (err == nil) != tc.ok {
+			t.Errorf("readRESPReply(%q) = %q, %v", tc.reply, got, err)
+		}
+	}
+}
+
+func TestRedisCollector(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+	c := NewRedisCollector([]string{addr}, "secret", time.Second)
+	v, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := v.([]map[string]interface{})[0]
+	if r["up"] != true || r["version"] != "7.2.4" || r["totalCommands"] != uint64(5000) {
+		t.Fatalf("redis = %v", r)
+	}
+	keyspace := r["keyspace"].(map[string]interface{})
+	if keyspace["keys"] != uint64(35) || keyspace["hitRate"] != 0.9 || keyspace["evictions"] != uint64(2) {
+		t.Errorf("keyspace = %v", keyspace)
+	}
+	if mem := r["memory"].(map[string]interface{}); mem["rss"] != uint64(2097152) || mem["fragmentationRatio"] != 2.0 {
+		t.Errorf("memory = %v", mem)
+	}
+	repl := r["replication"].(map[string]interface{})
+	if repl["role"] != "slave" || repl["masterHost"] != "10.0.0.1" || repl["linkStatus"] != "up" || repl["syncInProgress"] != false {
+		t.Errorf("replication = %v", repl)
+	}
+	if _, ok := r["opsPerSec"]; ok {
+		t.Errorf("rate reported from a single sample: %v", r)
+	}
+	v, _ = c.Collect()
+	if r := v.([]map[string]interface{})[0]; r["opsPerSec"] != 0.0 {
+		t.Errorf("opsPerSec = %v", r["opsPerSec"])
+	}
+}
+
+func TestRedisCollectorUnavailable(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+	v, err := NewRedisCollector([]string{addr, closedAddress(t)}, "wrong", time.Second).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := v.([]map[string]interface{})
+	if r := results[0]; r["up"] != false || !strings.Contains(fmt.Sprint(r["error"]), "WRONGPASS") {
+		t.Errorf("redis with a wrong password = %v", r)
+	}
+	if r := results[1]; r["up"] != false || r["error"] == nil {
+		t.Errorf("unreachable redis = %v", r)
+	}
+}
@@ -9,7 +9,9 @@ import (
 )
 
 // Spy collects information about running processes.
-type Spy struct{}
+type Spy struct {
+	cgroups *CgroupResolver
+}
 
 type Collector interface {
 	Collect() (interface{}, error)
@@ -17,7 +19,7 @@ type Collector interface {
 
 // NewSpy returns a new Spy instance.
 func NewSpy() *Spy {
-	return &Spy{}
+	return &Spy{cgroups: NewCgroupResolver(HostProc())}
 }
 
 // Collect retrieves process details.
@@ -34,11 +36,16 @@ func (s *Spy) Collect() (interface{}, error) {
 		if err != nil {
 			continue
 		}
-		results = append(results, map[string]interface{}{
+		entry := map[string]interface{}{
 			"pid":  p.Pid,
 			"name": name,
 			"time": time.Now(),
-		})
+		}
+		// Attach container, pod and systemd unit labels when available.
+		if info, err := s.cgroups.Resolve(p.Pid); err == nil && info.Path != "" {
+			entry["labels"] = info.Labels()
+		}
+		results = append(results, entry)
 	}
 	return results, nil
 }
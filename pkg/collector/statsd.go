@@ -0,0 +1,480 @@
+// pkg/collector/statsd.go
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsDSample is one parsed value from a StatsD line.
+type StatsDSample struct {
+	Name       string
+	Type       string // "c", "g", "ms", "h", "d" or "s".
+	Value      float64
+	Raw        string // Set member, or gauge value with its sign.
+	SampleRate float64
+	Tags       map[string]string
+}
+
+// ParseStatsDLine parses a StatsD line with optional DogStatsD extensions:
+//
+//	name:value|type[|@rate][|#tag:value,tag]
+//
+// Several values may share a line ("name:1:2:3|h"). Events ("_e{") and
+// service checks ("_sc|") are not metrics and return no samples.
+func ParseStatsDLine(line string) ([]StatsDSample, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return nil, nil
+	}
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("missing metric name in %q", line)
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("missing metric type in %q", line)
+	}
+	typ := parts[1]
+	switch typ {
+	case "c", "g", "ms", "h", "d", "s":
+	default:
+		return nil, fmt.Errorf("unknown metric type %q", typ)
+	}
+
+	rate := 1.0
+	var tags map[string]string
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			r, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return nil, fmt.Errorf("invalid sample rate %q", p)
+			}
+			rate = r
+		case strings.HasPrefix(p, "#"):
+			tags = parseStatsDTags(p[1:])
+		}
+	}
+
+	var samples []StatsDSample
+	for _, raw := range strings.Split(parts[0], ":") {
+		s := StatsDSample{Name: name, Type: typ, Raw: raw, SampleRate: rate, Tags: tags}
+		if typ != "s" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("invalid value %q for %s", raw, name)
+			}
+			s.Value = v
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// parseStatsDTags parses "key:value,flag" into a map. Tags without a value map to "".
+func parseStatsDTags(s string) map[string]string {
+	tags := map[string]string{}
+	for _, t := range strings.Split(s, ",") {
+		if t == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(t, ":")
+		tags[k] = v
+	}
+	return tags
+}
+
+// maxTimerSamples bounds the samples kept per timer, histogram or
+// distribution series in a flush interval. Beyond it, a uniform reservoir
+// sample is kept for percentiles; count, sum, min and max stay exact.
+const maxTimerSamples = 1024
+
+// statsdSeries accumulates the samples of one name, type and tag set
+// within a flush interval.
+type statsdSeries struct {
+	name  string
+	typ   string
+	tags  map[string]string
+	count float64 // Counter total, or number of timer samples scaled by sample rate.
+	gauge float64 // Last gauge value.
+	// Timer, histogram and distribution samples: a reservoir of at most
+	// maxTimerSamples values out of seen, and exact aggregates of all of them.
+	values   []float64
+	seen     uint64
+	sum      float64
+	min, max float64
+	set      map[string]struct{}
+	idle     int // Flushes since a gauge was last updated.
+}
+
+// observe adds a timer sample, replacing a random kept sample once the
+// reservoir is full. It reports whether the sample was dropped.
+func (ser *statsdSeries) observe(v float64) bool {
+	if ser.seen == 0 || v < ser.min {
+		ser.min = v
+	}
+	if ser.seen == 0 || v > ser.max {
+		ser.max = v
+	}
+	ser.seen++
+	ser.sum += v
+	if len(ser.values) < maxTimerSamples {
+		ser.values = append(ser.values, v)
+		return false
+	}
+	if i := rand.Int63n(int64(ser.seen)); i < maxTimerSamples {
+		ser.values[i] = v
+	}
+	return true
+}
+
+// StatsDMetric is the aggregate of one series over a flush interval.
+type StatsDMetric struct {
+	Name string            `json:"name"`
+	Type string            `json:"type"`
+	Tags map[string]string `json:"tags,omitempty"`
+	// Counters.
+	Count *float64 `json:"count,omitempty"`
+	Rate  *float64 `json:"ratePerSec,omitempty"`
+	// Gauges.
+	Value *float64 `json:"value,omitempty"`
+	// Timers, histograms and distributions.
+	Summary *StatsDSummary `json:"summary,omitempty"`
+	// Sets.
+	Unique *int `json:"unique,omitempty"`
+}
+
+// StatsDSummary describes the distribution of timer samples. Percentiles
+// are estimated from a sample when more than maxTimerSamples values were
+// received; Dropped is the number of values left out of it.
+type StatsDSummary struct {
+	Count  float64 `json:"count"`
+	Sum    float64 `json:"sum"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	// Dropped is the number of values not kept for percentiles.
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// StatsDFlush is the result of one flush interval.
+type StatsDFlush struct {
+	Start       time.Time      `json:"start"`
+	End         time.Time      `json:"end"`
+	Metrics     []StatsDMetric `json:"metrics"`
+	Packets     uint64         `json:"packets"`
+	ParseErrors uint64         `json:"parseErrors"`
+	Dropped     uint64         `json:"dropped"`
+	// Timer values left out of percentile samples, across all series.
+	DroppedSamples uint64 `json:"droppedSamples"`
+}
+
+// maxPendingFlushes bounds the completed flushes kept until they are
+// drained; older flushes are dropped first.
+const maxPendingFlushes = 100
+
+// StatsDServer receives StatsD and DogStatsD metrics over UDP and,
+// optionally, a unix datagram socket and aggregates them per flush interval.
+type StatsDServer struct {
+	address     string
+	socketPath  string
+	interval    time.Duration
+	maxSeries   int
+	gaugeExpiry int
+
+	mu             sync.Mutex
+	start          time.Time
+	series         map[string]*statsdSeries
+	gauges         map[string]*statsdSeries // Gauges keep their value across flushes.
+	packets        uint64
+	parseErrors    uint64
+	dropped        uint64
+	droppedSamples uint64
+	pending        []*StatsDFlush
+	droppedFlushes uint64
+}
+
+// NewStatsDServer returns a receiver for the given UDP address and unix
+// socket path; either may be empty to disable it. At most maxSeries
+// distinct series are kept per interval; further series are dropped.
+// Gauges not updated for gaugeExpiry flushes are removed, unless
+// gaugeExpiry is 0.
+func NewStatsDServer(address, socketPath string, interval time.Duration, maxSeries, gaugeExpiry int) *StatsDServer {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if maxSeries <= 0 {
+		maxSeries = 10000
+	}
+	return &StatsDServer{
+		address:     address,
+		socketPath:  socketPath,
+		interval:    interval,
+		maxSeries:   maxSeries,
+		gaugeExpiry: gaugeExpiry,
+		start:       time.Now(),
+		series:      make(map[string]*statsdSeries),
+		gauges:      make(map[string]*statsdSeries),
+	}
+}
+
+// Run opens the configured sockets and receives metrics until ctx is
+// cancelled, flushing every interval. It returns an error if a socket
+// cannot be opened.
+func (s *StatsDServer) Run(ctx context.Context) error {
+	var conns []net.PacketConn
+	if s.address != "" {
+		c, err := net.ListenPacket("udp", s.address)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, c)
+	}
+	if s.socketPath != "" {
+		os.Remove(s.socketPath)
+		c, err := net.ListenPacket("unixgram", s.socketPath)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return err
+		}
+		conns = append(conns, c)
+	}
+	if len(conns) == 0 {
+		return errors.New("no StatsD address or socket configured")
+	}
+	for _, c := range conns {
+		go s.serve(c)
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			for _, c := range conns {
+				c.Close()
+			}
+			if s.socketPath != "" {
+				os.Remove(s.socketPath)
+			}
+			return nil
+		case now := <-ticker.C:
+			s.Flush(now)
+		}
+	}
+}
+
+// serve reads datagrams from conn until it is closed.
+func (s *StatsDServer) serve(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		s.HandlePacket(buf[:n])
+	}
+}
+
+// HandlePacket parses and aggregates a datagram of newline-separated lines.
+func (s *StatsDServer) HandlePacket(packet []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.packets++
+	for _, line := range strings.Split(string(packet), "\n") {
+		samples, err := ParseStatsDLine(line)
+		if err != nil {
+			s.parseErrors++
+			continue
+		}
+		for _, sample := range samples {
+			s.add(sample)
+		}
+	}
+}
+
+// add aggregates one sample. The caller holds s.mu.
+func (s *StatsDServer) add(sample StatsDSample) {
+	key := statsdSeriesKey(sample)
+	table := s.series
+	if sample.Type == "g" {
+		table = s.gauges
+	}
+	ser, ok := table[key]
+	if !ok {
+		if len(s.series)+len(s.gauges) >= s.maxSeries {
+			s.dropped++
+			return
+		}
+		ser = &statsdSeries{name: sample.Name, typ: sample.Type, tags: sample.Tags}
+		table[key] = ser
+	}
+
+	switch sample.Type {
+	case "c":
+		ser.count += sample.Value / sample.SampleRate
+	case "g":
+		ser.idle = 0
+		// A leading sign makes the value relative to the current gauge.
+		if strings.HasPrefix(sample.Raw, "+") || strings.HasPrefix(sample.Raw, "-") {
+			ser.gauge += sample.Value
+		} else {
+			ser.gauge = sample.Value
+		}
+	case "ms", "h", "d":
+		if ser.observe(sample.Value) {
+			s.droppedSamples++
+		}
+		ser.count += 1 / sample.SampleRate
+	case "s":
+		if ser.set == nil {
+			ser.set = make(map[string]struct{})
+		}
+		ser.set[sample.Raw] = struct{}{}
+	}
+}
+
+// statsdSeriesKey identifies a series by type, name and sorted tags.
+func statsdSeriesKey(sample StatsDSample) string {
+	var b strings.Builder
+	b.WriteString(sample.Type)
+	b.WriteByte('|')
+	b.WriteString(sample.Name)
+	keys := make([]string, 0, len(sample.Tags))
+	for k := range sample.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte('|')
+		b.WriteString(k)
+		b.WriteByte(':')
+		b.WriteString(sample.Tags[k])
+	}
+	return b.String()
+}
+
+// Flush closes the current interval at now, queues its aggregate to be
+// drained and returns it. Gauges not updated for the expiry number of
+// flushes are removed afterwards.
+func (s *StatsDServer) Flush(now time.Time) *StatsDFlush {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flush := &StatsDFlush{
+		Start:          s.start,
+		End:            now,
+		Metrics:        make([]StatsDMetric, 0, len(s.series)+len(s.gauges)),
+		Packets:        s.packets,
+		ParseErrors:    s.parseErrors,
+		Dropped:        s.dropped,
+		DroppedSamples: s.droppedSamples,
+	}
+	elapsed := now.Sub(s.start).Seconds()
+	for _, ser := range s.series {
+		m := StatsDMetric{Name: ser.name, Type: ser.typ, Tags: ser.tags}
+		switch ser.typ {
+		case "c":
+			count := ser.count
+			m.Count = &count
+			if elapsed > 0 {
+				rate := count / elapsed
+				m.Rate = &rate
+			}
+		case "ms", "h", "d":
+			m.Summary = ser.summarize()
+		case "s":
+			n := len(ser.set)
+			m.Unique = &n
+		}
+		flush.Metrics = append(flush.Metrics, m)
+	}
+	for key, ser := range s.gauges {
+		if s.gaugeExpiry > 0 && ser.idle >= s.gaugeExpiry {
+			delete(s.gauges, key)
+			continue
+		}
+		v := ser.gauge
+		flush.Metrics = append(flush.Metrics, StatsDMetric{Name: ser.name, Type: ser.typ, Tags: ser.tags, Value: &v})
+		ser.idle++
+	}
+	sort.Slice(flush.Metrics, func(i, j int) bool {
+		if flush.Metrics[i].Name != flush.Metrics[j].Name {
+			return flush.Metrics[i].Name < flush.Metrics[j].Name
+		}
+		return flush.Metrics[i].Type < flush.Metrics[j].Type
+	})
+
+	s.start = now
+	s.series = make(map[string]*statsdSeries)
+	s.packets, s.parseErrors, s.dropped, s.droppedSamples = 0, 0, 0, 0
+	s.pending = append(s.pending, flush)
+	if over := len(s.pending) - maxPendingFlushes; over > 0 {
+		s.pending = append(s.pending[:0], s.pending[over:]...)
+		s.droppedFlushes += uint64(over)
+	}
+	return flush
+}
+
+// summarize computes distribution statistics of a series' timer samples.
+// Count is the number of events they represent after sample-rate scaling.
+func (ser *statsdSeries) summarize() *StatsDSummary {
+	if ser.seen == 0 {
+		return &StatsDSummary{}
+	}
+	sorted := append([]float64(nil), ser.values...)
+	sort.Float64s(sorted)
+	return &StatsDSummary{
+		Count:   ser.count,
+		Sum:     ser.sum,
+		Min:     ser.min,
+		Max:     ser.max,
+		Mean:    ser.sum / float64(ser.seen),
+		Median:  percentile(sorted, 50),
+		P90:     percentile(sorted, 90),
+		P95:     percentile(sorted, 95),
+		P99:     percentile(sorted, 99),
+		Dropped: ser.seen - uint64(len(ser.values)),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Drain returns and clears the flushes completed since the last drain,
+// oldest first, and the number of flushes dropped because they were not
+// drained in time.
+func (s *StatsDServer) Drain() ([]*StatsDFlush, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flushes, dropped := s.pending, s.droppedFlushes
+	s.pending, s.droppedFlushes = nil, 0
+	return flushes, dropped
+}
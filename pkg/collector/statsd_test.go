@@ -0,0 +1,63 @@
+// pkg/collector/statsd_test.go
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStatsDLine(t *testing.T) {
+	samples, err := ParseStatsDLine("req.time:10:20|ms|@0.5|#env:prod,canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[1].Value != 20 || samples[0].SampleRate != 0.5 {
+		t.Fatalf("samples = %+v", samples)
+	}
+	if tags := samples[0].Tags; tags["env"] != "prod" || len(tags) != 2 {
+		t.Errorf("tags = %v", tags)
+	}
+	for _, line := range []string{"noValue", ":1|c", "x:1", "x:1|q", "x:abc|g", "x:1|c|@2"} {
+		if _, err := ParseStatsDLine(line); err == nil {
+			t.Errorf("ParseStatsDLine(%q) succeeded", line)
+		}
+	}
+}
+
+func TestStatsDTimerSamplesBounded(t *testing.T) {
+	s := NewStatsDServer("", "", time.Minute, 0, 0)
+	const n = 10 * maxTimerSamples
+	var packet strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&packet, "lat:%d|ms\n", i)
+	}
+	s.HandlePacket([]byte(packet.String()))
+	if got := len(s.series["ms|lat"].values); got != maxTimerSamples {
+		t.Errorf("kept %d samples, want %d", got, maxTimerSamples)
+	}
+
+	flush := s.Flush(time.Now())
+	if len(flush.Metrics) != 1 || flush.Metrics[0].Summary == nil {
+		t.Fatalf("metrics = %+v", flush.Metrics)
+	}
+	sum := flush.Metrics[0].Summary
+	// Count, sum, min, max and mean cover every sample.
+	if sum.Count != n || sum.Sum != n*(n+1)/2 || sum.Min != 1 || sum.Max != n || sum.Mean != (n+1)/2.0 {
+		t.Errorf("summary = %+v", sum)
+	}
+	if sum.Dropped != n-maxTimerSamples || flush.DroppedSamples != sum.Dropped {
+		t.Errorf("dropped %d samples (flush %d), want %d", sum.Dropped, flush.DroppedSamples, n-maxTimerSamples)
+	}
+	// The reservoir is a uniform sample, so its median is near the middle.
+	if sum.Median < n/4 || sum.Median > 3*n/4 {
+		t.Errorf("median = %v, want near %d", sum.Median, n/2)
+	}
+
+	s.HandlePacket([]byte("lat:5|ms"))
+	if flush := s.Flush(time.Now()); flush.DroppedSamples != 0 || flush.Metrics[0].Summary.Dropped != 0 {
+		t.Errorf("dropped samples were not reset: %+v", flush)
+	}
+}
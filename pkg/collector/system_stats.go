@@ -6,12 +6,10 @@ import (
 	"runtime"
 	"time"
 
-	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/host"
 	"github.com/shirou/gopsutil/load"
 	"github.com/shirou/gopsutil/mem"
-	"github.com/shirou/gopsutil/net"
 )
 
 // SystemStatsCollector collects detailed system stats.
@@ -58,40 +56,17 @@ func (s *SystemStatsCollector) Collect() (interface{}, error) {
 		}
 	}
 
-	// Get disk usage for root (you might iterate over all partitions as needed).
-	diskStat, err := disk.Usage("/")
+	// Get disk usage for the host root (you might iterate over all partitions as needed).
+	diskStat, err := disk.Usage(HostPath("/"))
 	if err != nil {
 		return nil, err
 	}
 
-	// Get CPU info.
-	cpuInfos, err := cpu.Info()
-	if err != nil {
-		return nil, err
-	}
-
-	// Get network interfaces.
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return nil, err
-	}
-
-	// Build a slice of CPU details.
-	cpus := make([]map[string]interface{}, 0, len(cpuInfos))
-	for _, info := range cpuInfos {
-		cpus = append(cpus, map[string]interface{}{
-			"model": info.ModelName,
-			"cores": info.Cores,
-			"speed": info.Mhz / 1000.0, // Convert MHz to GHz.
-		})
-	}
-
-	// Construct the system stats map.
+	// Construct the system stats map. Slow-changing facts such as CPU models
+	// and network interfaces are served by the inventory instead.
 	stats := map[string]interface{}{
 		"uptime":   hostInfo.Uptime,
 		"hostname": hostInfo.Hostname,
-		"platform": hostInfo.Platform,
-		"arch":     runtime.GOARCH,
 		"loadAvg":  []float64{loadAvg.Load1, loadAvg.Load5, loadAvg.Load15},
 		"memoryUsage": map[string]interface{}{
 			"totalMem":   vmStat.Total,
@@ -111,11 +86,9 @@ func (s *SystemStatsCollector) Collect() (interface{}, error) {
 			"used":        diskStat.Used,
 			"free":        diskStat.Free,
 			"usedPercent": diskStat.UsedPercent,
-			"mount":       diskStat.Path,
+			"mount":       UnhostPath(diskStat.Path),
 		},
-		"cpus":              cpus,
-		"networkInterfaces": interfaces, // This will include a lot of data; you can filter fields as needed.
-		"timestamp":         time.Now().Unix(),
+		"timestamp": time.Now().Unix(),
 	}
 
 	return stats, nil
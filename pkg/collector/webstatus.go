@@ -0,0 +1,383 @@
+// pkg/collector/webstatus.go
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NginxStatus is the content of an nginx stub_status page.
+type NginxStatus struct {
+	Active   uint64
+	Accepts  uint64
+	Handled  uint64
+	Requests uint64
+	Reading  uint64
+	Writing  uint64
+	Waiting  uint64
+}
+
+// ParseNginxStubStatus parses the output of ngx_http_stub_status_module:
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func ParseNginxStubStatus(r io.Reader) (*NginxStatus, error) {
+	var st NginxStatus
+	var haveActive, haveCounters bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Active connections:"):
+			v, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "Active connections:")), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid active connections: %v", err)
+			}
+			st.Active = v
+			haveActive = true
+		case strings.HasPrefix(line, "server accepts"):
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("missing counters line")
+			}
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("invalid counters line %q", scanner.Text())
+			}
+			for i, dst := range []*uint64{&st.Accepts, &st.Handled, &st.Requests} {
+				v, err := strconv.ParseUint(fields[i], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid counter %q: %v", fields[i], err)
+				}
+				*dst = v
+			}
+			haveCounters = true
+		case strings.HasPrefix(line, "Reading:"):
+			fields := strings.Fields(line)
+			for i := 0; i+1 < len(fields); i += 2 {
+				v, err := strconv.ParseUint(fields[i+1], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s value %q", fields[i], fields[i+1])
+				}
+				switch fields[i] {
+				case "Reading:":
+					st.Reading = v
+				case "Writing:":
+					st.Writing = v
+				case "Waiting:":
+					st.Waiting = v
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !haveActive || !haveCounters {
+		return nil, fmt.Errorf("not a stub_status page")
+	}
+	return &st, nil
+}
+
+// ApacheStatus is the content of an Apache mod_status "?auto" page.
+type ApacheStatus struct {
+	TotalAccesses     uint64
+	TotalKBytes       uint64
+	Uptime            uint64
+	BusyWorkers       uint64
+	IdleWorkers       uint64
+	ConnsTotal        uint64
+	ConnsAsyncWriting uint64
+	ConnsAsyncKeep    uint64
+	ConnsAsyncClosing uint64
+	ReqPerSec         float64
+	BytesPerSec       float64
+	// Scoreboard counts worker slots by state, e.g. "waiting", "sending".
+	Scoreboard map[string]int
+}
+
+// apacheScoreboardStates names the scoreboard characters documented for mod_status.
+var apacheScoreboardStates = map[rune]string{
+	'_': "waiting",
+	'S': "starting",
+	'R': "reading",
+	'W': "sending",
+	'K': "keepalive",
+	'D': "dns",
+	'C': "closing",
+	'L': "logging",
+	'G': "finishing",
+	'I': "idleCleanup",
+	'.': "open",
+}
+
+// ParseApacheStatus parses the machine-readable output of mod_status
+// (server-status?auto). Unknown keys are ignored.
+func ParseApacheStatus(r io.Reader) (*ApacheStatus, error) {
+	st := &ApacheStatus{Scoreboard: map[string]int{}}
+	uints := map[string]*uint64{
+		"Total Accesses":      &st.TotalAccesses,
+		"Total kBytes":        &st.TotalKBytes,
+		"Uptime":              &st.Uptime,
+		"BusyWorkers":         &st.BusyWorkers,
+		"IdleWorkers":         &st.IdleWorkers,
+		"ConnsTotal":          &st.ConnsTotal,
+		"ConnsAsyncWriting":   &st.ConnsAsyncWriting,
+		"ConnsAsyncKeepAlive": &st.ConnsAsyncKeep,
+		"ConnsAsyncClosing":   &st.ConnsAsyncClosing,
+	}
+	floats := map[string]*float64{
+		"ReqPerSec":   &st.ReqPerSec,
+		"BytesPerSec": &st.BytesPerSec,
+	}
+
+	var haveWorkers bool
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if dst, ok := uints[key]; ok {
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", key, value)
+			}
+			*dst = v
+			if key == "BusyWorkers" {
+				haveWorkers = true
+			}
+			continue
+		}
+		if dst, ok := floats[key]; ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", key, value)
+			}
+			*dst = v
+			continue
+		}
+		if key == "Scoreboard" {
+			for _, c := range value {
+				if state, ok := apacheScoreboardStates[c]; ok {
+					st.Scoreboard[state]++
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !haveWorkers {
+		return nil, fmt.Errorf("not a mod_status ?auto page")
+	}
+	return st, nil
+}
+
+// counterSample is a set of monotonically increasing counters read at one time.
+type counterSample struct {
+	at     time.Time
+	values map[string]uint64
+}
+
+// counterRates computes per-second rates of counters between successive
+// samples of the same source.
+type counterRates struct {
+	mu   sync.Mutex
+	prev map[string]counterSample
+}
+
+// update records the counters for source and returns the per-second rate of
+// each one since the previous sample. The first sample of a source, and any
+// counter that went backwards (a server restart), yield no rate.
+func (c *counterRates) update(source string, now time.Time, values map[string]uint64) map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prev == nil {
+		c.prev = make(map[string]counterSample)
+	}
+	prev, ok := c.prev[source]
+	c.prev[source] = counterSample{at: now, values: values}
+	rates := map[string]float64{}
+	if !ok {
+		return rates
+	}
+	elapsed := now.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return rates
+	}
+	for name, v := range values {
+		if p, ok := prev.values[name]; ok && v >= p {
+			rates[name] = float64(v-p) / elapsed
+		}
+	}
+	return rates
+}
+
+// fetchStatusPage performs a GET request and returns the response body.
+func fetchStatusPage(client *http.Client, url string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// NginxCollector reads nginx stub_status pages.
+type NginxCollector struct {
+	urls    []string
+	timeout time.Duration
+	client  *http.Client
+	rates   counterRates
+}
+
+// NewNginxCollector returns a collector for the given stub_status URLs.
+func NewNginxCollector(urls []string, timeout time.Duration) *NginxCollector {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &NginxCollector{urls: urls, timeout: timeout, client: &http.Client{}}
+}
+
+// Collect reads every configured page. An unreachable server is reported
+// with up=false rather than failing the collection.
+func (n *NginxCollector) Collect() (interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(n.urls))
+	for _, url := range n.urls {
+		entry := map[string]interface{}{"url": url}
+		body, err := fetchStatusPage(n.client, url, n.timeout)
+		var st *NginxStatus
+		if err == nil {
+			st, err = ParseNginxStubStatus(bytes.NewReader(body))
+		}
+		entry["up"] = err == nil
+		if err != nil {
+			entry["error"] = err.Error()
+			results = append(results, entry)
+			continue
+		}
+
+		rates := n.rates.update(url, time.Now(), map[string]uint64{
+			"accepts":  st.Accepts,
+			"requests": st.Requests,
+		})
+		connections := map[string]interface{}{
+			"active":   st.Active,
+			"reading":  st.Reading,
+			"writing":  st.Writing,
+			"waiting":  st.Waiting,
+			"accepted": st.Accepts,
+			"handled":  st.Handled,
+			"dropped":  st.Accepts - st.Handled,
+		}
+		if r, ok := rates["accepts"]; ok {
+			connections["acceptedPerSec"] = r
+		}
+		requests := map[string]interface{}{"total": st.Requests}
+		if r, ok := rates["requests"]; ok {
+			requests["perSec"] = r
+		}
+		entry["connections"] = connections
+		entry["requests"] = requests
+		results = append(results, entry)
+	}
+	return results, nil
+}
+
+// ApacheCollector reads Apache mod_status pages in their "?auto" form.
+type ApacheCollector struct {
+	urls    []string
+	timeout time.Duration
+	client  *http.Client
+	rates   counterRates
+}
+
+// NewApacheCollector returns a collector for the given server-status URLs.
+// "?auto" is appended to URLs without a query string.
+func NewApacheCollector(urls []string, timeout time.Duration) *ApacheCollector {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	normalized := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if !strings.Contains(u, "?") {
+			u += "?auto"
+		}
+		normalized = append(normalized, u)
+	}
+	return &ApacheCollector{urls: normalized, timeout: timeout, client: &http.Client{}}
+}
+
+// Collect reads every configured page. An unreachable server is reported
+// with up=false rather than failing the collection.
+func (a *ApacheCollector) Collect() (interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(a.urls))
+	for _, url := range a.urls {
+		entry := map[string]interface{}{"url": url}
+		body, err := fetchStatusPage(a.client, url, a.timeout)
+		var st *ApacheStatus
+		if err == nil {
+			st, err = ParseApacheStatus(bytes.NewReader(body))
+		}
+		entry["up"] = err == nil
+		if err != nil {
+			entry["error"] = err.Error()
+			results = append(results, entry)
+			continue
+		}
+
+		rates := a.rates.update(url, time.Now(), map[string]uint64{
+			"accesses": st.TotalAccesses,
+			"kbytes":   st.TotalKBytes,
+		})
+		requests := map[string]interface{}{
+			"total":          st.TotalAccesses,
+			"bytes":          st.TotalKBytes * 1024,
+			"avgPerSec":      st.ReqPerSec,
+			"avgBytesPerSec": st.BytesPerSec,
+		}
+		if r, ok := rates["accesses"]; ok {
+			requests["perSec"] = r
+		}
+		if r, ok := rates["kbytes"]; ok {
+			requests["bytesPerSec"] = r * 1024
+		}
+		entry["requests"] = requests
+		entry["connections"] = map[string]interface{}{
+			"total":          st.ConnsTotal,
+			"asyncWriting":   st.ConnsAsyncWriting,
+			"asyncKeepAlive": st.ConnsAsyncKeep,
+			"asyncClosing":   st.ConnsAsyncClosing,
+		}
+		entry["workers"] = map[string]interface{}{
+			"busy":       st.BusyWorkers,
+			"idle":       st.IdleWorkers,
+			"scoreboard": st.Scoreboard,
+		}
+		entry["uptimeSeconds"] = st.Uptime
+		results = append(results, entry)
+	}
+	return results, nil
+}
@@ -0,0 +1,155 @@
+// pkg/collector/webstatus_test.go
+
+package collector
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webStatusFixture returns the contents of a file in testdata/webstatus.
+func webStatusFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "webstatus", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseNginxStubStatus(t *testing.T) {
+	st, err := ParseNginxStubStatus(bytes.NewReader(webStatusFixture(t, "nginx-stub-status.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NginxStatus{Active: 291, Accepts: 16630948, Handled: 16630940, Requests: 31070465, Reading: 6, Writing: 179, Waiting: 106}
+	if *st != want {
+		t.Errorf("parsed %+v, want %+v", *st, want)
+	}
+	for _, page := range []string{
+		"",
+		"<html>It works!</html>",
+		"Active connections: 1\nserver accepts handled requests\n",
+		"Active connections: 1\nserver accepts handled requests\n 1 2\n",
+		"Active connections: x\nserver accepts handled requests\n 1 2 3\n",
+	} {
+		if _, err := ParseNginxStubStatus(strings.NewReader(page)); err == nil {
+			t.Errorf("ParseNginxStubStatus(%q) succeeded", page)
+		}
+	}
+}
+
+func TestParseApacheStatus(t *testing.T) {
+	st, err := ParseApacheStatus(bytes.NewReader(webStatusFixture(t, "apache-status-auto.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.TotalAccesses != 1200 || st.TotalKBytes != 512 || st.Uptime != 3600 || st.BusyWorkers != 2 || st.IdleWorkers != 48 ||
+		st.ConnsTotal != 3 || st.ConnsAsyncKeep != 1 || st.ConnsAsyncClosing != 1 || st.BytesPerSec != 145.636 {
+		t.Errorf("parsed %+v", *st)
+	}
+	want := map[string]int{"waiting": 7, "sending": 1, "keepalive": 1, "reading": 1, "closing": 1, "open": 5}
+	if !reflect.DeepEqual(st.Scoreboard, want) {
+		t.Errorf("scoreboard = %v, want %v", st.Scoreboard, want)
+	}
+	for _, page := range []string{"", "Total Accesses: 1\n", "BusyWorkers: many\n"} {
+		if _, err := ParseApacheStatus(strings.NewReader(page)); err == nil {
+			t.Errorf("ParseApacheStatus(%q) succeeded", page)
+		}
+	}
+}
+
+func TestCounterRates(t *testing.T) {
+	var c counterRates
+	start := time.Now()
+	if rates := c.update("a", start, map[string]uint64{"requests": 100}); len(rates) != 0 {
+		t.Errorf("first sample rates = %v", rates)
+	}
+	rates := c.update("a", start.Add(10*time.Second), map[string]uint64{"requests": 150})
+	if rates["requests"] != 5 {
+		t.Errorf("rates = %v, want 5 requests per second", rates)
+	}
+	// A restart resets the counters; no rate is reported across it.
+	if rates := c.update("a", start.Add(20*time.Second), map[string]uint64{"requests": 3}); len(rates) != 0 {
+		t.Errorf("rates across a restart = %v", rates)
+	}
+}
+
+// statusServer serves a fixture at /status with the given query string,
+// and a 404 for anything else.
+func statusServer(t *testing.T, fixture, query string) *httptest.Server {
+	body := webStatusFixture(t, fixture)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" || r.URL.RawQuery != query {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// closedURL returns a URL on a server that is no longer listening.
+func closedURL() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL + "/status"
+}
+
+func TestNginxCollector(t *testing.T) {
+	srv := statusServer(t, "nginx-stub-status.txt", "")
+	c := NewNginxCollector([]string{srv.URL + "/status", srv.URL + "/missing", closedURL()}, time.Second)
+	v, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := v.([]map[string]interface{})
+	if len(results) != 3 {
+		t.Fatalf("Collect returned %d results, want 3", len(results))
+	}
+	up := results[0]
+	conns := up["connections"].(map[string]interface{})
+	if up["up"] != true || conns["active"] != uint64(291) || conns["dropped"] != uint64(8) {
+		t.Errorf("nginx = %v", up)
+	}
+	if _, ok := conns["acceptedPerSec"]; ok {
+		t.Errorf("rate reported from a single sample: %v", conns)
+	}
+	for _, r := range results[1:] {
+		if r["up"] != false || r["error"] == nil {
+			t.Errorf("unavailable nginx = %v", r)
+		}
+	}
+
+	// The second collection has a rate, zero here as the page is static.
+	v, _ = c.Collect()
+	if r, ok := v.([]map[string]interface{})[0]["requests"].(map[string]interface{})["perSec"]; !ok || r != 0.0 {
+		t.Errorf("requests perSec = %v", r)
+	}
+}
+
+func TestApacheCollector(t *testing.T) {
+	// The server only answers ?auto, which the collector appends.
+	srv := statusServer(t, "apache-status-auto.txt", "auto")
+	v, err := NewApacheCollector([]string{srv.URL + "/status", closedURL()}, time.Second).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := v.([]map[string]interface{})
+	up := results[0]
+	requests := up["requests"].(map[string]interface{})
+	if up["up"] != true || requests["total"] != uint64(1200) || requests["bytes"] != uint64(512*1024) || up["uptimeSeconds"] != uint64(3600) {
+		t.Errorf("apache = %v", up)
+	}
+	if down := results[1]; down["up"] != false || down["error"] == nil {
+		t.Errorf("unavailable apache = %v", down)
+	}
+}
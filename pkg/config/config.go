@@ -11,12 +11,21 @@ import (
 type Config = agentconfig.AgentConfig
 type RemoteHost = agentconfig.RemoteHost
 
-func getConfigPath() (string, error) {
+// DataDir returns the ~/.sailfin directory holding configuration and agent state.
+func DataDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".sailfin", "AgentConfig.pkl"), nil
+	return filepath.Join(home, ".sailfin"), nil
+}
+
+func getConfigPath() (string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "AgentConfig.pkl"), nil
 }
 
 // SaveConfig saves configuration to a Pkl file in the user's ~/.sailfin directory.
@@ -0,0 +1,54 @@
+// pkg/export/csv.go
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteCSV writes the snapshots of open as CSV with a header row and one
+// column per label and metric path. Cells of values a snapshot lacks are
+// empty.
+func WriteCSV(w io.Writer, open Opener) (int, error) {
+	columns, err := collectColumns(open)
+	if err != nil {
+		return 0, err
+	}
+	cw := csv.NewWriter(w)
+	record := make([]string, len(columns)+1)
+	record[0] = TimestampColumn
+	for i, col := range columns {
+		record[i+1] = col.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return 0, err
+	}
+
+	c, err := open()
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	n := 0
+	for c.Next() {
+		snap := c.Snapshot()
+		row, err := Flatten(snap)
+		if err != nil {
+			return n, err
+		}
+		record[0] = formatTimestamp(snap.Timestamp)
+		for i, col := range columns {
+			record[i+1] = formatValue(row[col.Name])
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := c.Err(); err != nil {
+		return n, err
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
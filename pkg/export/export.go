@@ -0,0 +1,185 @@
+// pkg/export/export.go
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{"jsonl", "csv", "parquet"}
+
+// ContentType returns the MIME type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case "jsonl":
+		return "application/x-ndjson"
+	case "csv":
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// Opener opens a cursor over the snapshots to export. CSV and Parquet read
+// the snapshots twice, first to collect the columns and then to write the
+// rows, so the cursor should cover a fixed range.
+type Opener func() (storage.Cursor, error)
+
+// Write exports the snapshots of open to w in the given format and returns
+// the number of snapshots written.
+func Write(w io.Writer, format string, open Opener) (int, error) {
+	switch format {
+	case "jsonl":
+		c, err := open()
+		if err != nil {
+			return 0, err
+		}
+		defer c.Close()
+		return WriteJSONL(w, c)
+	case "csv":
+		return WriteCSV(w, open)
+	case "parquet":
+		return WriteParquet(w, open)
+	default:
+		return 0, fmt.Errorf("unknown export format %q; supported: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// ColumnType is the type of a flattened column.
+type ColumnType int
+
+const (
+	Number ColumnType = iota
+	Bool
+	String
+)
+
+// Column is one column of a flattened export.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// TimestampColumn is the name of the column holding snapshot timestamps.
+// Snapshot labels are exported as columns prefixed with LabelPrefix; every
+// other column is a metric path.
+const (
+	TimestampColumn = "timestamp"
+	LabelPrefix     = "label."
+)
+
+// Flatten returns the labels and metric values of a snapshot keyed by
+// column name. Metric columns are named by metric path, as in storage and
+// query selectors (see storage.JoinKey). Values are float64, bool or
+// string; nulls and empty objects have no column.
+func Flatten(snap storage.Snapshot) (map[string]interface{}, error) {
+	metrics, err := storage.JSONMetrics(snap.Metrics)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(snap.Labels)+64)
+	for k, v := range snap.Labels {
+		out[LabelPrefix+k] = v
+	}
+	storage.WalkLeaves(metrics, func(path string, v interface{}) {
+		out[path] = v
+	})
+	return out, nil
+}
+
+// columnSet collects the columns of flattened snapshots. A column whose
+// values have different types is exported as strings.
+type columnSet struct {
+	types map[string]ColumnType
+}
+
+func newColumnSet() *columnSet {
+	return &columnSet{types: map[string]ColumnType{}}
+}
+
+// add records the columns of a flattened snapshot.
+func (s *columnSet) add(row map[string]interface{}) {
+	for name, v := range row {
+		t := valueType(v)
+		if prev, ok := s.types[name]; ok && prev != t {
+			t = String
+		}
+		s.types[name] = t
+	}
+}
+
+// columns returns the collected columns: labels first, then metric paths,
+// each sorted by name. The timestamp column is not included.
+func (s *columnSet) columns() []Column {
+	out := make([]Column, 0, len(s.types))
+	for name, t := range s.types {
+		out = append(out, Column{Name: name, Type: t})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		li, lj := strings.HasPrefix(out[i].Name, LabelPrefix), strings.HasPrefix(out[j].Name, LabelPrefix)
+		if li != lj {
+			return li
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// collectColumns reads every snapshot of open to collect its columns.
+func collectColumns(open Opener) ([]Column, error) {
+	c, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	set := newColumnSet()
+	for c.Next() {
+		row, err := Flatten(c.Snapshot())
+		if err != nil {
+			return nil, err
+		}
+		set.add(row)
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	return set.columns(), nil
+}
+
+// valueType returns the column type of a flattened value.
+func valueType(v interface{}) ColumnType {
+	switch v.(type) {
+	case float64:
+		return Number
+	case bool:
+		return Bool
+	default:
+		return String
+	}
+}
+
+// formatValue formats a flattened value as text.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// formatTimestamp formats a snapshot timestamp for text formats.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,104 @@
+// pkg/export/jsonl.go
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// MaxLineBytes bounds one line of a JSON Lines import.
+const MaxLineBytes = 16 << 20
+
+// WriteJSONL writes the snapshots of a cursor as JSON Lines, one snapshot
+// per line in the format the /metrics endpoint serves.
+func WriteJSONL(w io.Writer, c storage.Cursor) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := 0
+	for c.Next() {
+		if err := enc.Encode(c.Snapshot()); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := c.Err(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// Import saves the snapshots of a JSON Lines stream to store. Storage
+// expects timestamps that do not decrease, so a snapshot older than the
+// line before it or the newest stored snapshot, or later than now, is
+// rejected with its line number. The snapshots before it are kept.
+func Import(store storage.Storage, r io.Reader) (int, error) {
+	var last time.Time
+	c, err := store.Cursor(storage.CursorOptions{Reverse: true})
+	if err != nil {
+		return 0, err
+	}
+	if c.Next() {
+		last = c.Snapshot().Timestamp
+	}
+	err = c.Err()
+	c.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	return ReadJSONL(r, func(snap storage.Snapshot) error {
+		switch {
+		case snap.Timestamp.Before(last):
+			return fmt.Errorf("snapshot at %s is older than %s; imports must be in time order and newer than the stored snapshots",
+				formatTimestamp(snap.Timestamp), formatTimestamp(last))
+		case snap.Timestamp.After(time.Now()):
+			return fmt.Errorf("snapshot at %s is in the future", formatTimestamp(snap.Timestamp))
+		}
+		if err := store.Save(snap); err != nil {
+			return err
+		}
+		last = snap.Timestamp
+		return nil
+	})
+}
+
+// ReadJSONL reads snapshots written by WriteJSONL and calls fn for each in
+// order. Blank lines are skipped. It stops at the first invalid line or
+// error returned by fn, returning the number of snapshots passed to fn.
+func ReadJSONL(r io.Reader, fn func(storage.Snapshot) error) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), MaxLineBytes)
+	n, line := 0, 0
+	for sc.Scan() {
+		line++
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var snap storage.Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if snap.Timestamp.IsZero() {
+			return n, fmt.Errorf("line %d: snapshot has no timestamp", line)
+		}
+		if snap.Metrics == nil {
+			snap.Metrics = map[string]interface{}{}
+		}
+		if err := fn(snap); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		return n, fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,63 @@
+// pkg/export/jsonl_test.go
+
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// jsonlLine returns an import line for a snapshot taken at the given minute.
+func jsonlLine(minute int) string {
+	ts := time.Date(2024, 1, 1, 0, minute, 0, 0, time.UTC).Format(time.RFC3339)
+	return `{"timestamp":"` + ts + `","metrics":{"cpu":1}}` + "\n"
+}
+
+func TestImportInOrder(t *testing.T) {
+	s := storage.NewInMemoryStorageWithRetention(storage.Retention{})
+	n, err := Import(s, strings.NewReader(jsonlLine(1)+"\n"+jsonlLine(2)+jsonlLine(2)+jsonlLine(3)))
+	if err != nil || n != 4 {
+		t.Fatalf("Import = %d, %v; want 4 snapshots", n, err)
+	}
+}
+
+func TestImportOutOfOrder(t *testing.T) {
+	s := storage.NewInMemoryStorageWithRetention(storage.Retention{})
+	n, err := Import(s, strings.NewReader(jsonlLine(1)+jsonlLine(3)+jsonlLine(2)+jsonlLine(4)))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Fatalf("Import error = %v, want one for line 3", err)
+	}
+	if n != 2 {
+		t.Errorf("Import kept %d snapshots, want 2", n)
+	}
+	all, _ := s.GetAll()
+	if len(all) != 2 || !all[1].Timestamp.Equal(time.Date(2024, 1, 1, 0, 3, 0, 0, time.UTC)) {
+		t.Errorf("stored %+v, want the first two lines", all)
+	}
+}
+
+func TestImportOlderThanStored(t *testing.T) {
+	s := storage.NewInMemoryStorageWithRetention(storage.Retention{})
+	if _, err := Import(s, strings.NewReader(jsonlLine(5))); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Import(s, strings.NewReader(jsonlLine(6)+jsonlLine(4)))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") || n != 1 {
+		t.Errorf("Import = %d, %v; want 1 snapshot and an error for line 2", n, err)
+	}
+	n, err = Import(s, strings.NewReader(jsonlLine(1)))
+	if err == nil || n != 0 {
+		t.Errorf("Import = %d, %v; want a snapshot older than the stored ones rejected", n, err)
+	}
+}
+
+func TestImportFuture(t *testing.T) {
+	s := storage.NewInMemoryStorageWithRetention(storage.Retention{})
+	line := `{"timestamp":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `","metrics":{}}`
+	if n, err := Import(s, strings.NewReader(line)); err == nil || n != 0 {
+		t.Errorf("Import = %d, %v; want a future snapshot rejected", n, err)
+	}
+}
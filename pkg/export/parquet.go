@@ -0,0 +1,407 @@
+// pkg/export/parquet.go
+
+package export
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The writer produces Parquet files with a flat schema: a required
+// timestamp column and an optional column per label and metric path. Each
+// row group holds one uncompressed, PLAIN-encoded data page per column.
+
+// Values from parquet.thrift.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain     = 0
+	encodingRLE       = 3
+	codecUncompressed = 0
+	pageTypeData      = 0
+)
+
+// Limits on the rows buffered for one row group.
+const (
+	parquetRowGroupRows  = 10000
+	parquetRowGroupBytes = 64 << 20
+)
+
+// parquetMagic starts and ends a Parquet file.
+const parquetMagic = "PAR1"
+
+// WriteParquet writes the snapshots of open as a Parquet file with the
+// columns of WriteCSV. Timestamps are stored as microseconds since the
+// epoch, numbers as doubles, and labels and text as UTF-8 strings.
+func WriteParquet(w io.Writer, open Opener) (int, error) {
+	columns, err := collectColumns(open)
+	if err != nil {
+		return 0, err
+	}
+	pw := newParquetWriter(w, columns)
+	if err := pw.writeRaw([]byte(parquetMagic)); err != nil {
+		return 0, err
+	}
+
+	c, err := open()
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	n := 0
+	for c.Next() {
+		snap := c.Snapshot()
+		row, err := Flatten(snap)
+		if err != nil {
+			return n, err
+		}
+		if err := pw.add(snap.Timestamp.UnixMicro(), row); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := c.Err(); err != nil {
+		return n, err
+	}
+	return n, pw.close()
+}
+
+// parquetColumn buffers the values of one column for a row group.
+type parquetColumn struct {
+	name      string
+	typ       ColumnType
+	physical  int32
+	required  bool
+	present   []bool // Whether each row has a value; optional columns only.
+	values    []byte // PLAIN-encoded values, except booleans.
+	bools     []bool
+	numValues int
+}
+
+// add appends a row's value, or a null when v is missing or of another type.
+func (c *parquetColumn) add(v interface{}) {
+	c.numValues++
+	ok := false
+	switch c.typ {
+	case Number:
+		if f, isNum := v.(float64); isNum {
+			c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(f))
+			ok = true
+		}
+	case Bool:
+		if b, isBool := v.(bool); isBool {
+			c.bools = append(c.bools, b)
+			ok = true
+		}
+	default:
+		if v != nil {
+			s := formatValue(v)
+			c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(s)))
+			c.values = append(c.values, s...)
+			ok = true
+		}
+	}
+	c.present = append(c.present, ok)
+}
+
+// page returns the column's data page: the definition levels of an
+// optional column followed by its values.
+func (c *parquetColumn) page() []byte {
+	var page []byte
+	if !c.required {
+		levels := appendBitPacked(nil, c.present)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	if c.typ == Bool {
+		return append(page, packBools(nil, c.bools)...)
+	}
+	return append(page, c.values...)
+}
+
+// reset empties the column for the next row group.
+func (c *parquetColumn) reset() {
+	c.present, c.values, c.bools, c.numValues = c.present[:0], c.values[:0], c.bools[:0], 0
+}
+
+// parquetChunk locates a column chunk written to the file.
+type parquetChunk struct {
+	offset, size int64
+	numValues    int
+}
+
+// parquetRowGroup describes a row group written to the file.
+type parquetRowGroup struct {
+	rows   int
+	size   int64
+	chunks []parquetChunk
+}
+
+// parquetWriter writes row groups as rows are added and the footer on close.
+type parquetWriter struct {
+	w       io.Writer
+	off     int64
+	ts      *parquetColumn
+	columns []*parquetColumn
+	rows    int
+	bytes   int
+	total   int64
+	groups  []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer, columns []Column) *parquetWriter {
+	pw := &parquetWriter{
+		w:  w,
+		ts: &parquetColumn{name: TimestampColumn, physical: parquetInt64, required: true},
+	}
+	for _, col := range columns {
+		c := &parquetColumn{name: col.Name, typ: col.Type}
+		switch col.Type {
+		case Number:
+			c.physical = parquetDouble
+		case Bool:
+			c.physical = parquetBoolean
+		default:
+			c.physical = parquetByteArray
+		}
+		pw.columns = append(pw.columns, c)
+	}
+	return pw
+}
+
+func (pw *parquetWriter) writeRaw(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.off += int64(n)
+	return err
+}
+
+// add buffers a row, writing a row group when the limits are reached.
+func (pw *parquetWriter) add(micros int64, row map[string]interface{}) error {
+	pw.ts.numValues++
+	pw.ts.values = binary.LittleEndian.AppendUint64(pw.ts.values, uint64(micros))
+	pw.bytes += 8
+	for _, c := range pw.columns {
+		before := len(c.values)
+		c.add(row[c.name])
+		pw.bytes += len(c.values) - before + 1
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupRows || pw.bytes >= parquetRowGroupBytes {
+		return pw.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (pw *parquetWriter) flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{rows: pw.rows}
+	for _, c := range append([]*parquetColumn{pw.ts}, pw.columns...) {
+		page := c.page()
+		var h compactWriter
+		h.begin(0)
+		h.i32(1, pageTypeData)
+		h.i32(2, int32(len(page)))
+		h.i32(3, int32(len(page)))
+		h.begin(5)
+		h.i32(1, int32(c.numValues))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+
+		chunk := parquetChunk{offset: pw.off, size: int64(len(h.b) + len(page)), numValues: c.numValues}
+		if err := pw.writeRaw(h.b); err != nil {
+			return err
+		}
+		if err := pw.writeRaw(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+		c.reset()
+	}
+	pw.groups = append(pw.groups, group)
+	pw.total += int64(pw.rows)
+	pw.rows, pw.bytes = 0, 0
+	return nil
+}
+
+// close writes the remaining rows and the file footer.
+func (pw *parquetWriter) close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	all := append([]*parquetColumn{pw.ts}, pw.columns...)
+
+	var m compactWriter
+	m.begin(0)
+	m.i32(1, 1)
+	m.list(2, compactStruct, len(all)+1)
+	m.begin(0)
+	m.binary(4, "schema")
+	m.i32(5, int32(len(all)))
+	m.end()
+	for _, c := range all {
+		m.begin(0)
+		m.i32(1, c.physical)
+		if c.required {
+			m.i32(3, repetitionRequired)
+		} else {
+			m.i32(3, repetitionOptional)
+		}
+		m.binary(4, c.name)
+		switch {
+		case c == pw.ts:
+			m.i32(6, convertedTimestampMicros)
+		case c.physical == parquetByteArray:
+			m.i32(6, convertedUTF8)
+		}
+		m.end()
+	}
+	m.i64(3, pw.total)
+	m.list(4, compactStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		m.begin(0)
+		m.list(1, compactStruct, len(g.chunks))
+		for i, chunk := range g.chunks {
+			c := all[i]
+			m.begin(0)
+			m.i64(2, chunk.offset)
+			m.begin(3)
+			m.i32(1, c.physical)
+			m.list(2, compactI32, 2)
+			m.b = binary.AppendVarint(m.b, encodingPlain)
+			m.b = binary.AppendVarint(m.b, encodingRLE)
+			m.list(3, compactBinary, 1)
+			m.appendString(c.name)
+			m.i32(4, codecUncompressed)
+			m.i64(5, int64(chunk.numValues))
+			m.i64(6, chunk.size)
+			m.i64(7, chunk.size)
+			m.i64(9, chunk.offset)
+			m.end()
+			m.end()
+		}
+		m.i64(2, g.size)
+		m.i64(3, int64(g.rows))
+		m.end()
+	}
+	m.binary(6, "sailfin agent")
+	m.end()
+
+	m.b = binary.LittleEndian.AppendUint32(m.b, uint32(len(m.b)))
+	m.b = append(m.b, parquetMagic...)
+	return pw.writeRaw(m.b)
+}
+
+// appendBitPacked appends levels of bit width 1 in the RLE/bit-packing
+// hybrid encoding as a single bit-packed run.
+func appendBitPacked(b []byte, levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+	b = binary.AppendUvarint(b, uint64(groups)<<1|1)
+	return packBools(b, levels)
+}
+
+// packBools appends bits least significant first, padding the last byte.
+func packBools(b []byte, bits []bool) []byte {
+	start := len(b)
+	b = append(b, make([]byte, (len(bits)+7)/8)...)
+	for i, bit := range bits {
+		if bit {
+			b[start+i/8] |= 1 << (i % 8)
+		}
+	}
+	return b
+}
+
+// Thrift compact protocol types.
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes Thrift structs in the compact protocol, which
+// Parquet uses for page headers and the file footer. Fields must be written
+// in increasing order of id.
+type compactWriter struct {
+	b     []byte
+	last  int16
+	stack []int16
+}
+
+// field writes a field header.
+func (w *compactWriter) field(id int16, typ byte) {
+	if d := id - w.last; d > 0 && d <= 15 {
+		w.b = append(w.b, byte(d)<<4|typ)
+	} else {
+		w.b = append(w.b, typ)
+		w.b = binary.AppendVarint(w.b, int64(id))
+	}
+	w.last = id
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.b = binary.AppendVarint(w.b, int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.b = binary.AppendVarint(w.b, v)
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, compactBinary)
+	w.appendString(s)
+}
+
+func (w *compactWriter) appendString(s string) {
+	w.b = binary.AppendUvarint(w.b, uint64(len(s)))
+	w.b = append(w.b, s...)
+}
+
+// list writes the header of a list field with n elements, which the caller
+// writes next.
+func (w *compactWriter) list(id int16, elem byte, n int) {
+	w.field(id, compactList)
+	if n < 15 {
+		w.b = append(w.b, byte(n)<<4|elem)
+		return
+	}
+	w.b = append(w.b, 0xf0|elem)
+	w.b = binary.AppendUvarint(w.b, uint64(n))
+}
+
+// begin starts a struct: a struct field, or with id 0 the top-level struct
+// or a list element.
+func (w *compactWriter) begin(id int16) {
+	if id > 0 {
+		w.field(id, compactStruct)
+	}
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+// end writes the stop field of the current struct.
+func (w *compactWriter) end() {
+	w.b = append(w.b, 0)
+	w.last = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
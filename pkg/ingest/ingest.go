@@ -0,0 +1,116 @@
+// pkg/ingest/ingest.go
+
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Limits applied to ingested metrics.
+const (
+	MaxNameLength   = 200
+	MaxLabels       = 32
+	MaxLabelLength  = 256
+	MaxFields       = 64
+	MaxBatchPoints  = 10000
+	DefaultCapacity = 100000
+)
+
+// Point is one custom measurement.
+type Point struct {
+	Name      string             `json:"name"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Fields    map[string]float64 `json:"fields"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// validName matches metric, label and field names.
+var validName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.:-]*$`)
+
+// Validate checks names, label values and field values against the limits.
+func (p *Point) Validate() error {
+	if len(p.Name) > MaxNameLength || !validName.MatchString(p.Name) {
+		return fmt.Errorf("invalid metric name %q", p.Name)
+	}
+	if len(p.Labels) > MaxLabels {
+		return fmt.Errorf("metric %s has %d labels, more than %d", p.Name, len(p.Labels), MaxLabels)
+	}
+	for k, v := range p.Labels {
+		if len(k) > MaxNameLength || !validName.MatchString(k) {
+			return fmt.Errorf("metric %s: invalid label name %q", p.Name, k)
+		}
+		if len(v) > MaxLabelLength {
+			return fmt.Errorf("metric %s: label %s is longer than %d bytes", p.Name, k, MaxLabelLength)
+		}
+	}
+	if len(p.Fields) == 0 {
+		return fmt.Errorf("metric %s has no fields", p.Name)
+	}
+	if len(p.Fields) > MaxFields {
+		return fmt.Errorf("metric %s has %d fields, more than %d", p.Name, len(p.Fields), MaxFields)
+	}
+	for k, v := range p.Fields {
+		if len(k) > MaxNameLength || !validName.MatchString(k) {
+			return fmt.Errorf("metric %s: invalid field name %q", p.Name, k)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("metric %s: field %s is not a finite number", p.Name, k)
+		}
+	}
+	return nil
+}
+
+// BatchError lists the problems found in a rejected batch.
+type BatchError struct {
+	Errors []string
+}
+
+// Error summarizes the batch errors.
+func (e *BatchError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0]
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e.Errors[0], len(e.Errors)-1)
+}
+
+// Buffer holds ingested points until they are merged into a snapshot.
+type Buffer struct {
+	mu       sync.Mutex
+	capacity int
+	points   []Point
+	dropped  uint64
+}
+
+// NewBuffer returns a buffer holding at most capacity points. When full,
+// the oldest points are dropped.
+func NewBuffer(capacity int) *Buffer {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Buffer{capacity: capacity}
+}
+
+// Add appends points to the buffer.
+func (b *Buffer) Add(points []Point) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.points = append(b.points, points...)
+	if over := len(b.points) - b.capacity; over > 0 {
+		b.points = append(b.points[:0], b.points[over:]...)
+		b.dropped += uint64(over)
+	}
+}
+
+// Drain returns and clears the buffered points and the number of points
+// dropped since the last drain.
+func (b *Buffer) Drain() ([]Point, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	points, dropped := b.points, b.dropped
+	b.points, b.dropped = nil, 0
+	return points, dropped
+}
@@ -0,0 +1,101 @@
+// pkg/ingest/json.go
+
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// jsonBatch is the JSON write format:
+//
+//	{"metrics": [
+//	  {"name": "deploys", "labels": {"app": "web"}, "value": 1},
+//	  {"name": "orders", "fields": {"count": 12, "revenue": 340.5}, "timestamp": 1700000000}
+//	]}
+//
+// A bare array of metrics is also accepted.
+type jsonBatch struct {
+	Metrics []jsonPoint `json:"metrics"`
+}
+
+// jsonPoint is one metric in a JSON batch. Value is shorthand for a single
+// field named "value". Timestamp is Unix seconds or an RFC 3339 string.
+type jsonPoint struct {
+	Name      string             `json:"name"`
+	Labels    map[string]string  `json:"labels"`
+	Value     *float64           `json:"value"`
+	Fields    map[string]float64 `json:"fields"`
+	Timestamp json.RawMessage    `json:"timestamp"`
+}
+
+// ParseJSON parses a JSON batch. Every metric is validated; on any error no
+// points are returned.
+func ParseJSON(r io.Reader, now time.Time) ([]Point, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, &BatchError{Errors: []string{"invalid JSON: " + err.Error()}}
+	}
+	var batch jsonBatch
+	if len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &batch.Metrics); err != nil {
+			return nil, &BatchError{Errors: []string{"invalid JSON: " + err.Error()}}
+		}
+	} else if err := json.Unmarshal(raw, &batch); err != nil {
+		return nil, &BatchError{Errors: []string{"invalid JSON: " + err.Error()}}
+	}
+	if len(batch.Metrics) > MaxBatchPoints {
+		return nil, &BatchError{Errors: []string{fmt.Sprintf("batch exceeds %d points", MaxBatchPoints)}}
+	}
+
+	points := make([]Point, 0, len(batch.Metrics))
+	var errs []string
+	for i, m := range batch.Metrics {
+		p := Point{Name: m.Name, Labels: m.Labels, Fields: map[string]float64{}, Timestamp: now}
+		for k, v := range m.Fields {
+			p.Fields[k] = v
+		}
+		if m.Value != nil {
+			p.Fields["value"] = *m.Value
+		}
+		err := parseJSONTimestamp(m.Timestamp, &p.Timestamp)
+		if err == nil {
+			err = p.Validate()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("metric %d: %v", i, err))
+			continue
+		}
+		points = append(points, p)
+	}
+	if len(errs) > 0 {
+		return nil, &BatchError{Errors: errs}
+	}
+	return points, nil
+}
+
+// parseJSONTimestamp decodes Unix seconds (possibly fractional) or an RFC
+// 3339 string into dst. An absent timestamp leaves dst unchanged.
+func parseJSONTimestamp(raw json.RawMessage, dst *time.Time) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", s)
+		}
+		*dst = t
+		return nil
+	}
+	secs, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s", raw)
+	}
+	*dst = time.Unix(0, int64(secs*1e9))
+	return nil
+}
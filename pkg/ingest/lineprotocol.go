@@ -0,0 +1,173 @@
+// pkg/ingest/lineprotocol.go
+
+package ingest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// precisions maps the Influx precision parameter to a timestamp unit.
+var precisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// ParseLineProtocol parses InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// Integer ("12i"), unsigned ("12u"), float and boolean fields are accepted;
+// string fields are rejected. Timestamps are in the given precision ("ns",
+// "us", "ms" or "s") and default to now. Every line is validated; on any
+// error no points are returned.
+func ParseLineProtocol(r io.Reader, precision string, now time.Time) ([]Point, error) {
+	unit, ok := precisions[precision]
+	if !ok {
+		return nil, fmt.Errorf("invalid precision %q", precision)
+	}
+	var points []Point
+	var errs []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseLine(line, unit, now)
+		if err == nil {
+			err = p.Validate()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		points = append(points, p)
+		if len(points) > MaxBatchPoints {
+			return nil, &BatchError{Errors: []string{fmt.Sprintf("batch exceeds %d points", MaxBatchPoints)}}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &BatchError{Errors: errs}
+	}
+	return points, nil
+}
+
+// parseLine parses one line of line protocol.
+func parseLine(line string, unit time.Duration, now time.Time) (Point, error) {
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return Point{}, fmt.Errorf("expected measurement, fields and optional timestamp")
+	}
+
+	keys := splitUnescaped(sections[0], ',', false)
+	p := Point{Name: unescape(keys[0]), Fields: map[string]float64{}, Timestamp: now}
+	for _, kv := range keys[1:] {
+		k, v, ok := cutUnescaped(kv, '=')
+		if !ok || k == "" || v == "" {
+			return Point{}, fmt.Errorf("invalid tag %q", kv)
+		}
+		if p.Labels == nil {
+			p.Labels = map[string]string{}
+		}
+		p.Labels[unescape(k)] = unescape(v)
+	}
+
+	for _, kv := range splitUnescaped(sections[1], ',', true) {
+		k, v, ok := cutUnescaped(kv, '=')
+		if !ok || k == "" || v == "" {
+			return Point{}, fmt.Errorf("invalid field %q", kv)
+		}
+		f, err := parseFieldValue(v)
+		if err != nil {
+			return Point{}, fmt.Errorf("field %s: %v", unescape(k), err)
+		}
+		p.Fields[unescape(k)] = f
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		p.Timestamp = time.Unix(0, ts*int64(unit))
+	}
+	return p, nil
+}
+
+// parseFieldValue converts a line protocol field value to a float.
+func parseFieldValue(v string) (float64, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		return 0, fmt.Errorf("string fields are not supported")
+	case v == "t" || v == "T" || v == "true" || v == "True" || v == "TRUE":
+		return 1, nil
+	case v == "f" || v == "F" || v == "false" || v == "False" || v == "FALSE":
+		return 0, nil
+	case strings.HasSuffix(v, "i"):
+		n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		return float64(n), err
+	case strings.HasSuffix(v, "u"):
+		n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		return float64(n), err
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// splitUnescaped splits s on sep, ignoring separators escaped with a
+// backslash and, when quotes is set, inside double-quoted strings.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start, inQuote := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			inQuote = !inQuote
+		case c == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cutUnescaped splits s around the first unescaped sep.
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescape removes backslash escapes of commas, spaces and equals signs.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`, =`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
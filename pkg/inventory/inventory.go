@@ -0,0 +1,423 @@
+// pkg/inventory/inventory.go
+
+package inventory
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/collector"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+)
+
+// Facts holds slow-changing information about the host.
+type Facts struct {
+	GatheredAt     time.Time         `json:"gatheredAt"`
+	Hostname       string            `json:"hostname"`
+	HostID         string            `json:"hostId,omitempty"`
+	OS             OSRelease         `json:"os"`
+	Kernel         Kernel            `json:"kernel"`
+	BootTime       time.Time         `json:"bootTime"`
+	CPU            CPU               `json:"cpu"`
+	MemoryBytes    uint64            `json:"memoryBytes"`
+	BlockDevices   []BlockDevice     `json:"blockDevices"`
+	NICs           []NIC             `json:"nics"`
+	Virtualization Virtualization    `json:"virtualization"`
+	DMI            map[string]string `json:"dmi,omitempty"`
+	Agent          AgentRuntime      `json:"agent"`
+	Errors         []string          `json:"errors,omitempty"`
+}
+
+// OSRelease is the operating system identification from /etc/os-release.
+type OSRelease struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	VersionID  string `json:"versionId"`
+	PrettyName string `json:"prettyName"`
+	Family     string `json:"family,omitempty"`
+}
+
+// Kernel describes the running kernel.
+type Kernel struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+}
+
+// CPU describes the installed processors.
+type CPU struct {
+	Vendor         string   `json:"vendor"`
+	Model          string   `json:"model"`
+	Sockets        int      `json:"sockets"`
+	PhysicalCores  int      `json:"physicalCores"`
+	LogicalCores   int      `json:"logicalCores"`
+	MHz            float64  `json:"mhz"`
+	CacheSizeBytes int64    `json:"cacheSizeBytes"`
+	Flags          []string `json:"flags"`
+}
+
+// BlockDevice describes a disk.
+type BlockDevice struct {
+	Name       string `json:"name"`
+	SizeBytes  uint64 `json:"sizeBytes"`
+	Model      string `json:"model,omitempty"`
+	Vendor     string `json:"vendor,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Rotational bool   `json:"rotational"`
+	Removable  bool   `json:"removable"`
+}
+
+// NIC describes a network interface.
+type NIC struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	MTU       int      `json:"mtu"`
+	State     string   `json:"state,omitempty"`
+	SpeedMbps int      `json:"speedMbps,omitempty"`
+	Driver    string   `json:"driver,omitempty"`
+	Virtual   bool     `json:"virtual"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Virtualization describes the hypervisor or container technology in use.
+type Virtualization struct {
+	System string `json:"system,omitempty"`
+	Role   string `json:"role,omitempty"`
+}
+
+// AgentRuntime describes how the agent itself is deployed: whether it runs
+// in a container and where it reads the host's filesystems.
+type AgentRuntime struct {
+	Container collector.ContainerInfo `json:"container"`
+	HostRoots collector.HostRoots     `json:"hostRoots"`
+}
+
+// dmiFields are the /sys/class/dmi/id entries reported. Some of them are
+// only readable by root and are silently skipped otherwise.
+var dmiFields = []string{
+	"sys_vendor",
+	"product_name",
+	"product_version",
+	"product_serial",
+	"product_uuid",
+	"board_vendor",
+	"board_name",
+	"bios_vendor",
+	"bios_version",
+	"bios_date",
+	"chassis_type",
+	"chassis_vendor",
+}
+
+// Gather collects host facts. Partial failures are recorded in Facts.Errors
+// so that one unreadable source does not hide the rest.
+func Gather() *Facts {
+	f := &Facts{GatheredAt: time.Now()}
+	addErr := func(what string, err error) {
+		f.Errors = append(f.Errors, what+": "+err.Error())
+	}
+
+	if info, err := host.Info(); err != nil {
+		addErr("host", err)
+	} else {
+		f.Hostname = info.Hostname
+		f.HostID = info.HostID
+		f.BootTime = time.Unix(int64(info.BootTime), 0).UTC()
+		f.Kernel = Kernel{Name: info.OS, Version: info.KernelVersion, Arch: info.KernelArch}
+		f.OS.ID = info.Platform
+		f.OS.VersionID = info.PlatformVersion
+		f.OS.Family = info.PlatformFamily
+		f.Virtualization = Virtualization{System: info.VirtualizationSystem, Role: info.VirtualizationRole}
+	}
+	if f.Kernel.Arch == "" {
+		f.Kernel.Arch = runtime.GOARCH
+	}
+	if rel, err := ReadOSRelease(collector.HostPath("/etc/os-release")); err == nil {
+		family := f.OS.Family
+		f.OS = rel
+		f.OS.Family = family
+	} else if !os.IsNotExist(err) {
+		addErr("os-release", err)
+	}
+
+	if infos, err := cpu.Info(); err != nil {
+		addErr("cpu", err)
+	} else {
+		f.CPU = summarizeCPU(infos)
+	}
+	if n, err := cpu.Counts(true); err == nil {
+		f.CPU.LogicalCores = n
+	}
+
+	if vm, err := mem.VirtualMemory(); err != nil {
+		addErr("memory", err)
+	} else {
+		f.MemoryBytes = vm.Total
+	}
+
+	sysRoot := collector.HostSys()
+	if devs, err := ReadBlockDevices(sysRoot); err != nil && !os.IsNotExist(err) {
+		addErr("block devices", err)
+	} else {
+		f.BlockDevices = devs
+	}
+	nics, err := ReadNICs(sysRoot)
+	if os.IsNotExist(err) {
+		// Hosts without sysfs, such as macOS and Windows.
+		nics, err = interfaceNICs()
+	}
+	if err != nil {
+		addErr("network interfaces", err)
+	} else {
+		attachAddresses(nics)
+		f.NICs = nics
+	}
+	f.DMI = ReadDMI(sysRoot)
+	f.Agent = AgentRuntime{Container: collector.DetectContainer(), HostRoots: collector.CurrentHostRoots()}
+	return f
+}
+
+// summarizeCPU reduces per-processor entries to a single description.
+func summarizeCPU(infos []cpu.InfoStat) CPU {
+	c := CPU{}
+	if len(infos) == 0 {
+		return c
+	}
+	first := infos[0]
+	c.Vendor = first.VendorID
+	c.Model = first.ModelName
+	c.MHz = first.Mhz
+	c.CacheSizeBytes = int64(first.CacheSize) * 1024
+	c.Flags = first.Flags
+
+	sockets := map[string]bool{}
+	cores := map[string]bool{}
+	var reported int32
+	for _, info := range infos {
+		sockets[info.PhysicalID] = true
+		cores[info.PhysicalID+"/"+info.CoreID] = true
+		reported += info.Cores
+	}
+	c.Sockets = len(sockets)
+	c.PhysicalCores = len(cores)
+	if len(infos) == c.Sockets && reported > 0 {
+		// Platforms such as macOS report one entry per socket with a core count.
+		c.PhysicalCores = int(reported)
+	}
+	return c
+}
+
+// ReadOSRelease parses an os-release file.
+func ReadOSRelease(path string) (OSRelease, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return OSRelease{}, err
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unq, err := strconv.Unquote(v); err == nil {
+			v = unq
+		} else {
+			v = strings.Trim(v, `"'`)
+		}
+		values[k] = v
+	}
+	return OSRelease{
+		ID:         values["ID"],
+		Name:       values["NAME"],
+		Version:    values["VERSION"],
+		VersionID:  values["VERSION_ID"],
+		PrettyName: values["PRETTY_NAME"],
+	}, scanner.Err()
+}
+
+// ReadBlockDevices lists disks from <sysRoot>/block, skipping loop, ram and
+// device-mapper pseudo devices.
+func ReadBlockDevices(sysRoot string) ([]BlockDevice, error) {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "block"))
+	if err != nil {
+		return nil, err
+	}
+	devs := []BlockDevice{}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "dm-") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		dir := filepath.Join(sysRoot, "block", name)
+		dev := BlockDevice{
+			Name:       name,
+			Model:      readSysString(filepath.Join(dir, "device", "model")),
+			Vendor:     readSysString(filepath.Join(dir, "device", "vendor")),
+			Serial:     readSysString(filepath.Join(dir, "device", "serial")),
+			Rotational: readSysString(filepath.Join(dir, "queue", "rotational")) == "1",
+			Removable:  readSysString(filepath.Join(dir, "removable")) == "1",
+		}
+		// The size file is always in 512-byte sectors.
+		if sectors, err := strconv.ParseUint(readSysString(filepath.Join(dir, "size")), 10, 64); err == nil {
+			dev.SizeBytes = sectors * 512
+		}
+		devs = append(devs, dev)
+	}
+	return devs, nil
+}
+
+// ReadNICs lists network interfaces from <sysRoot>/class/net, excluding loopback.
+func ReadNICs(sysRoot string) ([]NIC, error) {
+	base := filepath.Join(sysRoot, "class", "net")
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+	nics := []NIC{}
+	for _, e := range entries {
+		name := e.Name()
+		if name == "lo" {
+			continue
+		}
+		dir := filepath.Join(base, name)
+		nic := NIC{
+			Name:  name,
+			MAC:   readSysString(filepath.Join(dir, "address")),
+			State: readSysString(filepath.Join(dir, "operstate")),
+		}
+		nic.MTU, _ = strconv.Atoi(readSysString(filepath.Join(dir, "mtu")))
+		// Speed is -1 or unreadable for interfaces that are down or virtual.
+		if speed, err := strconv.Atoi(readSysString(filepath.Join(dir, "speed"))); err == nil && speed > 0 {
+			nic.SpeedMbps = speed
+		}
+		if target, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+			nic.Driver = filepath.Base(target)
+		}
+		// Physical interfaces have a backing device; bridges, veths and
+		// tunnels do not.
+		if _, err := os.Stat(filepath.Join(dir, "device")); err != nil {
+			nic.Virtual = true
+		}
+		nics = append(nics, nic)
+	}
+	return nics, nil
+}
+
+// interfaceNICs lists network interfaces from the operating system,
+// excluding loopback, for hosts without sysfs. Only the name, MAC, MTU and
+// state are known.
+func interfaceNICs() ([]NIC, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	nics := []NIC{}
+	for _, iface := range ifaces {
+		nic := NIC{Name: iface.Name, MAC: iface.HardwareAddr, MTU: iface.MTU, State: "down"}
+		loopback := false
+		for _, flag := range iface.Flags {
+			switch flag {
+			case "loopback":
+				loopback = true
+			case "up":
+				nic.State = "up"
+			}
+		}
+		if !loopback {
+			nics = append(nics, nic)
+		}
+	}
+	return nics, nil
+}
+
+// attachAddresses adds IP addresses to interfaces visible in the agent's
+// network namespace.
+func attachAddresses(nics []NIC) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	addrs := map[string][]string{}
+	for _, iface := range ifaces {
+		for _, a := range iface.Addrs {
+			addrs[iface.Name] = append(addrs[iface.Name], a.Addr)
+		}
+	}
+	for i := range nics {
+		nics[i].Addresses = addrs[nics[i].Name]
+		sort.Strings(nics[i].Addresses)
+	}
+}
+
+// ReadDMI reads the DMI product information from <sysRoot>/class/dmi/id.
+func ReadDMI(sysRoot string) map[string]string {
+	dir := filepath.Join(sysRoot, "class", "dmi", "id")
+	out := map[string]string{}
+	for _, field := range dmiFields {
+		if v := readSysString(filepath.Join(dir, field)); v != "" {
+			out[field] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// readSysString reads a sysfs attribute, returning "" if it is unreadable.
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Inventory caches the most recently gathered facts.
+type Inventory struct {
+	mu    sync.Mutex
+	facts *Facts
+}
+
+// New returns an empty Inventory.
+func New() *Inventory {
+	return &Inventory{}
+}
+
+// Refresh gathers the facts again and caches them.
+func (i *Inventory) Refresh() *Facts {
+	facts := Gather()
+	i.mu.Lock()
+	i.facts = facts
+	i.mu.Unlock()
+	return facts
+}
+
+// Get returns the cached facts, gathering them first if necessary.
+func (i *Inventory) Get() *Facts {
+	i.mu.Lock()
+	facts := i.facts
+	i.mu.Unlock()
+	if facts == nil {
+		return i.Refresh()
+	}
+	return facts
+}
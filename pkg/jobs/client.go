@@ -0,0 +1,72 @@
+// pkg/jobs/client.go
+
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader carries the agent API key on authenticated requests.
+const APIKeyHeader = "X-API-Key"
+
+// Client reports runs to a running agent.
+type Client struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewClient returns a client for the agent listening on serverAddress, as
+// configured in AgentConfig. Wildcard listen addresses are reached through
+// the loopback interface.
+func NewClient(serverAddress, apiKey string) *Client {
+	return &Client{
+		baseURL: agentURL(serverAddress),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// agentURL turns a listen address into a base URL.
+func agentURL(addr string) string {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return strings.TrimRight(addr, "/")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// Report posts a run to the agent's /jobs/runs endpoint.
+func (c *Client) Report(run Run) error {
+	body, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/jobs/runs", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(APIKeyHeader, c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// pkg/jobs/history.go
+
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of runs kept per job.
+const DefaultHistorySize = 100
+
+// Expectation is the schedule a job is expected to run on.
+type Expectation struct {
+	Job      string
+	Schedule string
+	// Grace is how late a run may start before it is considered missed.
+	Grace time.Duration
+}
+
+// Status summarizes a job's recent runs and whether it is overdue.
+type Status struct {
+	Job                 string     `json:"job"`
+	Schedule            string     `json:"schedule,omitempty"`
+	Runs                int        `json:"runs"`
+	LastRun             *Run       `json:"lastRun,omitempty"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	NextExpectedAt      *time.Time `json:"nextExpectedAt,omitempty"`
+	Missed              bool       `json:"missed"`
+}
+
+// scheduledJob is a parsed expectation.
+type scheduledJob struct {
+	expr     string
+	schedule Schedule
+	grace    time.Duration
+}
+
+// History keeps the most recent runs of each job, persisted to a JSON file.
+type History struct {
+	mu sync.RWMutex
+	// writeMu serializes saves, so an older history is never written
+	// after a newer one. It is taken before mu.
+	writeMu  sync.Mutex
+	path     string
+	maxRuns  int
+	since    time.Time // Reference point for jobs that have never run.
+	runs     map[string][]Run
+	expected map[string]scheduledJob
+}
+
+// NewHistory returns a history persisted at path, loading any runs saved
+// there. An empty path keeps history in memory only.
+func NewHistory(path string, maxRuns int) (*History, error) {
+	if maxRuns <= 0 {
+		maxRuns = DefaultHistorySize
+	}
+	h := &History{
+		path:     path,
+		maxRuns:  maxRuns,
+		since:    time.Now(),
+		runs:     make(map[string][]Run),
+		expected: make(map[string]scheduledJob),
+	}
+	if path == "" {
+		return h, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &h.runs); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetExpectations replaces the expected schedules. It fails without
+// changing anything if any schedule is invalid.
+func (h *History) SetExpectations(expectations []Expectation) error {
+	expected := make(map[string]scheduledJob, len(expectations))
+	for _, e := range expectations {
+		s, err := ParseSchedule(e.Schedule)
+		if err != nil {
+			return errors.New("job " + e.Job + ": " + err.Error())
+		}
+		expected[e.Job] = scheduledJob{expr: e.Schedule, schedule: s, grace: e.Grace}
+	}
+	h.mu.Lock()
+	h.expected = expected
+	h.mu.Unlock()
+	return nil
+}
+
+// Record adds a run and persists the history.
+func (h *History) Record(run Run) error {
+	if run.Job == "" {
+		return errors.New("run has no job name")
+	}
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+	h.mu.Lock()
+	runs := append(h.runs[run.Job], run)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	if len(runs) > h.maxRuns {
+		runs = runs[len(runs)-h.maxRuns:]
+	}
+	h.runs[run.Job] = runs
+	data, err := json.Marshal(h.runs)
+	h.mu.Unlock()
+	if err != nil || h.path == "" {
+		return err
+	}
+	return writeFileAtomic(h.path, data)
+}
+
+// Runs returns the recorded runs of a job, newest first.
+func (h *History) Runs(job string) []Run {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	runs := h.runs[job]
+	out := make([]Run, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		out = append(out, runs[i])
+	}
+	return out
+}
+
+// Status returns the status of every job that has run or is expected,
+// sorted by name.
+func (h *History) Status(now time.Time) []Status {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make(map[string]bool)
+	for name := range h.runs {
+		names[name] = true
+	}
+	for name := range h.expected {
+		names[name] = true
+	}
+
+	out := make([]Status, 0, len(names))
+	for name := range names {
+		out = append(out, h.status(name, now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Job < out[j].Job })
+	return out
+}
+
+// status builds the Status of one job. The caller holds h.mu.
+func (h *History) status(name string, now time.Time) Status {
+	runs := h.runs[name]
+	st := Status{Job: name, Runs: len(runs)}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Success {
+			t := runs[i].StartedAt
+			st.LastSuccessAt = &t
+			break
+		}
+		st.ConsecutiveFailures++
+	}
+
+	ref := h.since
+	if len(runs) > 0 {
+		last := runs[len(runs)-1]
+		st.LastRun = &last
+		ref = last.StartedAt
+	}
+	if exp, ok := h.expected[name]; ok {
+		st.Schedule = exp.expr
+		if next := exp.schedule.Next(ref); !next.IsZero() {
+			st.NextExpectedAt = &next
+			st.Missed = now.After(next.Add(exp.grace))
+		}
+	}
+	return st
+}
+
+// writeFileAtomic writes data to path through a temporary file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
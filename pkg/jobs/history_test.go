@@ -0,0 +1,46 @@
+// pkg/jobs/history_test.go
+
+package jobs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHistoryConcurrentRecord checks that every run recorded concurrently
+// is in the persisted history.
+func TestHistoryConcurrentRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, err := NewHistory(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const jobs, runs = 8, 10
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			for i := 0; i < runs; i++ {
+				run := Run{Job: fmt.Sprintf("job-%d", j), StartedAt: time.Unix(int64(i), 0), Success: true}
+				if err := h.Record(run); err != nil {
+					t.Error(err)
+				}
+			}
+		}(j)
+	}
+	wg.Wait()
+
+	loaded, err := NewHistory(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < jobs; j++ {
+		if n := len(loaded.Runs(fmt.Sprintf("job-%d", j))); n != runs {
+			t.Errorf("job-%d has %d persisted runs, want %d", j, n, runs)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// pkg/jobs/run.go
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTailBytes is how much trailing output is kept from a run.
+const DefaultTailBytes = 4096
+
+// Run is the record of one execution of a job.
+type Run struct {
+	Job        string    `json:"job"`
+	Command    []string  `json:"command"`
+	Host       string    `json:"host,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DurationMs int64     `json:"durationMs"`
+	ExitCode   int       `json:"exitCode"`
+	Success    bool      `json:"success"`
+	Output     string    `json:"output,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu        sync.Mutex
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+// Write appends p, discarding the oldest bytes beyond the limit.
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the retained output.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// Execute runs args as job name, passing its output through to stdout and
+// stderr while keeping the last tailBytes of the combined output. A command
+// that cannot be started is recorded with exit code -1.
+func Execute(ctx context.Context, name string, args []string, stdout, stderr io.Writer, tailBytes int) Run {
+	if tailBytes <= 0 {
+		tailBytes = DefaultTailBytes
+	}
+	if name == "" {
+		name = DefaultJobName(args)
+	}
+	run := Run{Job: name, Command: args}
+	run.Host, _ = os.Hostname()
+
+	tail := &tailBuffer{max: tailBytes}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(stdout, tail)
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+
+	run.StartedAt = time.Now()
+	err := cmd.Run()
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Output = tail.String()
+	run.Truncated = tail.truncated
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		run.Success = true
+	case errors.As(err, &exitErr):
+		run.ExitCode = exitErr.ExitCode()
+		if run.ExitCode < 0 {
+			// Killed by a signal.
+			run.Error = exitErr.Error()
+		}
+	default:
+		run.ExitCode = -1
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// DefaultJobName derives a job name from the command's base name.
+func DefaultJobName(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+}
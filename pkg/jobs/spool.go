@@ -0,0 +1,80 @@
+// pkg/jobs/spool.go
+
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Spool holds runs that could not be delivered to the agent, one JSON file
+// per run, until they can be sent.
+type Spool struct {
+	dir string
+}
+
+// NewSpool returns a spool stored in dir.
+func NewSpool(dir string) *Spool {
+	return &Spool{dir: dir}
+}
+
+// Add writes run to the spool.
+func (s *Spool) Add(run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%d.json", run.FinishedAt.UnixNano(), os.Getpid())
+	tmp := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+// Drain passes spooled runs to send, oldest first, removing each one that
+// is sent successfully. It stops at the first send error and returns the
+// number of runs delivered. Unreadable files are discarded.
+func (s *Spool) Drain(send func(Run) error) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	sent := 0
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(data, &run); err != nil {
+			os.Remove(path)
+			continue
+		}
+		if err := send(run); err != nil {
+			return sent, err
+		}
+		os.Remove(path)
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,376 @@
+// pkg/query/query.go
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// Limits on a single query.
+const (
+	MaxPoints  = 11000   // Steps per series.
+	MaxSeries  = 1000    // Distinct series across all selectors.
+	MaxSamples = 2000000 // Samples buffered across all series.
+)
+
+// Aggregations lists the supported aggregation functions. last is the final
+// value of a step and count its number of samples. rate is the per-second
+// increase of a counter, allowing for resets; delta is the change of a
+// gauge. Both compare against the last sample of the previous step when
+// there is one.
+var Aggregations = []string{"avg", "min", "max", "last", "count", "p50", "p95", "p99", "rate", "delta"}
+
+// Query selects series from a time range and aggregates them per step.
+type Query struct {
+	Selectors    []Selector
+	From, To     time.Time
+	Step         time.Duration
+	Aggregations []string
+	// Rollup reports that the snapshots come from a rollup tier, whose
+	// buckets keep several statistics of each number. Each aggregation is
+	// then computed from the statistic of RollupStatistic.
+	Rollup bool
+}
+
+// RollupStatistic returns the bucket statistic an aggregation is computed
+// from over a rollup tier: the minimum of the bucket minimums, the maximum
+// of the maximums, the sum of the counts, and the average of the averages.
+// last, rate and delta read the last value of each bucket. Percentiles
+// cannot be computed from buckets and are rejected by Validate.
+func RollupStatistic(agg string) storage.Statistic {
+	switch agg {
+	case "min":
+		return storage.StatMin
+	case "max":
+		return storage.StatMax
+	case "last", "rate", "delta":
+		return storage.StatLast
+	case "count":
+		return storage.StatCount
+	}
+	return storage.StatAvg
+}
+
+// rollupAggregation reports whether an aggregation can be computed over a
+// rollup tier.
+func rollupAggregation(agg string) bool {
+	return !strings.HasPrefix(agg, "p")
+}
+
+// Statistics returns the statistics the query reads from each snapshot:
+// those of its aggregations over a rollup tier, and only the default for
+// raw snapshots.
+func (q Query) Statistics() []storage.Statistic {
+	if !q.Rollup {
+		return []storage.Statistic{""}
+	}
+	var out []storage.Statistic
+	seen := map[storage.Statistic]bool{}
+	for _, agg := range q.Aggregations {
+		if stat := RollupStatistic(agg); !seen[stat] {
+			seen[stat] = true
+			out = append(out, stat)
+		}
+	}
+	return out
+}
+
+// statistic returns the statistic the samples of an aggregation are read
+// from.
+func (q Query) statistic(agg string) storage.Statistic {
+	if !q.Rollup {
+		return ""
+	}
+	return RollupStatistic(agg)
+}
+
+// Validate checks the query against the supported functions and limits.
+func (q Query) Validate() error {
+	if len(q.Selectors) == 0 {
+		return fmt.Errorf("at least one metric selector is required")
+	}
+	if !q.To.After(q.From) {
+		return fmt.Errorf("the end of the range must be after its start")
+	}
+	if q.Step < time.Second || q.Step%time.Second != 0 {
+		return fmt.Errorf("step must be a whole number of seconds")
+	}
+	if steps := int64(q.To.Sub(q.From) / q.Step); steps >= MaxPoints {
+		return fmt.Errorf("the range has %d steps; at most %d are allowed", steps+1, MaxPoints)
+	}
+	if len(q.Aggregations) == 0 {
+		return fmt.Errorf("at least one aggregation is required")
+	}
+	for _, agg := range q.Aggregations {
+		if !knownAggregation(agg) {
+			return fmt.Errorf("unknown aggregation %q; supported: %s", agg, strings.Join(Aggregations, ", "))
+		}
+		if q.Rollup && !rollupAggregation(agg) {
+			return fmt.Errorf("aggregation %q cannot be computed from rollup buckets; query raw snapshots with resolution=raw", agg)
+		}
+	}
+	return nil
+}
+
+// knownAggregation reports whether name is one of Aggregations.
+func knownAggregation(name string) bool {
+	for _, agg := range Aggregations {
+		if agg == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is the compact form of a query's output. Every series has one
+// value per timestamp and aggregation, null where the step had no data.
+type Result struct {
+	Start      int64    `json:"start"` // Unix seconds.
+	End        int64    `json:"end"`
+	Step       int64    `json:"step"`       // Seconds.
+	Timestamps []int64  `json:"timestamps"` // Start of each step, in Unix seconds.
+	Series     []Series `json:"series"`
+}
+
+// Series is one selected series.
+type Series struct {
+	Selector string                `json:"selector"`
+	Path     string                `json:"path"`
+	Labels   map[string]string     `json:"labels,omitempty"`
+	Values   map[string][]*float64 `json:"values"`
+}
+
+// sample is one value of a series.
+type sample struct {
+	t float64 // Unix seconds.
+	v float64
+}
+
+// series accumulates the samples of one selected series, by statistic.
+type series struct {
+	selector string
+	path     string
+	labels   map[string]string
+	samples  map[storage.Statistic][]sample
+}
+
+// Evaluator runs a query over snapshots fed to it in any order.
+type Evaluator struct {
+	q       Query
+	series  map[string]*series
+	order   []string
+	samples int
+}
+
+// NewEvaluator validates q and returns an evaluator for it.
+func NewEvaluator(q Query) (*Evaluator, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return &Evaluator{q: q, series: map[string]*series{}}, nil
+}
+
+// Add selects the values of a snapshot whose numbers hold stat, one of the
+// query's Statistics. It fails once the query exceeds MaxSeries or
+// MaxSamples.
+func (e *Evaluator) Add(snap storage.Snapshot, stat storage.Statistic) error {
+	if snap.Timestamp.Before(e.q.From) || !snap.Timestamp.Before(e.q.To) {
+		return nil
+	}
+	metrics, err := storage.JSONMetrics(snap.Metrics)
+	if err != nil {
+		return err
+	}
+	t := float64(snap.Timestamp.UnixNano()) / 1e9
+	seen := map[string]bool{}
+	for _, sel := range e.q.Selectors {
+		sel.walk(metrics, sel.Path, "", snap.Labels, func(p string, labels map[string]string, v float64) {
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || !sel.matches(labels) {
+				return
+			}
+			key := seriesKey(sel.Text, p, labels)
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+			s := e.series[key]
+			if s == nil {
+				if len(e.series) >= MaxSeries {
+					err = fmt.Errorf("the query selects more than %d series", MaxSeries)
+					return
+				}
+				s = &series{selector: sel.Text, path: p, labels: labels, samples: map[storage.Statistic][]sample{}}
+				e.series[key] = s
+				e.order = append(e.order, key)
+			}
+			if e.samples >= MaxSamples {
+				err = fmt.Errorf("the query reads more than %d samples", MaxSamples)
+				return
+			}
+			s.samples[stat] = append(s.samples[stat], sample{t: t, v: v})
+			e.samples++
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seriesKey identifies a series by selector, path and labels. The same
+// path may hold different things over time, such as a process list, so
+// the labels are part of its identity.
+func seriesKey(selector, path string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(selector)
+	b.WriteByte(0)
+	b.WriteString(path)
+	for _, k := range names {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
+
+// Result aggregates the selected samples per step.
+func (e *Evaluator) Result() Result {
+	step := int64(e.q.Step / time.Second)
+	start := e.q.From.Unix()
+	steps := int(e.q.To.Sub(e.q.From)/e.q.Step) + 1
+	if !e.q.From.Add(time.Duration(steps-1) * e.q.Step).Before(e.q.To) {
+		steps--
+	}
+	res := Result{Start: start, End: e.q.To.Unix(), Step: step, Timestamps: make([]int64, steps), Series: []Series{}}
+	for i := range res.Timestamps {
+		res.Timestamps[i] = start + int64(i)*step
+	}
+	from := float64(e.q.From.UnixNano()) / 1e9
+
+	for _, key := range e.order {
+		s := e.series[key]
+		out := Series{Selector: s.selector, Path: s.path, Labels: s.labels, Values: map[string][]*float64{}}
+		for _, samples := range s.samples {
+			sort.SliceStable(samples, func(i, j int) bool { return samples[i].t < samples[j].t })
+		}
+		for _, agg := range e.q.Aggregations {
+			fn := agg
+			if agg == "count" && e.q.Rollup {
+				// Each sample is the count of a bucket.
+				fn = "sum"
+			}
+			out.Values[agg] = aggregateSteps(fn, s.samples[e.q.statistic(agg)], from, step, steps)
+		}
+		res.Series = append(res.Series, out)
+	}
+	return res
+}
+
+// aggregateSteps applies an aggregation to sorted samples step by step,
+// starting at from, in Unix seconds.
+func aggregateSteps(agg string, samples []sample, from float64, step int64, steps int) []*float64 {
+	values := make([]*float64, steps)
+	var prev *sample
+	for i := 0; i < len(samples); {
+		bucket := int((samples[i].t - from) / float64(step))
+		j := i
+		for j < len(samples) && int((samples[j].t-from)/float64(step)) == bucket {
+			j++
+		}
+		if bucket >= 0 && bucket < steps {
+			if v, ok := aggregate(agg, samples[i:j], prev); ok {
+				values[bucket] = &v
+			}
+		}
+		prev = &samples[j-1]
+		i = j
+	}
+	return values
+}
+
+// aggregate applies an aggregation, or "sum", to the samples of one step.
+// prev is the last sample before the step, if any.
+func aggregate(agg string, samples []sample, prev *sample) (float64, bool) {
+	switch agg {
+	case "avg", "sum":
+		sum := 0.0
+		for _, s := range samples {
+			sum += s.v
+		}
+		if agg == "sum" {
+			return sum, true
+		}
+		return sum / float64(len(samples)), true
+	case "last":
+		return samples[len(samples)-1].v, true
+	case "count":
+		return float64(len(samples)), true
+	case "min", "max":
+		v := samples[0].v
+		for _, s := range samples[1:] {
+			if agg == "min" && s.v < v || agg == "max" && s.v > v {
+				v = s.v
+			}
+		}
+		return v, true
+	case "p50":
+		return percentile(samples, 50), true
+	case "p95":
+		return percentile(samples, 95), true
+	case "p99":
+		return percentile(samples, 99), true
+	case "rate", "delta":
+		points := samples
+		if prev != nil {
+			points = append([]sample{*prev}, samples...)
+		}
+		if len(points) < 2 {
+			return 0, false
+		}
+		first, last := points[0], points[len(points)-1]
+		if agg == "delta" {
+			return last.v - first.v, true
+		}
+		elapsed := last.t - first.t
+		if elapsed <= 0 {
+			return 0, false
+		}
+		increase := 0.0
+		for i := 1; i < len(points); i++ {
+			if d := points[i].v - points[i-1].v; d >= 0 {
+				increase += d
+			} else {
+				// The counter was reset; it has counted up from zero since.
+				increase += points[i].v
+			}
+		}
+		return increase / elapsed, true
+	}
+	return 0, false
+}
+
+// percentile returns the p-th percentile of the samples' values, linearly
+// interpolated between the closest ranks.
+func percentile(samples []sample, p float64) float64 {
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		values[i] = s.v
+	}
+	sort.Float64s(values)
+	rank := p / 100 * float64(len(values)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return values[lo] + (values[hi]-values[lo])*(rank-float64(lo))
+}
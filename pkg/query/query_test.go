@@ -0,0 +1,163 @@
+// pkg/query/query_test.go
+
+package query
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+var queryStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// cpuSnapshot returns a snapshot with cpu set to v, sec seconds into the query.
+func cpuSnapshot(sec int, v float64) storage.Snapshot {
+	return storage.Snapshot{
+		Timestamp: queryStart.Add(time.Duration(sec) * time.Second),
+		Metrics:   map[string]interface{}{"cpu": v},
+	}
+}
+
+// evaluate runs a two-step query of cpu over the given samples, by
+// statistic, and returns the values of each aggregation.
+func evaluate(t *testing.T, rollup bool, aggs []string, samples map[storage.Statistic][][2]float64) map[string][]*float64 {
+	t.Helper()
+	sel, err := ParseSelector("cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{Selectors: []Selector{sel}, From: queryStart, To: queryStart.Add(2 * time.Minute), Step: time.Minute, Aggregations: aggs, Rollup: rollup}
+	e, err := NewEvaluator(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stat := range q.Statistics() {
+		for _, s := range samples[stat] {
+			if err := e.Add(cpuSnapshot(int(s[0]), s[1]), stat); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	res := e.Result()
+	if len(res.Series) != 1 {
+		t.Fatalf("Result has %d series, want 1", len(res.Series))
+	}
+	return res.Series[0].Values
+}
+
+// checkValues compares the values of each aggregation with want, where NaN
+// stands for a step without a value.
+func checkValues(t *testing.T, got map[string][]*float64, want map[string][]float64) {
+	t.Helper()
+	for agg, w := range want {
+		g := got[agg]
+		if len(g) != len(w) {
+			t.Errorf("%s has %d steps, want %d", agg, len(g), len(w))
+			continue
+		}
+		for i := range w {
+			switch {
+			case math.IsNaN(w[i]) && g[i] != nil:
+				t.Errorf("%s[%d] = %v, want no value", agg, i, *g[i])
+			case !math.IsNaN(w[i]) && (g[i] == nil || math.Abs(*g[i]-w[i]) > 1e-9):
+				t.Errorf("%s[%d] = %v, want %v", agg, i, g[i], w[i])
+			}
+		}
+	}
+}
+
+func TestAggregations(t *testing.T) {
+	raw := map[storage.Statistic][][2]float64{
+		"": {{0, 1}, {10, 3}, {20, 2}, {60, 5}, {90, 4}},
+	}
+	got := evaluate(t, false, Aggregations, raw)
+	checkValues(t, got, map[string][]float64{
+		"avg":   {2, 4.5},
+		"min":   {1, 4},
+		"max":   {3, 5},
+		"last":  {2, 4},
+		"count": {3, 2},
+		"p50":   {2, 4.5},
+		"p99":   {2.98, 4.99},
+		// 1 to 3, then a reset to 2, over 20 seconds; then from the
+		// previous step's 2 to 5, and a reset to 4, over 70 seconds.
+		"rate":  {4.0 / 20, 7.0 / 70},
+		"delta": {1, 2},
+	})
+}
+
+func TestRollupAggregations(t *testing.T) {
+	// Two buckets in the first step and one in the second.
+	buckets := map[storage.Statistic][][2]float64{
+		storage.StatAvg:   {{0, 2}, {30, 4}, {60, 6}},
+		storage.StatMin:   {{0, 1}, {30, 0}, {60, 5}},
+		storage.StatMax:   {{0, 5}, {30, 9}, {60, 7}},
+		storage.StatLast:  {{0, 10}, {30, 12}, {60, 20}},
+		storage.StatCount: {{0, 3}, {30, 4}, {60, 2}},
+	}
+	got := evaluate(t, true, []string{"avg", "min", "max", "last", "count", "rate", "delta"}, buckets)
+	checkValues(t, got, map[string][]float64{
+		// The average of the bucket averages, not weighted by count.
+		"avg":   {3, 6},
+		"min":   {0, 5},
+		"max":   {9, 7},
+		"last":  {12, 20},
+		"count": {7, 2},
+		// From the bucket last values: 10 to 12 over 30 seconds, then the
+		// previous step's 12 to 20 over 30 seconds.
+		"rate":  {2.0 / 30, 8.0 / 30},
+		"delta": {2, 8},
+	})
+}
+
+func TestRollupStatistics(t *testing.T) {
+	q := Query{Aggregations: []string{"avg", "min", "rate", "delta", "last", "count", "max"}, Rollup: true}
+	want := []storage.Statistic{storage.StatAvg, storage.StatMin, storage.StatLast, storage.StatCount, storage.StatMax}
+	if got := q.Statistics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statistics = %v, want %v", got, want)
+	}
+	q.Rollup = false
+	if got := q.Statistics(); !reflect.DeepEqual(got, []storage.Statistic{""}) {
+		t.Errorf("raw Statistics = %v", got)
+	}
+}
+
+func TestRollupRejectsPercentiles(t *testing.T) {
+	sel, _ := ParseSelector("cpu")
+	for _, agg := range []string{"p50", "p95", "p99"} {
+		q := Query{Selectors: []Selector{sel}, From: queryStart, To: queryStart.Add(time.Hour), Step: time.Minute, Aggregations: []string{"avg", agg}}
+		if err := q.Validate(); err != nil {
+			t.Errorf("%s over raw snapshots: %v", agg, err)
+		}
+		q.Rollup = true
+		if err := q.Validate(); err == nil || !strings.Contains(err.Error(), agg) {
+			t.Errorf("%s over a rollup tier: error = %v", agg, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	sel, _ := ParseSelector("cpu")
+	ok := Query{Selectors: []Selector{sel}, From: queryStart, To: queryStart.Add(time.Hour), Step: time.Minute, Aggregations: []string{"avg"}}
+	if err := ok.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, mod := range map[string]func(q *Query){
+		"no selectors":      func(q *Query) { q.Selectors = nil },
+		"empty range":       func(q *Query) { q.To = q.From },
+		"fractional step":   func(q *Query) { q.Step = 1500 * time.Millisecond },
+		"too many steps":    func(q *Query) { q.Step = time.Second; q.To = q.From.Add(MaxPoints * time.Second) },
+		"no aggregations":   func(q *Query) { q.Aggregations = nil },
+		"unknown aggregate": func(q *Query) { q.Aggregations = []string{"median"} },
+	} {
+		q := ok
+		mod(&q)
+		if err := q.Validate(); err == nil {
+			t.Errorf("%s: Validate succeeded", name)
+		}
+	}
+}
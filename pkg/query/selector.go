@@ -0,0 +1,289 @@
+// pkg/query/selector.go
+
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SailfinIO/agent/pkg/storage"
+)
+
+// MatchOp is the comparison of a label matcher.
+type MatchOp string
+
+const (
+	MatchEqual     MatchOp = "="
+	MatchNotEqual  MatchOp = "!="
+	MatchRegexp    MatchOp = "=~"
+	MatchNotRegexp MatchOp = "!~"
+)
+
+// Matcher compares one label of a series. A missing label matches as the
+// empty string.
+type Matcher struct {
+	Name  string
+	Op    MatchOp
+	Value string
+	re    *regexp.Regexp
+}
+
+// Matches reports whether a label value satisfies the matcher.
+func (m Matcher) Matches(v string) bool {
+	switch m.Op {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+// Selector picks numeric values out of snapshot metrics:
+//
+//	path{label="value", label!="value", label=~"regex", label!~"regex"}
+//
+// The path is a metric path (see storage.JoinKey), a dotted list of object
+// keys and array indexes, in which "*" matches any one key or index and "**"
+// matches any number of them. A backslash escapes a literal dot, or a key
+// that would read as an index. Booleans select as 0 and 1.
+//
+// Every object along a matched path lends its string fields, and the
+// strings in its "labels" field, as labels of the series, after the
+// snapshot's own labels; inner objects take precedence. This lets a
+// selector such as custom.points.*.fields.value{name="deploys"} pick the
+// points that a collector reports with a name.
+type Selector struct {
+	Text     string
+	Path     []string
+	Matchers []Matcher
+}
+
+// ParseSelector parses a selector.
+func ParseSelector(s string) (Selector, error) {
+	sel := Selector{Text: s}
+	s = strings.TrimSpace(s)
+	pathText, rest := s, ""
+	if i := strings.IndexByte(s, '{'); i >= 0 {
+		pathText, rest = strings.TrimSpace(s[:i]), s[i:]
+	}
+	path, err := splitSelectorPath(pathText)
+	if err != nil {
+		return sel, err
+	}
+	sel.Path = path
+	if rest == "" {
+		return sel, nil
+	}
+	if !strings.HasSuffix(rest, "}") {
+		return sel, fmt.Errorf("selector %q: missing closing brace", sel.Text)
+	}
+	matchers, err := parseMatchers(rest[1 : len(rest)-1])
+	if err != nil {
+		return sel, fmt.Errorf("selector %q: %v", sel.Text, err)
+	}
+	sel.Matchers = matchers
+	return sel, nil
+}
+
+// splitSelectorPath splits a path on unescaped dots.
+func splitSelectorPath(s string) ([]string, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty metric path")
+	}
+	var segments []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case c == '.':
+			segments = append(segments, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	segments = append(segments, cur.String())
+	for _, seg := range segments {
+		if seg == "" {
+			return nil, fmt.Errorf("metric path %q has an empty segment", s)
+		}
+	}
+	return segments, nil
+}
+
+// parseMatchers parses the comma-separated matchers between braces.
+func parseMatchers(s string) ([]Matcher, error) {
+	var out []Matcher
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return out, nil
+		}
+		i := 0
+		for i < len(s) && (s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || i > 0 && s[i] >= '0' && s[i] <= '9') {
+			i++
+		}
+		if i == 0 {
+			return nil, fmt.Errorf("expected a label name at %q", s)
+		}
+		m := Matcher{Name: s[:i]}
+		s = strings.TrimLeft(s[i:], " \t")
+		switch {
+		case strings.HasPrefix(s, "=~"), strings.HasPrefix(s, "!~"), strings.HasPrefix(s, "!="):
+			m.Op, s = MatchOp(s[:2]), s[2:]
+		case strings.HasPrefix(s, "="):
+			m.Op, s = MatchEqual, s[1:]
+		default:
+			return nil, fmt.Errorf("expected =, !=, =~ or !~ after %q", m.Name)
+		}
+		s = strings.TrimLeft(s, " \t")
+		value, rest, err := quotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("label %q: %v", m.Name, err)
+		}
+		m.Value = value
+		if m.Op == MatchRegexp || m.Op == MatchNotRegexp {
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("label %q: %v", m.Name, err)
+			}
+			m.re = re
+		}
+		out = append(out, m)
+		s = strings.TrimLeft(rest, " \t")
+		if s == "" {
+			return out, nil
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("expected a comma at %q", s)
+		}
+		s = s[1:]
+	}
+}
+
+// quotedPrefix reads a double-quoted string from the start of s.
+func quotedPrefix(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("expected a quoted value")
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			v, err := strconv.Unquote(s[:i+1])
+			return v, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted value")
+}
+
+// matches reports whether labels satisfy every matcher.
+func (sel Selector) matches(labels map[string]string) bool {
+	for _, m := range sel.Matchers {
+		if !m.Matches(labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// walk calls fn for each number or boolean in v matched by segments, with
+// its metric path. path is the metric path of v.
+func (sel Selector) walk(v interface{}, segments []string, path string, labels map[string]string, fn func(path string, labels map[string]string, value float64)) {
+	if obj, ok := v.(map[string]interface{}); ok {
+		labels = objectLabels(obj, labels)
+	}
+	if len(segments) == 0 {
+		switch v := v.(type) {
+		case float64:
+			fn(path, labels, v)
+		case bool:
+			n := 0.0
+			if v {
+				n = 1
+			}
+			fn(path, labels, n)
+		}
+		return
+	}
+	seg := segments[0]
+	if seg == "**" {
+		// Match nothing, or one more level and stay on "**".
+		sel.walk(v, segments[1:], path, labels, fn)
+		eachChild(v, path, func(childPath string, child interface{}) {
+			sel.walk(child, segments, childPath, labels, fn)
+		})
+		return
+	}
+	if seg == "*" {
+		eachChild(v, path, func(childPath string, child interface{}) {
+			sel.walk(child, segments[1:], childPath, labels, fn)
+		})
+		return
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if child, ok := v[seg]; ok {
+			sel.walk(child, segments[1:], storage.JoinKey(path, seg), labels, fn)
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(seg); err == nil && i >= 0 && i < len(v) {
+			sel.walk(v[i], segments[1:], storage.JoinIndex(path, i), labels, fn)
+		}
+	}
+}
+
+// eachChild calls fn for each element of an object or array, with its
+// metric path below path.
+func eachChild(v interface{}, path string, fn func(childPath string, child interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			fn(storage.JoinKey(path, k), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			fn(storage.JoinIndex(path, i), child)
+		}
+	}
+}
+
+// objectLabels returns labels extended with the string fields of obj and
+// the strings of its "labels" field.
+func objectLabels(obj map[string]interface{}, labels map[string]string) map[string]string {
+	var out map[string]string
+	set := func(k, v string) {
+		if out == nil {
+			out = make(map[string]string, len(labels)+4)
+			for lk, lv := range labels {
+				out[lk] = lv
+			}
+		}
+		out[k] = v
+	}
+	for k, v := range obj {
+		if s, ok := v.(string); ok {
+			set(k, s)
+		}
+	}
+	if inner, ok := obj["labels"].(map[string]interface{}); ok {
+		for k, v := range inner {
+			if s, ok := v.(string); ok {
+				set(k, s)
+			}
+		}
+	}
+	if out == nil {
+		return labels
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+// pkg/query/selector_test.go
+
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector(`disks.*.usedPercent{mount="/", fs!="tmpfs", dev=~"sd.*", name!~"loop\\d+"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"disks", "*", "usedPercent"}; !reflect.DeepEqual(sel.Path, want) {
+		t.Errorf("Path = %q, want %q", sel.Path, want)
+	}
+	if len(sel.Matchers) != 4 {
+		t.Fatalf("Matchers = %+v", sel.Matchers)
+	}
+	labels := map[string]string{"mount": "/", "fs": "ext4", "dev": "sda1", "name": "root"}
+	if !sel.matches(labels) {
+		t.Errorf("selector does not match %v", labels)
+	}
+	for k, v := range map[string]string{"mount": "/var", "fs": "tmpfs", "dev": "nvme0", "name": "loop3"} {
+		other := map[string]string{"mount": "/", "fs": "ext4", "dev": "sda1", "name": "root"}
+		other[k] = v
+		if sel.matches(other) {
+			t.Errorf("selector matches %v", other)
+		}
+	}
+
+	sel, err = ParseSelector(`a\.b.\0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.b", "0"}; !reflect.DeepEqual(sel.Path, want) {
+		t.Errorf("escaped Path = %q, want %q", sel.Path, want)
+	}
+
+	for _, s := range []string{"", "a..b", `cpu{mount="/"`, `cpu{mount}`, `cpu{mount="/}`, `cpu{1x="a"}`, `cpu{a="1" b="2"}`, `cpu{a=~"("}`} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSelectorWalk(t *testing.T) {
+	metrics := map[string]interface{}{
+		"disks": []interface{}{
+			map[string]interface{}{"mount": "/", "used": float64(10)},
+			map[string]interface{}{"mount": "/var", "used": float64(20), "ok": true},
+		},
+		"cores": map[string]interface{}{"0": map[string]interface{}{"user": float64(5)}},
+	}
+	for _, tc := range []struct {
+		selector string
+		want     map[string]float64
+	}{
+		{`disks.*.used`, map[string]float64{"disks.0.used": 10, "disks.1.used": 20}},
+		{`disks.*.used{mount="/var"}`, map[string]float64{"disks.1.used": 20}},
+		{`disks.1.ok`, map[string]float64{"disks.1.ok": 1}},
+		{`**.user`, map[string]float64{`cores.\0.user`: 5}},
+		{`cores.0.user`, map[string]float64{`cores.\0.user`: 5}},
+		{`disks.2.used`, map[string]float64{}},
+	} {
+		sel, err := ParseSelector(tc.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]float64{}
+		sel.walk(metrics, sel.Path, "", nil, func(path string, labels map[string]string, v float64) {
+			if sel.matches(labels) {
+				got[path] = v
+			}
+		})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s selected %v, want %v", tc.selector, got, tc.want)
+		}
+	}
+}
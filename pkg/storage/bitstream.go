@@ -0,0 +1,83 @@
+// pkg/storage/bitstream.go
+
+package storage
+
+import "io"
+
+// bitWriter appends individual bits to a byte slice, most significant bit first.
+type bitWriter struct {
+	buf   []byte
+	count uint8 // Bits still free in the last byte.
+}
+
+// writeBit appends one bit.
+func (w *bitWriter) writeBit(bit bool) {
+	if w.count == 0 {
+		w.buf = append(w.buf, 0)
+		w.count = 8
+	}
+	w.count--
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << w.count
+	}
+}
+
+// writeBits appends the low nbits of v, most significant first.
+func (w *bitWriter) writeBits(v uint64, nbits int) {
+	for nbits > 0 {
+		if w.count == 0 {
+			w.buf = append(w.buf, 0)
+			w.count = 8
+		}
+		n := int(w.count)
+		if n > nbits {
+			n = nbits
+		}
+		// Take the next n bits of v and place them in the free low bits.
+		chunk := byte(v>>uint(nbits-n)) & byte(1<<uint(n)-1)
+		w.buf[len(w.buf)-1] |= chunk << (w.count - uint8(n))
+		w.count -= uint8(n)
+		nbits -= n
+	}
+}
+
+// bytes returns a copy of the written bytes.
+func (w *bitWriter) bytes() []byte {
+	return append([]byte(nil), w.buf...)
+}
+
+// bitReader reads bits written by a bitWriter.
+type bitReader struct {
+	buf []byte
+	pos int // Index of the next bit.
+}
+
+// readBit reads one bit.
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= len(r.buf)*8 {
+		return false, io.ErrUnexpectedEOF
+	}
+	bit := r.buf[r.pos/8]&(0x80>>uint(r.pos%8)) != 0
+	r.pos++
+	return bit, nil
+}
+
+// readBits reads nbits into the low bits of the result.
+func (r *bitReader) readBits(nbits int) (uint64, error) {
+	if r.pos+nbits > len(r.buf)*8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var v uint64
+	for nbits > 0 {
+		offset := r.pos % 8
+		n := 8 - offset
+		if n > nbits {
+			n = nbits
+		}
+		b := r.buf[r.pos/8] >> uint(8-offset-n) & byte(1<<uint(n)-1)
+		v = v<<uint(n) | uint64(b)
+		r.pos += n
+		nbits -= n
+	}
+	return v, nil
+}
@@ -0,0 +1,552 @@
+// pkg/storage/block.go
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Block file layout. All integers are big-endian; the index uses varints.
+//
+//	header: magic[8] minT[8] maxT[8] count[4] indexOffset[8] indexLen[4] indexCRC[4] headerCRC[4]
+//	data:   compressed chunks, one for the snapshot timestamps and one per series
+//	index:  timestamps chunk entry, string dictionary, series entries
+//
+// The header is enough to place a block in time; the index locates each
+// series' chunk along with its point count, time range and checksum, so a
+// range query reads only the chunks it needs.
+//
+// In an encrypted block, marked by its magic, the index and every chunk are
+// sealed by a Keyring separately, so chunks can still be read on their own.
+// Lengths, offsets and checksums are those of the sealed bytes.
+const (
+	blockMagic       = "SFTSDB01"
+	blockMagicSealed = "SFTSDBE1"
+	blockHeaderSize  = 48
+)
+
+// errCorruptBlock reports a block whose header, index or chunk is damaged.
+var errCorruptBlock = errors.New("corrupt block")
+
+// chunkRef locates a compressed chunk within a block file.
+type chunkRef struct {
+	count      int
+	minT, maxT int64
+	offset     int64
+	length     int
+	crc        uint32
+}
+
+// blockIndex is the decoded index of a block.
+type blockIndex struct {
+	times  chunkRef
+	dict   []string
+	series map[string]chunkRef
+}
+
+// blockMeta is the in-memory summary of a block file.
+type blockMeta struct {
+	seq        int
+	path       string
+	size       int64
+	count      int
+	minT, maxT int64 // Unix milliseconds.
+	sealed     bool
+	keyID      uint32 // Key that sealed the index of an encrypted block.
+}
+
+// blockChunk is a chunk to be written to a block. The offset, length and
+// checksum of its ref are set when it is written.
+type blockChunk struct {
+	ref  chunkRef
+	data []byte
+}
+
+// headBlock accumulates snapshots in compressed form until it is written
+// out as a block.
+type headBlock struct {
+	count      int
+	minT, maxT int64
+	times      chunk
+	series     map[string]*chunk
+	dict       []string
+	dictIndex  map[string]int
+}
+
+// newHeadBlock returns an empty head block.
+func newHeadBlock() *headBlock {
+	return &headBlock{series: map[string]*chunk{}, dictIndex: map[string]int{}}
+}
+
+// add appends a flattened snapshot taken at ts, in Unix milliseconds.
+func (h *headBlock) add(ts int64, values []seriesValue) {
+	if h.count == 0 || ts < h.minT {
+		h.minT = ts
+	}
+	if h.count == 0 || ts > h.maxT {
+		h.maxT = ts
+	}
+	h.count++
+	h.times.append(ts, 0)
+	for _, v := range values {
+		c := h.series[v.key]
+		if c == nil {
+			c = &chunk{}
+			h.series[v.key] = c
+		}
+		num := v.num
+		if v.key[0] == kindString || v.key[0] == kindLabels {
+			id, ok := h.dictIndex[v.text]
+			if !ok {
+				id = len(h.dict)
+				h.dict = append(h.dict, v.text)
+				h.dictIndex[v.text] = id
+			}
+			num = float64(id)
+		}
+		c.append(ts, num)
+	}
+}
+
+// encode serializes the head block in the block file layout, encrypted
+// when k is not nil.
+func (h *headBlock) encode(k *Keyring) ([]byte, error) {
+	toBlockChunk := func(c *chunk) blockChunk {
+		return blockChunk{ref: chunkRef{count: c.count, minT: c.minT, maxT: c.maxT}, data: c.bytes()}
+	}
+	series := make(map[string]blockChunk, len(h.series))
+	for key, c := range h.series {
+		series[key] = toBlockChunk(c)
+	}
+	return encodeBlock(h.minT, h.maxT, h.count, toBlockChunk(&h.times), h.dict, series, k)
+}
+
+// encodeBlock serializes chunks in the block file layout, encrypted when k
+// is not nil.
+func encodeBlock(minT, maxT int64, count int, times blockChunk, dict []string, series map[string]blockChunk, k *Keyring) ([]byte, error) {
+	var data bytes.Buffer
+	data.Write(make([]byte, blockHeaderSize))
+	put := func(c blockChunk) (chunkRef, error) {
+		b := c.data
+		if k != nil {
+			var err error
+			if b, err = k.seal(b); err != nil {
+				return chunkRef{}, err
+			}
+		}
+		ref := c.ref
+		ref.offset = int64(data.Len())
+		ref.length = len(b)
+		ref.crc = crc32.Checksum(b, crcTable)
+		data.Write(b)
+		return ref, nil
+	}
+
+	ref, err := put(times)
+	if err != nil {
+		return nil, err
+	}
+	index := appendChunkRef(nil, ref)
+	index = binary.AppendUvarint(index, uint64(len(dict)))
+	for _, s := range dict {
+		index = binary.AppendUvarint(index, uint64(len(s)))
+		index = append(index, s...)
+	}
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	index = binary.AppendUvarint(index, uint64(len(keys)))
+	for _, key := range keys {
+		ref, err := put(series[key])
+		if err != nil {
+			return nil, err
+		}
+		index = binary.AppendUvarint(index, uint64(len(key)))
+		index = append(index, key...)
+		index = appendChunkRef(index, ref)
+	}
+	magic := blockMagic
+	if k != nil {
+		magic = blockMagicSealed
+		if index, err = k.seal(index); err != nil {
+			return nil, err
+		}
+	}
+
+	indexOffset := data.Len()
+	data.Write(index)
+	out := data.Bytes()
+	header := out[:blockHeaderSize]
+	copy(header, magic)
+	binary.BigEndian.PutUint64(header[8:], uint64(minT))
+	binary.BigEndian.PutUint64(header[16:], uint64(maxT))
+	binary.BigEndian.PutUint32(header[24:], uint32(count))
+	binary.BigEndian.PutUint64(header[28:], uint64(indexOffset))
+	binary.BigEndian.PutUint32(header[36:], uint32(len(index)))
+	binary.BigEndian.PutUint32(header[40:], crc32.Checksum(index, crcTable))
+	binary.BigEndian.PutUint32(header[44:], crc32.Checksum(header[:44], crcTable))
+	return out, nil
+}
+
+// appendChunkRef appends the index entry of a chunk.
+func appendChunkRef(b []byte, ref chunkRef) []byte {
+	b = binary.AppendUvarint(b, uint64(ref.count))
+	b = binary.AppendVarint(b, ref.minT)
+	b = binary.AppendVarint(b, ref.maxT)
+	b = binary.AppendUvarint(b, uint64(ref.offset))
+	b = binary.AppendUvarint(b, uint64(ref.length))
+	return binary.BigEndian.AppendUint32(b, ref.crc)
+}
+
+// snapshotReader reads the chunks of a block. For the head block the chunks
+// are in memory; for block files they are read from disk on demand.
+type snapshotReader interface {
+	index() (*blockIndex, error)
+	chunk(ref chunkRef) ([]byte, error)
+}
+
+// headReader reads an encoded head block from memory.
+type headReader struct {
+	data []byte
+	idx  *blockIndex
+}
+
+// newHeadReader encodes the head block so it can be queried like a file.
+func newHeadReader(h *headBlock) (*headReader, error) {
+	data, err := h.encode(nil)
+	if err != nil {
+		return nil, err
+	}
+	offset := binary.BigEndian.Uint64(data[28:])
+	idx, err := decodeBlockIndex(data[offset:])
+	if err != nil {
+		return nil, err
+	}
+	return &headReader{data: data, idx: idx}, nil
+}
+
+func (r *headReader) index() (*blockIndex, error) {
+	return r.idx, nil
+}
+
+func (r *headReader) chunk(ref chunkRef) ([]byte, error) {
+	return r.data[ref.offset : ref.offset+int64(ref.length)], nil
+}
+
+// blockFile reads a block file, decrypting it with k if it is encrypted.
+// It is not safe for concurrent use.
+type blockFile struct {
+	f    *os.File
+	meta *blockMeta
+	k    *Keyring
+	idx  *blockIndex
+}
+
+// openBlockFile opens a block file for reading.
+func openBlockFile(meta *blockMeta, k *Keyring) (*blockFile, error) {
+	f, err := os.Open(meta.path)
+	if err != nil {
+		return nil, err
+	}
+	return &blockFile{f: f, meta: meta, k: k}, nil
+}
+
+func (b *blockFile) index() (*blockIndex, error) {
+	if b.idx != nil {
+		return b.idx, nil
+	}
+	header := make([]byte, blockHeaderSize)
+	if _, err := b.f.ReadAt(header, 0); err != nil {
+		return nil, errCorruptBlock
+	}
+	offset := int64(binary.BigEndian.Uint64(header[28:]))
+	length := binary.BigEndian.Uint32(header[36:])
+	if length > maxRecordSize {
+		return nil, errCorruptBlock
+	}
+	data := make([]byte, length)
+	if _, err := b.f.ReadAt(data, offset); err != nil {
+		return nil, errCorruptBlock
+	}
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[40:]) {
+		return nil, errCorruptBlock
+	}
+	if b.meta.sealed {
+		var err error
+		if data, err = b.k.open(data); err != nil {
+			return nil, err
+		}
+	}
+	idx, err := decodeBlockIndex(data)
+	if err != nil {
+		return nil, err
+	}
+	b.idx = idx
+	return idx, nil
+}
+
+func (b *blockFile) chunk(ref chunkRef) ([]byte, error) {
+	data := make([]byte, ref.length)
+	if _, err := b.f.ReadAt(data, ref.offset); err != nil {
+		return nil, errCorruptBlock
+	}
+	if crc32.Checksum(data, crcTable) != ref.crc {
+		return nil, errCorruptBlock
+	}
+	if b.meta.sealed {
+		return b.k.open(data)
+	}
+	return data, nil
+}
+
+func (b *blockFile) Close() error {
+	return b.f.Close()
+}
+
+// readBlockMeta reads and verifies the header of a block file.
+func readBlockMeta(path string, seq int) (*blockMeta, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, blockHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, errCorruptBlock
+	}
+	magic := string(header[:8])
+	if magic != blockMagic && magic != blockMagicSealed ||
+		crc32.Checksum(header[:44], crcTable) != binary.BigEndian.Uint32(header[44:]) {
+		return nil, errCorruptBlock
+	}
+	indexOffset := int64(binary.BigEndian.Uint64(header[28:]))
+	if indexOffset+int64(binary.BigEndian.Uint32(header[36:])) != info.Size() {
+		return nil, errCorruptBlock
+	}
+	meta := &blockMeta{
+		seq:    seq,
+		path:   path,
+		size:   info.Size(),
+		minT:   int64(binary.BigEndian.Uint64(header[8:])),
+		maxT:   int64(binary.BigEndian.Uint64(header[16:])),
+		count:  int(binary.BigEndian.Uint32(header[24:])),
+		sealed: magic == blockMagicSealed,
+	}
+	if meta.sealed {
+		var id [keyIDSize]byte
+		if _, err := f.ReadAt(id[:], indexOffset); err != nil {
+			return nil, errCorruptBlock
+		}
+		meta.keyID = sealedKeyID(id[:])
+	}
+	return meta, nil
+}
+
+// decodeBlockIndex parses the index section of a block.
+func decodeBlockIndex(data []byte) (*blockIndex, error) {
+	d := indexDecoder{data: data}
+	idx := &blockIndex{series: map[string]chunkRef{}}
+	idx.times = d.chunkRef()
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		idx.dict = append(idx.dict, d.string())
+	}
+	n = d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		key := d.string()
+		idx.series[key] = d.chunkRef()
+	}
+	if _, empty := idx.series[""]; d.err != nil || empty {
+		return nil, errCorruptBlock
+	}
+	return idx, nil
+}
+
+// indexDecoder reads varint-encoded index fields, remembering the first
+// error.
+type indexDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *indexDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errCorruptBlock
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *indexDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errCorruptBlock
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *indexDecoder) string() string {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.data)) {
+		d.err = errCorruptBlock
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
+
+func (d *indexDecoder) chunkRef() chunkRef {
+	ref := chunkRef{
+		count:  int(d.uvarint()),
+		minT:   d.varint(),
+		maxT:   d.varint(),
+		offset: int64(d.uvarint()),
+		length: int(d.uvarint()),
+	}
+	if d.err == nil && len(d.data) < 4 {
+		d.err = errCorruptBlock
+	}
+	if d.err != nil {
+		return chunkRef{}
+	}
+	ref.crc = binary.BigEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	return ref
+}
+
+// decodeChunk decodes every point of a chunk.
+func decodeChunk(r snapshotReader, ref chunkRef) ([]int64, []float64, error) {
+	data, err := r.chunk(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts := make([]int64, 0, ref.count)
+	vs := make([]float64, 0, ref.count)
+	it := newChunkIterator(data, ref.count)
+	for it.next() {
+		t, v := it.at()
+		ts = append(ts, t)
+		vs = append(vs, v)
+	}
+	if it.err != nil || len(ts) != ref.count {
+		return nil, nil, errCorruptBlock
+	}
+	return ts, vs, nil
+}
+
+// readSnapshots rebuilds the snapshots of a block whose timestamps, in Unix
+// milliseconds, satisfy keep. Numbers are read from the series of kind
+// numbers, which selects a statistic in rollup tiers. Series whose time
+// range lies outside [lo, hi] are skipped without being read.
+func readSnapshots(r snapshotReader, lo, hi int64, keep func(ts int64) bool, numbers byte) ([]Snapshot, error) {
+	idx, err := r.index()
+	if err != nil {
+		return nil, err
+	}
+	times, _, err := decodeChunk(r, idx.times)
+	if err != nil {
+		return nil, err
+	}
+	// Positions of the kept snapshots, and the snapshots themselves.
+	slot := make([]int, len(times))
+	var out []Snapshot
+	for i, ts := range times {
+		slot[i] = -1
+		if keep(ts) {
+			slot[i] = len(out)
+			out = append(out, Snapshot{Timestamp: time.UnixMilli(ts), Metrics: map[string]interface{}{}})
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	for key, ref := range idx.series {
+		kind := key[0]
+		switch kind {
+		case numbers:
+			kind = kindNumber
+		case kindBool, kindString, kindLabels:
+		default:
+			// Rollup statistics other than the selected one.
+			continue
+		}
+		if ref.maxT < lo || ref.minT > hi {
+			continue
+		}
+		ts, vs, err := decodeChunk(r, ref)
+		if err != nil {
+			return nil, fmt.Errorf("series %q: %v", key[1:], err)
+		}
+		// A series' points are a subsequence of the snapshot timestamps in
+		// the same order, which also matches snapshots sharing a millisecond.
+		j := 0
+		for i, t := range times {
+			if j >= len(ts) {
+				break
+			}
+			if ts[j] != t {
+				continue
+			}
+			if s := slot[i]; s >= 0 {
+				if err := setSeriesValue(&out[s], kind, key[1:], vs[j], idx.dict); err != nil {
+					return nil, err
+				}
+			}
+			j++
+		}
+	}
+	for i := range out {
+		out[i].Metrics = finishArrays(out[i].Metrics).(map[string]interface{})
+	}
+	return out, nil
+}
+
+// setSeriesValue stores a decoded value of a series of the given kind and
+// path in a rebuilt snapshot.
+func setSeriesValue(snap *Snapshot, kind byte, path string, v float64, dict []string) error {
+	switch kind {
+	case kindNumber:
+		setPath(snap.Metrics, path, v)
+	case kindBool:
+		setPath(snap.Metrics, path, v != 0)
+	case kindString, kindLabels:
+		id := int(v)
+		if v < 0 || v != math.Trunc(v) || id >= len(dict) {
+			return errCorruptBlock
+		}
+		if kind == kindString {
+			setPath(snap.Metrics, path, dict[id])
+		} else if err := json.Unmarshal([]byte(dict[id]), &snap.Labels); err != nil {
+			return errCorruptBlock
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// pkg/storage/block_test.go
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// richSnapshot returns a snapshot with every kind of value the block
+// format stores.
+func richSnapshot(i int) Snapshot {
+	return Snapshot{
+		Timestamp: time.UnixMilli(1700000000000 + int64(i)*30000).UTC(),
+		Labels:    map[string]string{"host": "web-1", "region": "eu"},
+		Metrics: map[string]interface{}{
+			"cpu": map[string]interface{}{"usage": 12.5 + float64(i), "cores": float64(8)},
+			"disks": []interface{}{
+				map[string]interface{}{"mount": "/", "used": float64(100 * i)},
+				map[string]interface{}{"mount": "/var", "used": float64(7)},
+			},
+			"healthy": i%2 == 0,
+			"version": "1.2.3",
+			"a.b":     float64(i),
+			// Keys that read as array indexes.
+			"cores": map[string]interface{}{"0": float64(i), "[1]": float64(2)},
+		},
+	}
+}
+
+// sameSnapshots compares snapshots by their JSON encoding.
+func sameSnapshots(t *testing.T, got, want []Snapshot) {
+	t.Helper()
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(want)
+	if !bytes.Equal(g, w) {
+		t.Errorf("snapshots differ\n got: %s\nwant: %s", g, w)
+	}
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	keyring, err := NewKeyring(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		k    *Keyring
+	}{{"plain", nil}, {"encrypted", keyring}} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHeadBlock()
+			var want []Snapshot
+			for i := 0; i < 5; i++ {
+				snap := richSnapshot(i)
+				values, err := flattenSnapshot(snap)
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.add(snap.Timestamp.UnixMilli(), values)
+				want = append(want, snap)
+			}
+			data, err := h.encode(tc.k)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "0000000000.blk")
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			meta, err := readBlockMeta(path, 0)
+			if err != nil {
+				t.Fatalf("readBlockMeta: %v", err)
+			}
+			if meta.count != 5 || meta.minT != want[0].Timestamp.UnixMilli() || meta.maxT != want[4].Timestamp.UnixMilli() {
+				t.Errorf("meta = %+v", meta)
+			}
+			if meta.sealed != (tc.k != nil) {
+				t.Errorf("sealed = %v", meta.sealed)
+			}
+			b, err := openBlockFile(meta, tc.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			got, err := readSnapshots(b, meta.minT, meta.maxT, func(int64) bool { return true }, kindNumber)
+			if err != nil {
+				t.Fatalf("readSnapshots: %v", err)
+			}
+			sameSnapshots(t, got, want)
+
+			// Reading through the in-memory head gives the same snapshots.
+			r, err := newHeadReader(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err = readSnapshots(r, meta.minT, meta.maxT, func(int64) bool { return true }, kindNumber)
+			if err != nil {
+				t.Fatalf("readSnapshots from head: %v", err)
+			}
+			sameSnapshots(t, got, want)
+		})
+	}
+}
+
+func TestBlockCorrupt(t *testing.T) {
+	h := newHeadBlock()
+	values, _ := flattenSnapshot(richSnapshot(0))
+	h.add(richSnapshot(0).Timestamp.UnixMilli(), values)
+	data, err := h.encode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	path := filepath.Join(t.TempDir(), "0000000000.blk")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := readBlockMeta(path, 0)
+	if err == nil {
+		var b *blockFile
+		if b, err = openBlockFile(meta, nil); err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+		_, err = readSnapshots(b, meta.minT, meta.maxT, func(int64) bool { return true }, kindNumber)
+	}
+	if err == nil {
+		t.Fatal("reading a corrupt block succeeded")
+	}
+}
@@ -0,0 +1,241 @@
+// pkg/storage/cursor.go
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrInvalidToken reports a page token that was not produced by a cursor.
+var ErrInvalidToken = errors.New("invalid page token")
+
+// errNoRollups reports a rollup query against a backend without tiers.
+var errNoRollups = errors.New("the storage backend keeps no rollups")
+
+// CursorOptions selects the snapshots a cursor visits.
+type CursorOptions struct {
+	// From and To bound the snapshot timestamps, exclusive. A zero time
+	// leaves that end of the range open.
+	From, To time.Time
+	// Reverse visits the newest snapshots first.
+	Reverse bool
+	// Resolution selects a rollup tier of backends implementing
+	// Downsampler. Zero visits raw snapshots.
+	Resolution time.Duration
+	// Statistic selects the bucket statistic the numbers of rolled-up
+	// snapshots hold. It defaults to the average and is not carried by
+	// page tokens.
+	Statistic Statistic
+	// Token resumes after the snapshot whose Token it is. A token carries
+	// the order and resolution of the cursor that produced it, which take
+	// precedence over Reverse and Resolution.
+	Token string
+}
+
+// Cursor visits stored snapshots one at a time without loading the whole
+// range. Snapshots are visited in the order they were saved, which is
+// timestamp order for the agent's own snapshots. Snapshots evicted while a
+// cursor is open may be skipped.
+//
+//	c, err := store.Cursor(storage.CursorOptions{Reverse: true})
+//	if err != nil { ... }
+//	defer c.Close()
+//	for c.Next() {
+//		snap := c.Snapshot()
+//	}
+//	if err := c.Err(); err != nil { ... }
+type Cursor interface {
+	// Next advances to the next snapshot, returning false when there are
+	// no more or an error occurred.
+	Next() bool
+	// Snapshot returns the current snapshot.
+	Snapshot() Snapshot
+	// Token returns a page token that resumes after the current snapshot.
+	Token() string
+	// Err returns the error that stopped the cursor, if any.
+	Err() error
+	// Close releases the cursor's resources.
+	Close() error
+}
+
+// snapshotSource yields snapshots in cursor order. It must yield at least
+// every snapshot within the cursor's lo and hi bounds; the cursor applies
+// the exact range and token.
+type snapshotSource interface {
+	next() (Snapshot, bool, error)
+	close() error
+}
+
+// pageToken is the decoded position of a cursor: the timestamp of the last
+// snapshot visited and how many snapshots with that timestamp were visited.
+type pageToken struct {
+	reverse    bool
+	resolution time.Duration
+	ts         int64 // Unix nanoseconds.
+	seen       int
+}
+
+// encode returns the opaque, URL-safe form of the token.
+func (t pageToken) encode() string {
+	b := []byte{1, 0}
+	if t.reverse {
+		b[1] = 1
+	}
+	b = binary.AppendVarint(b, int64(t.resolution))
+	b = binary.AppendVarint(b, t.ts)
+	b = binary.AppendUvarint(b, uint64(t.seen))
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodePageToken parses a token produced by pageToken.encode.
+func decodePageToken(s string) (pageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) < 2 || b[0] != 1 || b[1] > 1 {
+		return pageToken{}, ErrInvalidToken
+	}
+	d := indexDecoder{data: b[2:]}
+	t := pageToken{reverse: b[1] == 1}
+	t.resolution = time.Duration(d.varint())
+	t.ts = d.varint()
+	seen := d.uvarint()
+	if d.err != nil || len(d.data) != 0 || t.resolution < 0 || seen == 0 || seen > math.MaxInt32 {
+		return pageToken{}, ErrInvalidToken
+	}
+	t.seen = int(seen)
+	return t, nil
+}
+
+// cursor implements Cursor over a snapshotSource.
+type cursor struct {
+	src     snapshotSource
+	opts    CursorOptions
+	lo, hi  int64 // Bounds for sources, in Unix nanoseconds, inclusive.
+	token   *pageToken
+	skipped int // Snapshots at token.ts skipped so far.
+
+	cur  Snapshot
+	ts   int64 // Timestamp of cur.
+	seen int   // Snapshots visited with timestamp ts.
+	err  error
+	done bool
+}
+
+// newCursor validates opts and applies its token. The caller sets src.
+func newCursor(opts CursorOptions) (*cursor, error) {
+	if _, ok := opts.Statistic.kind(); !ok {
+		return nil, fmt.Errorf("unknown rollup statistic %q", opts.Statistic)
+	}
+	c := &cursor{opts: opts, lo: math.MinInt64, hi: math.MaxInt64}
+	if !opts.From.IsZero() {
+		c.lo = opts.From.UnixNano()
+	}
+	if !opts.To.IsZero() {
+		c.hi = opts.To.UnixNano()
+	}
+	if opts.Token != "" {
+		t, err := decodePageToken(opts.Token)
+		if err != nil {
+			return nil, err
+		}
+		c.token = &t
+		c.opts.Reverse, c.opts.Resolution = t.reverse, t.resolution
+		c.ts, c.seen = t.ts, t.seen
+		if t.reverse && t.ts < c.hi {
+			c.hi = t.ts
+		}
+		if !t.reverse && t.ts > c.lo {
+			c.lo = t.ts
+		}
+	}
+	return c, nil
+}
+
+// inWindow reports whether a segment or block spanning minT to maxT, in
+// Unix nanoseconds, may hold snapshots the cursor visits.
+func (c *cursor) inWindow(minT, maxT int64) bool {
+	return maxT >= c.lo && minT <= c.hi
+}
+
+func (c *cursor) Next() bool {
+	if c.done {
+		return false
+	}
+	for {
+		snap, ok, err := c.src.next()
+		if err != nil || !ok {
+			c.err = err
+			c.done = true
+			return false
+		}
+		ts := snap.Timestamp.UnixNano()
+		if !c.opts.From.IsZero() && !snap.Timestamp.After(c.opts.From) ||
+			!c.opts.To.IsZero() && !snap.Timestamp.Before(c.opts.To) {
+			continue
+		}
+		if t := c.token; t != nil {
+			if !t.reverse && ts < t.ts || t.reverse && ts > t.ts {
+				continue
+			}
+			if ts == t.ts && c.skipped < t.seen {
+				c.skipped++
+				continue
+			}
+		}
+		if ts == c.ts {
+			c.seen++
+		} else {
+			c.ts, c.seen = ts, 1
+		}
+		c.cur = snap
+		return true
+	}
+}
+
+func (c *cursor) Snapshot() Snapshot {
+	return c.cur
+}
+
+func (c *cursor) Token() string {
+	return pageToken{reverse: c.opts.Reverse, resolution: c.opts.Resolution, ts: c.ts, seen: c.seen}.encode()
+}
+
+func (c *cursor) Err() error {
+	return c.err
+}
+
+func (c *cursor) Close() error {
+	c.done = true
+	return c.src.close()
+}
+
+// sliceSource yields snapshots from a slice, which is already in cursor
+// order.
+type sliceSource struct {
+	snaps []Snapshot
+}
+
+func (s *sliceSource) next() (Snapshot, bool, error) {
+	if len(s.snaps) == 0 {
+		return Snapshot{}, false, nil
+	}
+	snap := s.snaps[0]
+	s.snaps = s.snaps[1:]
+	return snap, true, nil
+}
+
+func (s *sliceSource) close() error {
+	s.snaps = nil
+	return nil
+}
+
+// reverseSnapshots reverses snaps in place.
+func reverseSnapshots(snaps []Snapshot) {
+	for i, j := 0, len(snaps)-1; i < j; i, j = i+1, j-1 {
+		snaps[i], snaps[j] = snaps[j], snaps[i]
+	}
+}
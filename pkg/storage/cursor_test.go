@@ -0,0 +1,145 @@
+// pkg/storage/cursor_test.go
+
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// readPages pages through a cursor n snapshots at a time, resuming each
+// page from the token of the last snapshot of the previous one.
+func readPages(t *testing.T, s Storage, opts CursorOptions, n int) []Snapshot {
+	t.Helper()
+	var out []Snapshot
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("paging does not end")
+		}
+		c, err := s.Cursor(opts)
+		if err != nil {
+			t.Fatalf("Cursor: %v", err)
+		}
+		got := 0
+		for got < n && c.Next() {
+			out = append(out, c.Snapshot())
+			opts.Token = c.Token()
+			got++
+		}
+		err = c.Err()
+		c.Close()
+		if err != nil {
+			t.Fatalf("cursor: %v", err)
+		}
+		if got < n {
+			return out
+		}
+	}
+}
+
+// tiedStorage returns a memory store in which snapshots 2 to 4 share a
+// timestamp, so that pages end between them.
+func tiedStorage() (*InMemoryStorage, []int) {
+	s := NewInMemoryStorageWithRetention(Retention{})
+	secs := []int{0, 1, 2, 2, 2, 3, 4}
+	for _, i := range secs {
+		s.Save(testSnapshot(i))
+	}
+	return s, secs
+}
+
+func TestCursorPages(t *testing.T) {
+	s, secs := tiedStorage()
+	for n := 1; n <= len(secs)+1; n++ {
+		if got := timestamps(readPages(t, s, CursorOptions{}, n)); !sameInts(got, secs) {
+			t.Errorf("pages of %d = %v, want %v", n, got, secs)
+		}
+		reversed := make([]int, len(secs))
+		for i, v := range secs {
+			reversed[len(secs)-1-i] = v
+		}
+		if got := timestamps(readPages(t, s, CursorOptions{Reverse: true}, n)); !sameInts(got, reversed) {
+			t.Errorf("reverse pages of %d = %v, want %v", n, got, reversed)
+		}
+	}
+
+	// The range still applies when resuming.
+	opts := CursorOptions{From: testSnapshot(0).Timestamp, To: testSnapshot(4).Timestamp}
+	if got := timestamps(readPages(t, s, opts, 2)); !sameInts(got, []int{1, 2, 2, 2, 3}) {
+		t.Errorf("ranged pages = %v", got)
+	}
+}
+
+func TestCursorTokenKeepsOrder(t *testing.T) {
+	s, _ := tiedStorage()
+	c, _ := s.Cursor(CursorOptions{Reverse: true})
+	c.Next()
+	token := c.Token()
+	c.Close()
+	// Reverse from the token wins over the options.
+	if got := timestamps(readAll(t, s, CursorOptions{Token: token})); !sameInts(got, []int{3, 2, 2, 2, 1, 0}) {
+		t.Errorf("resumed cursor = %v", got)
+	}
+}
+
+func TestCursorInvalidToken(t *testing.T) {
+	s, _ := tiedStorage()
+	c, _ := s.Cursor(CursorOptions{})
+	c.Next()
+	valid, _ := base64.RawURLEncoding.DecodeString(c.Token())
+	c.Close()
+
+	tamper := func(f func(b []byte) []byte) string {
+		b := append([]byte(nil), valid...)
+		return base64.RawURLEncoding.EncodeToString(f(b))
+	}
+	for name, token := range map[string]string{
+		"not base64": "!!!",
+		"version":    tamper(func(b []byte) []byte { b[0] = 2; return b }),
+		"order":      tamper(func(b []byte) []byte { b[1] = 7; return b }),
+		"trailing":   tamper(func(b []byte) []byte { return append(b, 0) }),
+		"truncated":  tamper(func(b []byte) []byte { return b[:len(b)-1] }),
+		"zero seen":  tamper(func(b []byte) []byte { b[len(b)-1] = 0; return b }),
+	} {
+		if _, err := s.Cursor(CursorOptions{Token: token}); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: error = %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
+
+// TestCursorTokenResolution resumes a rollup page with options naming
+// another resolution; the token's resolution wins.
+func TestCursorTokenResolution(t *testing.T) {
+	db, err := OpenTSDB(TSDBOptions{Dir: t.TempDir(), Fsync: FsyncNever, Rollups: []RollupTier{{Resolution: time.Minute}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 6*60; i += 20 {
+		if err := db.Save(testSnapshot(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Buckets of minutes 0 to 4 are complete; minute 5 is still open.
+	all := readAll(t, db, CursorOptions{Resolution: time.Minute})
+	if len(all) != 5 {
+		t.Fatalf("rollup cursor returned %d buckets, want 5", len(all))
+	}
+
+	c, err := db.Cursor(CursorOptions{Resolution: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Next()
+	c.Next()
+	token := c.Token()
+	c.Close()
+	rest := readAll(t, db, CursorOptions{Token: token})
+	sameSnapshots(t, rest, all[2:])
+
+	if _, err := NewInMemoryStorage().Cursor(CursorOptions{Token: token}); !errors.Is(err, errNoRollups) {
+		t.Errorf("rollup token on a memory store: error = %v, want %v", err, errNoRollups)
+	}
+}
@@ -0,0 +1,732 @@
+// pkg/storage/disk.go
+
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy controls when writes are flushed to stable storage.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the write-ahead log after every snapshot.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs the write-ahead log periodically.
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system.
+	FsyncNever FsyncPolicy = "never"
+)
+
+// DiskOptions configures a DiskStorage.
+type DiskOptions struct {
+	Dir           string
+	Retention     Retention
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration // Defaults to one second.
+	// A segment is sealed once it holds this many snapshots or bytes.
+	SegmentMaxSnapshots int   // Defaults to 120.
+	SegmentMaxBytes     int64 // Defaults to 8 MiB.
+	// Keyring encrypts new segments and log records. Without one they are
+	// stored unencrypted.
+	Keyring *Keyring
+}
+
+// segmentEntry locates one snapshot within a segment file.
+type segmentEntry struct {
+	ts     int64 // Snapshot timestamp, in Unix nanoseconds.
+	offset int64 // Offset of the framed record.
+}
+
+// segment is the in-memory index of a sealed segment file.
+type segment struct {
+	seq      int
+	path     string
+	size     int64
+	min, max int64
+	entries  []segmentEntry
+}
+
+// DiskStorage persists snapshots in append-only segment files. Snapshots
+// are first appended to a write-ahead log; once enough accumulate they are
+// sealed into an immutable, time-indexed segment and the log is reset.
+// After a crash, the log is replayed up to its last intact record.
+type DiskStorage struct {
+	mu       sync.RWMutex
+	opts     DiskOptions
+	segDir   string
+	segments []*segment // Oldest first.
+	nextSeq  int
+
+	lock      *os.File
+	wal       *os.File
+	walSize   int64
+	active    []Snapshot // Snapshots in the write-ahead log.
+	dirty     bool       // Unsynced writes in the write-ahead log.
+	evicted   uint64
+	recovered int // Snapshots replayed from the log when opening.
+
+	now  func() time.Time
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// OpenDiskStorage opens or creates a DiskStorage in opts.Dir, recovering
+// any snapshots left in the write-ahead log.
+func OpenDiskStorage(opts DiskOptions) (*DiskStorage, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("storage directory is required")
+	}
+	switch opts.Fsync {
+	case "":
+		opts.Fsync = FsyncInterval
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("invalid fsync policy %q", opts.Fsync)
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = time.Second
+	}
+	if opts.SegmentMaxSnapshots <= 0 {
+		opts.SegmentMaxSnapshots = 120
+	}
+	if opts.SegmentMaxBytes <= 0 {
+		opts.SegmentMaxBytes = 8 << 20
+	}
+
+	d := &DiskStorage{
+		opts:   opts,
+		segDir: filepath.Join(opts.Dir, "segments"),
+		now:    time.Now,
+		done:   make(chan struct{}),
+	}
+	if err := os.MkdirAll(d.segDir, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	d.lock = lock
+	if err := d.loadSegments(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	if err := d.openWAL(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	d.enforce()
+
+	if opts.Fsync == FsyncInterval {
+		d.wg.Add(1)
+		go d.syncLoop()
+	}
+	return d, nil
+}
+
+// loadSegments indexes the sealed segment files and removes leftovers of
+// interrupted seals.
+func (d *DiskStorage) loadSegments() error {
+	entries, err := os.ReadDir(d.segDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(d.segDir, name))
+			continue
+		}
+		seqStr, ok := strings.CutSuffix(name, ".seg")
+		if !ok {
+			continue
+		}
+		seq, err := strconv.Atoi(seqStr)
+		if err != nil {
+			continue
+		}
+		seg, err := indexSegment(filepath.Join(d.segDir, name), seq, d.opts.Keyring)
+		if err != nil {
+			return fmt.Errorf("indexing segment %s: %w", name, err)
+		}
+		if len(seg.entries) == 0 {
+			os.Remove(seg.path)
+			continue
+		}
+		d.segments = append(d.segments, seg)
+	}
+	sort.Slice(d.segments, func(i, j int) bool { return d.segments[i].seq < d.segments[j].seq })
+	if n := len(d.segments); n > 0 {
+		d.nextSeq = d.segments[n-1].seq + 1
+	}
+	return nil
+}
+
+// indexSegment reads a segment file and records each snapshot's timestamp
+// and offset. A damaged tail is ignored; data that cannot be decrypted is
+// an error.
+func indexSegment(path string, seq int, k *Keyring) (*segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seg := &segment{seq: seq, path: path}
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, n, err := k.readRecord(r)
+		if isKeyError(err) {
+			return nil, err
+		}
+		if err != nil {
+			break
+		}
+		ts, err := snapshotTime(payload)
+		if err != nil {
+			break
+		}
+		seg.add(ts, offset)
+		offset += n
+	}
+	seg.size = offset
+	return seg, nil
+}
+
+// snapshotTime decodes only the timestamp of an encoded snapshot.
+func snapshotTime(payload []byte) (int64, error) {
+	var s struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return 0, err
+	}
+	return s.Timestamp.UnixNano(), nil
+}
+
+// add appends an index entry, widening the segment's time range.
+func (s *segment) add(ts, offset int64) {
+	if len(s.entries) == 0 || ts < s.min {
+		s.min = ts
+	}
+	if len(s.entries) == 0 || ts > s.max {
+		s.max = ts
+	}
+	s.entries = append(s.entries, segmentEntry{ts: ts, offset: offset})
+}
+
+// openWAL opens the write-ahead log, replays intact records and truncates
+// anything after the last one. Records already sealed into a segment are
+// skipped; see openLog.
+func (d *DiskStorage) openWAL() error {
+	f, size, seq, err := openLog(filepath.Join(d.opts.Dir, "wal.log"), d.opts.Keyring, d.nextSeq, func(payload []byte) error {
+		var snap Snapshot
+		if err := json.Unmarshal(payload, &snap); err != nil {
+			return err
+		}
+		d.active = append(d.active, snap)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.wal = f
+	d.walSize = size
+	d.nextSeq = seq
+	d.recovered = len(d.active)
+	return nil
+}
+
+// Recovered returns how many snapshots were replayed from the write-ahead
+// log when the storage was opened.
+func (d *DiskStorage) Recovered() int {
+	return d.recovered
+}
+
+// syncLoop flushes the write-ahead log every FsyncInterval.
+func (d *DiskStorage) syncLoop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.opts.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			if d.dirty && d.wal != nil {
+				d.wal.Sync()
+				d.dirty = false
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// Save appends a snapshot to the write-ahead log, sealing a segment when
+// the log is full and applying retention.
+func (d *DiskStorage) Save(snapshot Snapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	record, err := d.opts.Keyring.encodeRecord(payload)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.wal == nil {
+		return fmt.Errorf("storage is closed")
+	}
+	if _, err := d.wal.Write(record); err != nil {
+		// Drop a partly written record so the next one is not written
+		// after it, where replay could not reach it.
+		rewindLog(d.wal, d.walSize)
+		return err
+	}
+	if d.opts.Fsync == FsyncAlways {
+		if err := d.wal.Sync(); err != nil {
+			rewindLog(d.wal, d.walSize)
+			return err
+		}
+	} else {
+		d.dirty = true
+	}
+	d.walSize += int64(len(record))
+	d.active = append(d.active, snapshot)
+
+	if len(d.active) >= d.opts.SegmentMaxSnapshots || d.walSize >= d.opts.SegmentMaxBytes {
+		if err := d.seal(); err != nil {
+			return fmt.Errorf("sealing segment: %v", err)
+		}
+	}
+	d.enforce()
+	return nil
+}
+
+// seal writes the snapshots in the write-ahead log to a new segment and
+// resets the log. The segment is written to a temporary file and renamed
+// into place before the log is reset. A crash in between leaves both, and
+// the log's header then names a segment that exists, so its records are
+// not replayed again. The caller holds d.mu.
+func (d *DiskStorage) seal() error {
+	if len(d.active) == 0 {
+		return nil
+	}
+	payloads := make([][]byte, len(d.active))
+	times := make([]int64, len(d.active))
+	for i, snap := range d.active {
+		payload, err := json.Marshal(snap)
+		if err != nil {
+			return err
+		}
+		payloads[i], times[i] = payload, snap.Timestamp.UnixNano()
+	}
+	path := filepath.Join(d.segDir, fmt.Sprintf("%010d.seg", d.nextSeq))
+	seg, err := writeSegment(path, d.nextSeq, d.opts.Keyring, payloads, times)
+	if err != nil {
+		return err
+	}
+	syncDir(d.segDir)
+
+	// The snapshots are durable in the segment; start a fresh log.
+	d.segments = append(d.segments, seg)
+	d.nextSeq++
+	d.active = nil
+	size, err := resetLog(d.wal, d.nextSeq)
+	if err != nil {
+		return err
+	}
+	d.walSize = size
+	d.dirty = false
+	return nil
+}
+
+// writeSegment writes encoded snapshots taken at the given times, in Unix
+// nanoseconds, to a segment file and returns its index. The file is
+// written to a temporary file and renamed into place.
+func writeSegment(path string, seq int, k *Keyring, payloads [][]byte, times []int64) (*segment, error) {
+	seg := &segment{seq: seq, path: path}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	var offset int64
+	for i, payload := range payloads {
+		record, err := k.encodeRecord(payload)
+		if err == nil {
+			_, err = w.Write(record)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return nil, err
+		}
+		seg.add(times[i], offset)
+		offset += int64(len(record))
+	}
+	seg.size = offset
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return seg, nil
+}
+
+// syncDir flushes directory entries so a rename survives a crash. Errors
+// are ignored because not every platform supports syncing directories.
+func syncDir(dir string) {
+	if f, err := os.Open(dir); err == nil {
+		f.Sync()
+		f.Close()
+	}
+}
+
+// enforce deletes the oldest segments that fall outside the retention
+// limits. Retention works on whole segments, so up to a segment's worth of
+// extra history may be dropped. The caller holds d.mu.
+func (d *DiskStorage) enforce() {
+	r := d.opts.Retention
+	count, size := len(d.active), d.walSize
+	for _, s := range d.segments {
+		count += len(s.entries)
+		size += s.size
+	}
+	cutoff := int64(0)
+	if r.MaxAge > 0 {
+		cutoff = d.now().Add(-r.MaxAge).UnixNano()
+	}
+	for len(d.segments) > 0 {
+		oldest := d.segments[0]
+		expired := r.MaxAge > 0 && oldest.max < cutoff
+		tooMany := r.MaxSnapshots > 0 && count > r.MaxSnapshots
+		tooBig := r.MaxBytes > 0 && size > r.MaxBytes
+		if !expired && !tooMany && !tooBig {
+			return
+		}
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		count -= len(oldest.entries)
+		size -= oldest.size
+		d.evicted += uint64(len(oldest.entries))
+		d.segments = d.segments[1:]
+	}
+}
+
+// readSegment decodes the snapshots of seg whose timestamps satisfy keep.
+func readSegment(seg *segment, k *Keyring, keep func(ts int64) bool) ([]Snapshot, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Snapshot
+	for _, e := range seg.entries {
+		if !keep(e.ts) {
+			continue
+		}
+		if _, err := f.Seek(e.offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		payload, _, err := k.readRecord(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s at offset %d: %v", seg.path, e.offset, err)
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(payload, &snap); err != nil {
+			return nil, err
+		}
+		out = append(out, snap)
+	}
+	return out, nil
+}
+
+// GetAll returns every retained snapshot, oldest first.
+func (d *DiskStorage) GetAll() ([]Snapshot, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var out []Snapshot
+	for _, seg := range d.segments {
+		snaps, err := readSegment(seg, d.opts.Keyring, func(int64) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, snaps...)
+	}
+	return append(out, d.active...), nil
+}
+
+// Query returns snapshots between two timestamps, exclusive. Only segments
+// whose time range overlaps the window are read, and within them only the
+// matching records.
+func (d *DiskStorage) Query(from, to time.Time) ([]Snapshot, error) {
+	lo, hi := from.UnixNano(), to.UnixNano()
+	inRange := func(ts int64) bool { return ts > lo && ts < hi }
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var out []Snapshot
+	for _, seg := range d.segments {
+		if seg.max <= lo || seg.min >= hi {
+			continue
+		}
+		snaps, err := readSegment(seg, d.opts.Keyring, inRange)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, snaps...)
+	}
+	for _, snap := range d.active {
+		if snap.Timestamp.After(from) && snap.Timestamp.Before(to) {
+			out = append(out, snap)
+		}
+	}
+	return out, nil
+}
+
+// Stats reports the number and on-disk size of retained snapshots.
+func (d *DiskStorage) Stats() Stats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	st := Stats{Backend: "disk", Evicted: d.evicted, Snapshots: len(d.active), Bytes: d.walSize}
+	first, last := true, int64(0)
+	var oldest int64
+	note := func(ts int64) {
+		if first || ts < oldest {
+			oldest = ts
+		}
+		if first || ts > last {
+			last = ts
+		}
+		first = false
+	}
+	for _, s := range d.segments {
+		st.Snapshots += len(s.entries)
+		st.Bytes += s.size
+		note(s.min)
+		note(s.max)
+	}
+	for _, snap := range d.active {
+		note(snap.Timestamp.UnixNano())
+	}
+	if !first {
+		st.Oldest = time.Unix(0, oldest)
+		st.Newest = time.Unix(0, last)
+	}
+	return st
+}
+
+// Close syncs and closes the write-ahead log. Snapshots in the log are
+// replayed the next time the storage is opened.
+func (d *DiskStorage) Close() error {
+	d.mu.Lock()
+	if d.wal == nil {
+		d.mu.Unlock()
+		return nil
+	}
+	close(d.done)
+	err := d.wal.Sync()
+	if cerr := d.wal.Close(); err == nil {
+		err = cerr
+	}
+	d.wal = nil
+	d.lock.Close()
+	d.mu.Unlock()
+	d.wg.Wait()
+	return err
+}
+
+// Reencrypt seals the write-ahead log into a segment, so that it holds no
+// records, and rewrites every segment holding records that are unencrypted
+// or encrypted with a previous key. Cursors opened before may fail to read
+// rewritten segments.
+func (d *DiskStorage) Reencrypt() (int, error) {
+	if d.opts.Keyring == nil {
+		return 0, ErrNoKey
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.wal == nil {
+		return 0, fmt.Errorf("storage is closed")
+	}
+	if err := d.seal(); err != nil {
+		return 0, fmt.Errorf("sealing segment: %v", err)
+	}
+	n := 0
+	for i, seg := range d.segments {
+		rewritten, err := d.reencryptSegment(seg)
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", seg.path, err)
+		}
+		if rewritten != nil {
+			d.segments[i] = rewritten
+			n++
+		}
+	}
+	if n > 0 {
+		syncDir(d.segDir)
+	}
+	return n, nil
+}
+
+// reencryptSegment rewrites seg with the active key and returns its new
+// index, or nil if every record already uses the active key. The caller
+// holds d.mu.
+func (d *DiskStorage) reencryptSegment(seg *segment) (*segment, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	k := d.opts.Keyring
+	r := bufio.NewReader(f)
+	payloads := make([][]byte, len(seg.entries))
+	times := make([]int64, len(seg.entries))
+	current := true
+	for i, e := range seg.entries {
+		payload, sealed, _, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		if !sealed || !k.isActive(payload) {
+			current = false
+		}
+		if sealed {
+			if payload, err = k.open(payload); err != nil {
+				return nil, err
+			}
+		}
+		payloads[i], times[i] = payload, e.ts
+	}
+	if current {
+		return nil, nil
+	}
+	return writeSegment(seg.path, seg.seq, k, payloads, times)
+}
+
+// Cursor returns a cursor over the retained snapshots. Segments are read
+// one record at a time, and only those overlapping the cursor's range.
+func (d *DiskStorage) Cursor(opts CursorOptions) (Cursor, error) {
+	c, err := newCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.Resolution != 0 {
+		return nil, errNoRollups
+	}
+	d.mu.RLock()
+	src := &diskSource{
+		c:        c,
+		k:        d.opts.Keyring,
+		segments: append([]*segment(nil), d.segments...),
+		active:   &sliceSource{snaps: append([]Snapshot(nil), d.active...)},
+	}
+	d.mu.RUnlock()
+	if c.opts.Reverse {
+		for i, j := 0, len(src.segments)-1; i < j; i, j = i+1, j-1 {
+			src.segments[i], src.segments[j] = src.segments[j], src.segments[i]
+		}
+		reverseSnapshots(src.active.snaps)
+	}
+	c.src = src
+	return c, nil
+}
+
+// diskSource yields the snapshots of a DiskStorage: the sealed segments,
+// then the write-ahead log, or the reverse.
+type diskSource struct {
+	c        *cursor
+	k        *Keyring
+	segments []*segment // Remaining segments, in cursor order.
+	active   *sliceSource
+
+	f       *os.File       // Segment being read.
+	entries []segmentEntry // Its remaining entries, in cursor order.
+}
+
+func (s *diskSource) next() (Snapshot, bool, error) {
+	if s.c.opts.Reverse && len(s.active.snaps) > 0 {
+		return s.active.next()
+	}
+	for {
+		for s.f != nil && len(s.entries) > 0 {
+			e := s.entries[0]
+			s.entries = s.entries[1:]
+			if !s.c.inWindow(e.ts, e.ts) {
+				continue
+			}
+			payload, _, err := s.k.readRecord(io.NewSectionReader(s.f, e.offset, maxRecordSize+recordHeaderSize))
+			if err != nil {
+				return Snapshot{}, false, fmt.Errorf("%s at offset %d: %v", s.f.Name(), e.offset, err)
+			}
+			var snap Snapshot
+			if err := json.Unmarshal(payload, &snap); err != nil {
+				return Snapshot{}, false, err
+			}
+			return snap, true, nil
+		}
+		if s.f != nil {
+			s.f.Close()
+			s.f = nil
+		}
+		if len(s.segments) == 0 {
+			return s.active.next()
+		}
+		seg := s.segments[0]
+		s.segments = s.segments[1:]
+		if !s.c.inWindow(seg.min, seg.max) {
+			continue
+		}
+		f, err := os.Open(seg.path)
+		if os.IsNotExist(err) {
+			// Removed by retention since the cursor was opened.
+			continue
+		}
+		if err != nil {
+			return Snapshot{}, false, err
+		}
+		s.f = f
+		s.entries = append([]segmentEntry(nil), seg.entries...)
+		if s.c.opts.Reverse {
+			for i, j := 0, len(s.entries)-1; i < j; i, j = i+1, j-1 {
+				s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+			}
+		}
+	}
+}
+
+func (s *diskSource) close() error {
+	s.segments, s.entries = nil, nil
+	s.active.close()
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// pkg/storage/disk_test.go
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testSnapshot returns a snapshot taken i seconds after a fixed time.
+func testSnapshot(i int) Snapshot {
+	return Snapshot{
+		Timestamp: time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC),
+		Labels:    map[string]string{"host": "a"},
+		Metrics:   map[string]interface{}{"cpu": float64(i)},
+	}
+}
+
+func openTestDisk(t *testing.T, dir string) *DiskStorage {
+	t.Helper()
+	d, err := OpenDiskStorage(DiskOptions{Dir: dir, Fsync: FsyncAlways, SegmentMaxSnapshots: 10})
+	if err != nil {
+		t.Fatalf("OpenDiskStorage: %v", err)
+	}
+	return d
+}
+
+func TestDiskReplaysLog(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestDisk(t, dir)
+	for i := 0; i < 3; i++ {
+		if err := d.Save(testSnapshot(i)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	d.Close()
+
+	d = openTestDisk(t, dir)
+	defer d.Close()
+	if d.Recovered() != 3 {
+		t.Errorf("Recovered() = %d, want 3", d.Recovered())
+	}
+	all, err := d.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("GetAll returned %d snapshots, want 3", len(all))
+	}
+	for i, snap := range all {
+		if !snap.Timestamp.Equal(testSnapshot(i).Timestamp) || snap.Metrics["cpu"] != float64(i) {
+			t.Errorf("snapshot %d = %+v", i, snap)
+		}
+	}
+}
+
+// TestDiskCrashAfterSeal recovers from a crash after a segment was written
+// but before the log was reset, which leaves the sealed records in the log.
+func TestDiskCrashAfterSeal(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestDisk(t, dir)
+	for i := 0; i < 2; i++ {
+		if err := d.Save(testSnapshot(i)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	walPath := filepath.Join(dir, "wal.log")
+	log, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.mu.Lock()
+	err = d.seal()
+	d.mu.Unlock()
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	d.Close()
+	if err := os.WriteFile(walPath, log, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d = openTestDisk(t, dir)
+	if d.Recovered() != 0 {
+		t.Errorf("Recovered() = %d, want 0", d.Recovered())
+	}
+	all, err := d.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("GetAll returned %d snapshots, want 2", len(all))
+	}
+
+	// New snapshots go to the next segment and survive another restart.
+	if err := d.Save(testSnapshot(2)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	d.Close()
+	d = openTestDisk(t, dir)
+	defer d.Close()
+	if all, _ := d.GetAll(); len(all) != 3 {
+		t.Fatalf("GetAll returned %d snapshots after restart, want 3", len(all))
+	}
+}
+
+// TestDiskLogWithoutHeader replays logs written before logs had headers.
+func TestDiskLogWithoutHeader(t *testing.T) {
+	dir := t.TempDir()
+	var log []byte
+	for i := 0; i < 2; i++ {
+		record, err := (*Keyring)(nil).encodeRecord([]byte(`{"timestamp":"2024-01-01T00:00:0` + string(rune('0'+i)) + `Z","metrics":{}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log = append(log, record...)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "wal.log"), log, 0600); err != nil {
+		t.Fatal(err)
+	}
+	d := openTestDisk(t, dir)
+	defer d.Close()
+	if d.Recovered() != 2 {
+		t.Errorf("Recovered() = %d, want 2", d.Recovered())
+	}
+}
+
+// TestDiskTornRecord drops a partly written record at the end of the log.
+func TestDiskTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestDisk(t, dir)
+	if err := d.Save(testSnapshot(0)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	d.Close()
+	f, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1})
+	f.Close()
+
+	d = openTestDisk(t, dir)
+	if err := d.Save(testSnapshot(1)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	d.Close()
+	d = openTestDisk(t, dir)
+	defer d.Close()
+	if all, _ := d.GetAll(); len(all) != 2 {
+		t.Fatalf("GetAll returned %d snapshots, want 2", len(all))
+	}
+}
@@ -0,0 +1,230 @@
+// pkg/storage/encrypt.go
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Encryption errors. Data that cannot be decrypted is never treated as a
+// damaged tail, so opening a store with the wrong keys fails instead of
+// discarding the data.
+var (
+	// ErrNoKey reports encrypted data in a store opened without a keyring.
+	ErrNoKey = errors.New("storage data is encrypted but no encryption key is configured")
+	// ErrUnknownKey reports data encrypted with a key that is not in the keyring.
+	ErrUnknownKey = errors.New("storage data is encrypted with a key that is not configured")
+	// errDecrypt reports sealed data that fails authentication.
+	errDecrypt = errors.New("decryption failed")
+)
+
+// Sealed data is [key id 4][nonce 12][ciphertext][tag 16].
+const (
+	keyIDSize   = 4
+	nonceSize   = 12
+	sealedExtra = keyIDSize + nonceSize + 16
+)
+
+// Keyring encrypts persisted snapshots with AES-256-GCM. The first key
+// encrypts new data and every key decrypts, so after a rotation data
+// written with the previous key stays readable until it is re-encrypted.
+// Keys are identified in sealed data by the first bytes of their SHA-256
+// hash. A nil *Keyring stores data unencrypted.
+type Keyring struct {
+	keys []keyringKey // Active key first.
+}
+
+// keyringKey is one key of a Keyring.
+type keyringKey struct {
+	id   uint32
+	aead cipher.AEAD
+}
+
+// NewKeyring returns a keyring of 32-byte keys, the first of which is
+// active.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one encryption key is required")
+	}
+	k := &Keyring{}
+	seen := map[uint32]bool{}
+	for i, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %d is %d bytes; AES-256 keys are 32 bytes", i+1, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		id := binary.BigEndian.Uint32(sum[:])
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		k.keys = append(k.keys, keyringKey{id: id, aead: aead})
+	}
+	return k, nil
+}
+
+// ParseKeyring parses keys separated by whitespace, each 64 hex characters
+// or the standard base64 encoding of 32 bytes, such as the output of
+// "openssl rand -hex 32". Lines starting with # are comments. The first key
+// is active.
+func ParseKeyring(text string) (*Keyring, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			key, err := hex.DecodeString(field)
+			if err != nil || len(key) != 32 {
+				key, err = base64.StdEncoding.DecodeString(field)
+			}
+			if err != nil || len(key) != 32 {
+				return nil, fmt.Errorf("encryption key %d is not 32 bytes in hex or base64", len(keys)+1)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return NewKeyring(keys...)
+}
+
+// LoadKeyring reads the keys of ParseKeyring from a file or, when file is
+// empty, from an environment variable.
+func LoadKeyring(file, env string) (*Keyring, error) {
+	switch {
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading encryption key file: %v", err)
+		}
+		return ParseKeyring(string(data))
+	case env != "":
+		text, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, fmt.Errorf("encryption key variable %s is not set", env)
+		}
+		return ParseKeyring(text)
+	default:
+		return nil, fmt.Errorf("an encryption key file or environment variable is required")
+	}
+}
+
+// ActiveKeyID returns the identifier of the key that encrypts new data, as
+// hex.
+func (k *Keyring) ActiveKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return fmt.Sprintf("%08x", k.keys[0].id)
+}
+
+// seal encrypts data with the active key.
+func (k *Keyring) seal(data []byte) ([]byte, error) {
+	active := k.keys[0]
+	out := make([]byte, keyIDSize+nonceSize, sealedExtra+len(data))
+	binary.BigEndian.PutUint32(out, active.id)
+	if _, err := rand.Read(out[keyIDSize:]); err != nil {
+		return nil, err
+	}
+	return active.aead.Seal(out, out[keyIDSize:], data, nil), nil
+}
+
+// open decrypts data produced by seal with any key of the keyring.
+func (k *Keyring) open(data []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	if len(data) < sealedExtra {
+		return nil, errDecrypt
+	}
+	key, ok := k.key(sealedKeyID(data))
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	plain, err := key.aead.Open(nil, data[keyIDSize:keyIDSize+nonceSize], data[keyIDSize+nonceSize:], nil)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plain, nil
+}
+
+// key returns the key with the given identifier.
+func (k *Keyring) key(id uint32) (keyringKey, bool) {
+	if k != nil {
+		for _, key := range k.keys {
+			if key.id == id {
+				return key, true
+			}
+		}
+	}
+	return keyringKey{}, false
+}
+
+// isActive reports whether sealed data was encrypted with the active key.
+func (k *Keyring) isActive(sealed []byte) bool {
+	return k != nil && len(sealed) >= keyIDSize && sealedKeyID(sealed) == k.keys[0].id
+}
+
+// sealedKeyID returns the key identifier of sealed data.
+func sealedKeyID(data []byte) uint32 {
+	return binary.BigEndian.Uint32(data)
+}
+
+// encodeRecord frames payload as a record, encrypted when k is not nil.
+func (k *Keyring) encodeRecord(payload []byte) ([]byte, error) {
+	if k == nil {
+		return frameRecord(payload, false), nil
+	}
+	sealed, err := k.seal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return frameRecord(sealed, true), nil
+}
+
+// readRecord reads one record like readFrame and decrypts its payload. An
+// encrypted record that cannot be decrypted returns ErrNoKey, ErrUnknownKey
+// or a decryption error rather than errCorruptRecord.
+func (k *Keyring) readRecord(r io.Reader) ([]byte, int64, error) {
+	payload, sealed, n, err := readFrame(r)
+	if err != nil || !sealed {
+		return payload, n, err
+	}
+	plain, err := k.open(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	return plain, n, nil
+}
+
+// isKeyError reports whether err means data could not be decrypted, as
+// opposed to being damaged.
+func isKeyError(err error) bool {
+	return errors.Is(err, ErrNoKey) || errors.Is(err, ErrUnknownKey) || errors.Is(err, errDecrypt)
+}
+
+// Reencrypter is implemented by backends that can rewrite their files
+// with the active key of their keyring.
+type Reencrypter interface {
+	// Reencrypt rewrites every file holding data that is not encrypted with
+	// the active key and returns the number of files rewritten.
+	Reencrypt() (int, error)
+}
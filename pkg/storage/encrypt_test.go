@@ -0,0 +1,242 @@
+// pkg/storage/encrypt_test.go
+
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testKey returns a 32-byte key filled with b.
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func testKeyring(t *testing.T, keys ...[]byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	k := testKeyring(t, testKey(1))
+	sealed, err := k.seal([]byte("snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("snapshot")) {
+		t.Error("sealed data contains the plaintext")
+	}
+	plain, err := k.open(sealed)
+	if err != nil || string(plain) != "snapshot" {
+		t.Fatalf("open = %q, %v", plain, err)
+	}
+
+	// A rotated keyring still opens data sealed with the previous key.
+	rotated := testKeyring(t, testKey(2), testKey(1))
+	if plain, err := rotated.open(sealed); err != nil || string(plain) != "snapshot" {
+		t.Errorf("open after rotation = %q, %v", plain, err)
+	}
+	if rotated.isActive(sealed) || !k.isActive(sealed) {
+		t.Error("isActive does not follow the active key")
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	for _, tc := range []struct {
+		name string
+		k    *Keyring
+		data []byte
+		want error
+	}{
+		{"no keyring", nil, sealed, ErrNoKey},
+		{"wrong key", testKeyring(t, testKey(3)), sealed, ErrUnknownKey},
+		{"tampered", k, tampered, errDecrypt},
+		{"truncated", k, sealed[:sealedExtra-1], errDecrypt},
+		{"no key id", k, sealed[:2], errDecrypt},
+		{"empty", k, nil, errDecrypt},
+	} {
+		if _, err := tc.k.open(tc.data); !errors.Is(err, tc.want) {
+			t.Errorf("%s: open error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	hexKey := hex.EncodeToString(testKey(1))
+	b64Key := base64.StdEncoding.EncodeToString(testKey(2))
+	k, err := ParseKeyring("# active key first\n" + hexKey + "\n" + b64Key + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k.keys) != 2 || k.ActiveKeyID() != testKeyring(t, testKey(1)).ActiveKeyID() {
+		t.Errorf("keyring has %d keys, active %s", len(k.keys), k.ActiveKeyID())
+	}
+	for _, text := range []string{"", "abcd", hexKey[:62]} {
+		if _, err := ParseKeyring(text); err == nil {
+			t.Errorf("ParseKeyring(%q) succeeded", text)
+		}
+	}
+}
+
+// openKeyedDisk opens a disk store that seals a segment every two snapshots.
+func openKeyedDisk(dir string, k *Keyring) (*DiskStorage, error) {
+	return OpenDiskStorage(DiskOptions{Dir: dir, Fsync: FsyncAlways, SegmentMaxSnapshots: 2, Keyring: k})
+}
+
+// saveDisk opens a disk store, saves snapshots first..last-1 and closes it.
+func saveDisk(t *testing.T, dir string, k *Keyring, first, last int) {
+	t.Helper()
+	d, err := openKeyedDisk(dir, k)
+	if err != nil {
+		t.Fatalf("OpenDiskStorage: %v", err)
+	}
+	defer d.Close()
+	for i := first; i < last; i++ {
+		if err := d.Save(testSnapshot(i)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+}
+
+// checkDisk opens a disk store and checks that it holds n snapshots.
+func checkDisk(t *testing.T, dir string, k *Keyring, n int) {
+	t.Helper()
+	d, err := openKeyedDisk(dir, k)
+	if err != nil {
+		t.Fatalf("OpenDiskStorage: %v", err)
+	}
+	defer d.Close()
+	all, err := d.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != n {
+		t.Fatalf("GetAll returned %d snapshots, want %d", len(all), n)
+	}
+	for i, snap := range all {
+		if !snap.Timestamp.Equal(testSnapshot(i).Timestamp) {
+			t.Errorf("snapshot %d at %v", i, snap.Timestamp)
+		}
+	}
+}
+
+func TestDiskEncryption(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := testKeyring(t, testKey(1))
+	saveDisk(t, dir, oldKey, 0, 3)
+	checkDisk(t, dir, oldKey, 3)
+
+	// After rotation, old segments and log records stay readable.
+	rotated := testKeyring(t, testKey(2), testKey(1))
+	saveDisk(t, dir, rotated, 3, 6)
+	checkDisk(t, dir, rotated, 6)
+
+	for _, tc := range []struct {
+		name string
+		k    *Keyring
+		want error
+	}{
+		{"no keyring", nil, ErrNoKey},
+		{"new key only", testKeyring(t, testKey(2)), ErrUnknownKey},
+	} {
+		d, err := openKeyedDisk(dir, tc.k)
+		if err == nil {
+			_, err = d.GetAll()
+			d.Close()
+		}
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+// TestDiskMixedRecords reads a store written partly before encryption was
+// enabled, then re-encrypts it.
+func TestDiskMixedRecords(t *testing.T) {
+	dir := t.TempDir()
+	saveDisk(t, dir, nil, 0, 3)
+	k := testKeyring(t, testKey(1))
+	saveDisk(t, dir, k, 3, 5)
+	checkDisk(t, dir, k, 5)
+
+	rotated := testKeyring(t, testKey(2), testKey(1))
+	d, err := openKeyedDisk(dir, rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := d.Reencrypt()
+	if err != nil || n == 0 {
+		t.Fatalf("Reencrypt = %d, %v; want rewritten segments", n, err)
+	}
+	if n, err := d.Reencrypt(); err != nil || n != 0 {
+		t.Errorf("second Reencrypt = %d, %v; want nothing rewritten", n, err)
+	}
+	d.Close()
+
+	// Everything is now readable with the new key alone.
+	checkDisk(t, dir, testKeyring(t, testKey(2)), 5)
+	if _, err := openKeyedDisk(dir, nil); !errors.Is(err, ErrNoKey) {
+		t.Errorf("open without keyring: error = %v, want %v", err, ErrNoKey)
+	}
+}
+
+func TestTSDBReencrypt(t *testing.T) {
+	dir := t.TempDir()
+	open := func(k *Keyring) *TSDB {
+		t.Helper()
+		db, err := OpenTSDB(TSDBOptions{Dir: dir, Fsync: FsyncAlways, BlockDuration: time.Minute, Keyring: k})
+		if err != nil {
+			t.Fatalf("OpenTSDB: %v", err)
+		}
+		return db
+	}
+	var want []Snapshot
+	save := func(db *TSDB, first, last int) {
+		t.Helper()
+		for i := first; i < last; i++ {
+			snap := richSnapshot(i)
+			if err := db.Save(snap); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			want = append(want, snap)
+		}
+	}
+
+	db := open(nil)
+	save(db, 0, 3)
+	db.Close()
+	db = open(testKeyring(t, testKey(1)))
+	save(db, 3, 6)
+	db.Close()
+
+	db = open(testKeyring(t, testKey(2), testKey(1)))
+	got, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	sameSnapshots(t, got, want)
+	n, err := db.Reencrypt()
+	if err != nil || n == 0 {
+		t.Fatalf("Reencrypt = %d, %v; want rewritten blocks", n, err)
+	}
+	if n, err := db.Reencrypt(); err != nil || n != 0 {
+		t.Errorf("second Reencrypt = %d, %v; want nothing rewritten", n, err)
+	}
+	db.Close()
+
+	db = open(testKeyring(t, testKey(2)))
+	defer db.Close()
+	got, err = db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll with the new key: %v", err)
+	}
+	sameSnapshots(t, got, want)
+}
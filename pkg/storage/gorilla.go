@@ -0,0 +1,231 @@
+// pkg/storage/gorilla.go
+
+package storage
+
+import (
+	"math"
+	"math/bits"
+)
+
+// chunk compresses a series of (timestamp, value) points as described in
+// "Gorilla: A Fast, Scalable, In-Memory Time Series Database" (Pelkonen et
+// al., 2015): timestamps as delta-of-deltas and values as the XOR with the
+// previous value. Timestamps are Unix milliseconds.
+type chunk struct {
+	w     bitWriter
+	count int
+
+	t, tDelta  int64
+	v          uint64
+	leading    int
+	trailing   int
+	minT, maxT int64
+}
+
+// append adds a point to the chunk.
+func (c *chunk) append(t int64, v float64) {
+	vbits := math.Float64bits(v)
+	switch c.count {
+	case 0:
+		c.w.writeBits(uint64(t), 64)
+		c.w.writeBits(vbits, 64)
+		c.minT, c.maxT = t, t
+		c.leading = -1
+	default:
+		delta := t - c.t
+		writeDoD(&c.w, delta-c.tDelta)
+		c.tDelta = delta
+		c.writeValue(vbits)
+		if t < c.minT {
+			c.minT = t
+		}
+		if t > c.maxT {
+			c.maxT = t
+		}
+	}
+	c.t, c.v = t, vbits
+	c.count++
+}
+
+// writeDoD writes a delta-of-delta using the variable-length buckets of the
+// Gorilla paper, widened for millisecond timestamps.
+func writeDoD(w *bitWriter, dod int64) {
+	switch {
+	case dod == 0:
+		w.writeBit(false)
+	case dod >= -63 && dod <= 64:
+		w.writeBits(0b10, 2)
+		w.writeBits(uint64(dod), 7)
+	case dod >= -255 && dod <= 256:
+		w.writeBits(0b110, 3)
+		w.writeBits(uint64(dod), 9)
+	case dod >= -2047 && dod <= 2048:
+		w.writeBits(0b1110, 4)
+		w.writeBits(uint64(dod), 12)
+	default:
+		w.writeBits(0b1111, 4)
+		w.writeBits(uint64(dod), 64)
+	}
+}
+
+// writeValue writes the XOR of vbits with the previous value.
+func (c *chunk) writeValue(vbits uint64) {
+	xor := vbits ^ c.v
+	if xor == 0 {
+		c.w.writeBit(false)
+		return
+	}
+	c.w.writeBit(true)
+	leading := bits.LeadingZeros64(xor)
+	trailing := bits.TrailingZeros64(xor)
+	if leading > 31 {
+		leading = 31
+	}
+	if c.leading >= 0 && leading >= c.leading && trailing >= c.trailing {
+		// The meaningful bits fit in the previous window.
+		c.w.writeBit(false)
+		c.w.writeBits(xor>>uint(c.trailing), 64-c.leading-c.trailing)
+		return
+	}
+	c.leading, c.trailing = leading, trailing
+	sigbits := 64 - leading - trailing
+	c.w.writeBit(true)
+	c.w.writeBits(uint64(leading), 5)
+	// 64 significant bits do not fit in 6 bits and are stored as 0.
+	c.w.writeBits(uint64(sigbits&63), 6)
+	c.w.writeBits(xor>>uint(trailing), sigbits)
+}
+
+// bytes returns the encoded chunk.
+func (c *chunk) bytes() []byte {
+	return c.w.bytes()
+}
+
+// chunkIterator decodes a chunk.
+type chunkIterator struct {
+	r     bitReader
+	count int
+	read  int
+
+	t, tDelta int64
+	v         uint64
+	leading   int
+	trailing  int
+	err       error
+}
+
+// newChunkIterator returns an iterator over count points encoded in data.
+func newChunkIterator(data []byte, count int) *chunkIterator {
+	return &chunkIterator{r: bitReader{buf: data}, count: count}
+}
+
+// next advances to the next point, returning false at the end or on error.
+func (it *chunkIterator) next() bool {
+	if it.err != nil || it.read >= it.count {
+		return false
+	}
+	if it.read == 0 {
+		t, err := it.r.readBits(64)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		v, err := it.r.readBits(64)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.t, it.v = int64(t), v
+		it.read++
+		return true
+	}
+
+	dod, err := readDoD(&it.r)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.tDelta += dod
+	it.t += it.tDelta
+	if err := it.readValue(); err != nil {
+		it.err = err
+		return false
+	}
+	it.read++
+	return true
+}
+
+// readDoD reads a delta-of-delta written by writeDoD.
+func readDoD(r *bitReader) (int64, error) {
+	var prefix int
+	for prefix < 4 {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		prefix++
+	}
+	var nbits int
+	switch prefix {
+	case 0:
+		return 0, nil
+	case 1:
+		nbits = 7
+	case 2:
+		nbits = 9
+	case 3:
+		nbits = 12
+	default:
+		nbits = 64
+	}
+	v, err := r.readBits(nbits)
+	if err != nil {
+		return 0, err
+	}
+	if nbits < 64 && v > uint64(1)<<uint(nbits-1) {
+		// Sign-extend values above the bucket's positive range.
+		return int64(v) - int64(1)<<uint(nbits), nil
+	}
+	return int64(v), nil
+}
+
+// readValue reads an XOR-encoded value.
+func (it *chunkIterator) readValue() error {
+	bit, err := it.r.readBit()
+	if err != nil || !bit {
+		return err
+	}
+	bit, err = it.r.readBit()
+	if err != nil {
+		return err
+	}
+	if bit {
+		leading, err := it.r.readBits(5)
+		if err != nil {
+			return err
+		}
+		sigbits, err := it.r.readBits(6)
+		if err != nil {
+			return err
+		}
+		if sigbits == 0 {
+			sigbits = 64
+		}
+		it.leading = int(leading)
+		it.trailing = 64 - int(leading) - int(sigbits)
+	}
+	sig, err := it.r.readBits(64 - it.leading - it.trailing)
+	if err != nil {
+		return err
+	}
+	it.v ^= sig << uint(it.trailing)
+	return nil
+}
+
+// at returns the current point.
+func (it *chunkIterator) at() (int64, float64) {
+	return it.t, math.Float64frombits(it.v)
+}
@@ -0,0 +1,50 @@
+// pkg/storage/gorilla_test.go
+
+package storage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestChunkRoundTrip(t *testing.T) {
+	ts := []int64{1700000000000, 1700000030000, 1700000060000, 1700000090001, 1700000090001, 1700000000000, 1800000000000}
+	vs := []float64{0, 1.5, 1.5, -3.25, math.MaxFloat64, math.SmallestNonzeroFloat64, 42}
+	var c chunk
+	for i := range ts {
+		c.append(ts[i], vs[i])
+	}
+	if c.count != len(ts) {
+		t.Fatalf("count = %d, want %d", c.count, len(ts))
+	}
+
+	it := newChunkIterator(c.bytes(), c.count)
+	n := 0
+	for it.next() {
+		gotT, gotV := it.at()
+		if gotT != ts[n] || math.Float64bits(gotV) != math.Float64bits(vs[n]) {
+			t.Errorf("point %d = (%d, %v), want (%d, %v)", n, gotT, gotV, ts[n], vs[n])
+		}
+		n++
+	}
+	if it.err != nil {
+		t.Fatalf("iterator error: %v", it.err)
+	}
+	if n != len(ts) {
+		t.Fatalf("decoded %d points, want %d", n, len(ts))
+	}
+}
+
+func TestChunkTruncated(t *testing.T) {
+	var c chunk
+	for i := 0; i < 10; i++ {
+		c.append(int64(i)*1000, float64(i)*1.1)
+	}
+	data := c.bytes()
+	it := newChunkIterator(data[:len(data)/2], c.count)
+	for it.next() {
+	}
+	if it.err == nil {
+		t.Fatal("decoding a truncated chunk succeeded")
+	}
+}
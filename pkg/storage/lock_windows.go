@@ -0,0 +1,28 @@
+//go:build windows
+
+// pkg/storage/lock_windows.go
+
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockDir takes an exclusive lock on dir so that two processes do not
+// write the same write-ahead log.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(dir+"/LOCK", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	var ol windows.Overlapped
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &ol); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("storage directory %s is in use by another process", dir)
+	}
+	return f, nil
+}
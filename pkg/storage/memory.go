@@ -0,0 +1,225 @@
+// pkg/storage/memory.go
+
+package storage
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// InMemoryStorage keeps snapshots in a fixed-capacity ring buffer, evicting
+// the oldest snapshots when the count, age or size limits are exceeded.
+type InMemoryStorage struct {
+	mu        sync.RWMutex
+	retention Retention
+	ring      []Snapshot
+	sizes     []int64
+	head      int // Index of the oldest snapshot.
+	count     int
+	bytes     int64
+	evicted   uint64
+	now       func() time.Time
+}
+
+// NewInMemoryStorage returns an InMemoryStorage using DefaultRetention.
+func NewInMemoryStorage() *InMemoryStorage {
+	return NewInMemoryStorageWithRetention(DefaultRetention)
+}
+
+// NewInMemoryStorageWithRetention returns an InMemoryStorage bounded by r.
+// Without a snapshot limit the ring grows as needed, bounded by the other limits.
+func NewInMemoryStorageWithRetention(r Retention) *InMemoryStorage {
+	capacity := r.MaxSnapshots
+	if capacity <= 0 {
+		capacity = 64
+	}
+	return &InMemoryStorage{
+		retention: r,
+		ring:      make([]Snapshot, capacity),
+		sizes:     make([]int64, capacity),
+		now:       time.Now,
+	}
+}
+
+// snapshotSize estimates the memory held by a snapshot from its encoded size.
+func snapshotSize(s Snapshot) int64 {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// Save appends a snapshot, evicting old snapshots to stay within the retention limits.
+func (s *InMemoryStorage) Save(snapshot Snapshot) error {
+	size := snapshotSize(snapshot)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == len(s.ring) {
+		if s.retention.MaxSnapshots > 0 {
+			s.evictOldest()
+		} else {
+			s.grow()
+		}
+	}
+	i := (s.head + s.count) % len(s.ring)
+	s.ring[i] = snapshot
+	s.sizes[i] = size
+	s.count++
+	s.bytes += size
+	s.enforce()
+	return nil
+}
+
+// grow doubles the ring's capacity, preserving order. The caller holds s.mu.
+func (s *InMemoryStorage) grow() {
+	ring := make([]Snapshot, len(s.ring)*2)
+	sizes := make([]int64, len(s.ring)*2)
+	for i := 0; i < s.count; i++ {
+		j := (s.head + i) % len(s.ring)
+		ring[i], sizes[i] = s.ring[j], s.sizes[j]
+	}
+	s.ring, s.sizes, s.head = ring, sizes, 0
+}
+
+// evictOldest removes the oldest snapshot. The caller holds s.mu.
+func (s *InMemoryStorage) evictOldest() {
+	s.bytes -= s.sizes[s.head]
+	s.ring[s.head] = Snapshot{} // Release the metrics for collection.
+	s.sizes[s.head] = 0
+	s.head = (s.head + 1) % len(s.ring)
+	s.count--
+	s.evicted++
+}
+
+// enforce evicts snapshots older than MaxAge and, oldest first, enough
+// snapshots to fit within MaxBytes. The newest snapshot is always kept.
+// The caller holds s.mu.
+func (s *InMemoryStorage) enforce() {
+	if s.retention.MaxAge > 0 {
+		cutoff := s.now().Add(-s.retention.MaxAge)
+		for s.count > 1 && s.ring[s.head].Timestamp.Before(cutoff) {
+			s.evictOldest()
+		}
+	}
+	if s.retention.MaxBytes > 0 {
+		for s.count > 1 && s.bytes > s.retention.MaxBytes {
+			s.evictOldest()
+		}
+	}
+}
+
+// at returns the i-th oldest snapshot. The caller holds s.mu.
+func (s *InMemoryStorage) at(i int) Snapshot {
+	return s.ring[(s.head+i)%len(s.ring)]
+}
+
+// GetAll returns a copy of all retained snapshots, oldest first.
+func (s *InMemoryStorage) GetAll() ([]Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Snapshot, s.count)
+	for i := range out {
+		out[i] = s.at(i)
+	}
+	return out, nil
+}
+
+// Query returns snapshots between two timestamps, exclusive.
+func (s *InMemoryStorage) Query(from, to time.Time) ([]Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []Snapshot
+	for i := 0; i < s.count; i++ {
+		if snap := s.at(i); snap.Timestamp.After(from) && snap.Timestamp.Before(to) {
+			result = append(result, snap)
+		}
+	}
+	return result, nil
+}
+
+// Stats reports the number and estimated size of retained snapshots.
+func (s *InMemoryStorage) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := Stats{
+		Backend:   "memory",
+		Snapshots: s.count,
+		Bytes:     s.bytes,
+		Evicted:   s.evicted,
+	}
+	if s.count > 0 {
+		st.Oldest = s.at(0).Timestamp
+		st.Newest = s.at(s.count - 1).Timestamp
+	}
+	return st
+}
+
+// Cursor returns a cursor over the retained snapshots. It reads the ring
+// buffer one snapshot at a time, so it holds no copy of the range.
+func (s *InMemoryStorage) Cursor(opts CursorOptions) (Cursor, error) {
+	c, err := newCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.Resolution != 0 {
+		return nil, errNoRollups
+	}
+	src := &memorySource{s: s, reverse: c.opts.Reverse}
+	s.mu.RLock()
+	// Positions are counted from the first snapshot ever saved, so they
+	// stay valid as older snapshots are evicted.
+	src.pos = s.evicted
+	if c.opts.Reverse {
+		src.pos += uint64(s.count) - 1
+	}
+	src.empty = s.count == 0
+	s.mu.RUnlock()
+	c.src = src
+	return c, nil
+}
+
+// memorySource yields the snapshots of an InMemoryStorage.
+type memorySource struct {
+	s       *InMemoryStorage
+	reverse bool
+	pos     uint64
+	empty   bool
+}
+
+func (m *memorySource) next() (Snapshot, bool, error) {
+	if m.empty {
+		return Snapshot{}, false, nil
+	}
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+	first := m.s.evicted
+	if m.pos < first {
+		if m.reverse {
+			m.empty = true
+			return Snapshot{}, false, nil
+		}
+		m.pos = first
+	}
+	if m.pos >= first+uint64(m.s.count) {
+		m.empty = true
+		return Snapshot{}, false, nil
+	}
+	snap := m.s.at(int(m.pos - first))
+	if m.reverse {
+		if m.pos == 0 {
+			m.empty = true
+		}
+		m.pos--
+	} else {
+		m.pos++
+	}
+	return snap, true, nil
+}
+
+func (m *memorySource) close() error {
+	m.empty = true
+	return nil
+}
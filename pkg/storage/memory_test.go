@@ -0,0 +1,116 @@
+// pkg/storage/memory_test.go
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// timestamps returns the seconds past the time of testSnapshot(0) of each
+// snapshot.
+func timestamps(snaps []Snapshot) []int {
+	out := make([]int, len(snaps))
+	for i, s := range snaps {
+		out[i] = int(s.Timestamp.Sub(testSnapshot(0).Timestamp) / time.Second)
+	}
+	return out
+}
+
+// readAll returns every snapshot of a cursor.
+func readAll(t *testing.T, s Storage, opts CursorOptions) []Snapshot {
+	t.Helper()
+	c, err := s.Cursor(opts)
+	if err != nil {
+		t.Fatalf("Cursor: %v", err)
+	}
+	defer c.Close()
+	var out []Snapshot
+	for c.Next() {
+		out = append(out, c.Snapshot())
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	return out
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryRingWraparound(t *testing.T) {
+	s := NewInMemoryStorageWithRetention(Retention{MaxSnapshots: 3})
+	for i := 0; i < 7; i++ {
+		s.Save(testSnapshot(i))
+	}
+	all, _ := s.GetAll()
+	if got := timestamps(all); !sameInts(got, []int{4, 5, 6}) {
+		t.Errorf("GetAll = %v, want [4 5 6]", got)
+	}
+	if st := s.Stats(); st.Snapshots != 3 || st.Evicted != 4 || !st.Newest.Equal(testSnapshot(6).Timestamp) {
+		t.Errorf("Stats = %+v", st)
+	}
+	if got := timestamps(readAll(t, s, CursorOptions{Reverse: true})); !sameInts(got, []int{6, 5, 4}) {
+		t.Errorf("reverse cursor = %v, want [6 5 4]", got)
+	}
+	q, _ := s.Query(testSnapshot(4).Timestamp, testSnapshot(7).Timestamp)
+	if got := timestamps(q); !sameInts(got, []int{5, 6}) {
+		t.Errorf("Query = %v, want [5 6]", got)
+	}
+}
+
+func TestMemoryGrows(t *testing.T) {
+	s := NewInMemoryStorageWithRetention(Retention{})
+	var want []int
+	for i := 0; i < 150; i++ {
+		s.Save(testSnapshot(i))
+		want = append(want, i)
+	}
+	all, _ := s.GetAll()
+	if got := timestamps(all); !sameInts(got, want) {
+		t.Errorf("GetAll returned %d snapshots out of order", len(got))
+	}
+}
+
+func TestMemoryMaxAge(t *testing.T) {
+	s := NewInMemoryStorageWithRetention(Retention{MaxAge: 10 * time.Second})
+	now := testSnapshot(0).Timestamp
+	s.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		s.Save(testSnapshot(i))
+	}
+	now = testSnapshot(13).Timestamp
+	s.Save(testSnapshot(13))
+	all, _ := s.GetAll()
+	if got := timestamps(all); !sameInts(got, []int{3, 4, 13}) {
+		t.Errorf("GetAll = %v, want [3 4 13]", got)
+	}
+
+	// The newest snapshot is kept however old it is.
+	now = testSnapshot(100).Timestamp
+	s.Save(testSnapshot(20))
+	all, _ = s.GetAll()
+	if got := timestamps(all); !sameInts(got, []int{20}) {
+		t.Errorf("GetAll = %v, want [20]", got)
+	}
+}
+
+func TestMemoryMaxBytes(t *testing.T) {
+	size := snapshotSize(testSnapshot(0))
+	s := NewInMemoryStorageWithRetention(Retention{MaxBytes: 3 * size})
+	for i := 0; i < 6; i++ {
+		s.Save(testSnapshot(i))
+	}
+	if st := s.Stats(); st.Snapshots != 3 || st.Bytes > 3*size {
+		t.Errorf("Stats = %+v, want 3 snapshots within %d bytes", st, 3*size)
+	}
+}
@@ -0,0 +1,172 @@
+// pkg/storage/path.go
+
+package storage
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric paths address the leaves of snapshot metrics, in storage as well
+// as in queries and exports. Object keys and array indexes are joined with
+// dots, so {"disks": [{"used": 1}]} has the leaf "disks.0.used". In a key,
+// a backslash escapes a literal dot or backslash, and a key that would
+// read as an array index, such as "0", starts with one: "\0".
+
+// JoinKey appends an object key to a metric path.
+func JoinKey(path, key string) string {
+	key = strings.ReplaceAll(key, `\`, `\\`)
+	key = strings.ReplaceAll(key, ".", `\.`)
+	if isIndex(key) || strings.HasPrefix(key, "[") {
+		// Segments of the form "[i]" are array indexes in paths stored
+		// before indexes were written as plain numbers.
+		key = `\` + key
+	}
+	return joinSegment(path, key)
+}
+
+// JoinIndex appends an array index to a metric path.
+func JoinIndex(path string, i int) string {
+	return joinSegment(path, strconv.Itoa(i))
+}
+
+// joinSegment appends an encoded path segment.
+func joinSegment(path, segment string) string {
+	if path == "" {
+		return segment
+	}
+	return path + "." + segment
+}
+
+// isIndex reports whether a path segment is a non-empty run of digits.
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkLeaves calls fn with the metric path and value of each number,
+// boolean and string in v, a decoded JSON value. Object keys are visited
+// in sorted order. Nulls and empty objects or arrays have no leaves.
+func WalkLeaves(v interface{}, fn func(path string, value interface{})) {
+	walkLeaves("", v, fn)
+}
+
+func walkLeaves(path string, v interface{}, fn func(path string, value interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkLeaves(JoinKey(path, k), v[k], fn)
+		}
+	case []interface{}:
+		for i, e := range v {
+			walkLeaves(JoinIndex(path, i), e, fn)
+		}
+	case float64, bool, string:
+		if path != "" {
+			fn(path, v)
+		}
+	}
+}
+
+// JSONMetrics returns metrics as decoded JSON. Collectors return typed
+// structs, which are round-tripped through JSON so that storage, queries
+// and exports see the same shape the API serves.
+func JSONMetrics(metrics map[string]interface{}) (interface{}, error) {
+	if isJSONValue(metrics) {
+		return metrics, nil
+	}
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// isJSONValue reports whether v consists only of the types encoding/json
+// decodes into.
+func isJSONValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, float64, bool, string:
+		return true
+	case map[string]interface{}:
+		for _, e := range v {
+			if !isJSONValue(e) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, e := range v {
+			if !isJSONValue(e) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// pathSegment is one parsed path segment: an object key or an array index.
+type pathSegment struct {
+	key   string
+	index int
+	array bool
+}
+
+// splitPath parses a metric path.
+func splitPath(path string) []pathSegment {
+	var segments []pathSegment
+	var cur strings.Builder
+	escapedStart := false
+	flush := func() {
+		s := cur.String()
+		seg := pathSegment{key: s}
+		if !escapedStart {
+			digits := s
+			if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+				digits = s[1 : len(s)-1]
+			}
+			if isIndex(digits) {
+				if i, err := strconv.Atoi(digits); err == nil {
+					seg = pathSegment{index: i, array: true}
+				}
+			}
+		}
+		segments = append(segments, seg)
+		cur.Reset()
+		escapedStart = false
+	}
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '\\' && i+1 < len(path):
+			if cur.Len() == 0 {
+				escapedStart = true
+			}
+			i++
+			cur.WriteByte(path[i])
+		case c == '.':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return segments
+}
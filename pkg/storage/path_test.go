@@ -0,0 +1,46 @@
+// pkg/storage/path_test.go
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWalkLeaves(t *testing.T) {
+	metrics := map[string]interface{}{
+		"disks": []interface{}{map[string]interface{}{"used": float64(1)}},
+		"cores": map[string]interface{}{"0": true, "[1]": "x"},
+		"a.b":   map[string]interface{}{`c\d`: float64(2)},
+		"none":  nil,
+	}
+	got := map[string]interface{}{}
+	WalkLeaves(metrics, func(path string, v interface{}) { got[path] = v })
+	want := map[string]interface{}{
+		"disks.0.used": float64(1),
+		`cores.\0`:     true,
+		`cores.\[1]`:   "x",
+		`a\.b.c\\d`:    float64(2),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkLeaves = %v, want %v", got, want)
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want []pathSegment
+	}{
+		{"disks.0.used", []pathSegment{{key: "disks"}, {index: 0, array: true}, {key: "used"}}},
+		{`cores.\0`, []pathSegment{{key: "cores"}, {key: "0"}}},
+		{`a\.b.c\\d`, []pathSegment{{key: "a.b"}, {key: `c\d`}}},
+		// Indexes of paths stored before they were plain numbers.
+		{"disks.[12].used", []pathSegment{{key: "disks"}, {index: 12, array: true}, {key: "used"}}},
+		{`cores.\[1]`, []pathSegment{{key: "cores"}, {key: "[1]"}}},
+	} {
+		if got := splitPath(tc.path); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitPath(%q) = %+v, want %+v", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// pkg/storage/rollup.go
+
+package storage
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RollupTier is a downsampled copy of the raw snapshots, aggregated into
+// buckets of Resolution and kept for Retention.
+type RollupTier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// Additional series kinds of rollup tiers. The bucket average is stored as
+// kindNumber, so rolled-up snapshots keep the shape of raw ones; booleans
+// are averaged as 0 and 1 and become the fraction of samples that were
+// true.
+const (
+	kindMin   = 'i'
+	kindMax   = 'a'
+	kindLast  = 't'
+	kindCount = 'c'
+)
+
+// Statistic is one of the statistics rollup tiers keep of each number in
+// a bucket.
+type Statistic string
+
+// Statistics of rollup buckets.
+const (
+	StatAvg   Statistic = "avg"
+	StatMin   Statistic = "min"
+	StatMax   Statistic = "max"
+	StatLast  Statistic = "last"
+	StatCount Statistic = "count" // Number of samples.
+)
+
+// kind returns the series kind holding the statistic. The empty statistic
+// is the average.
+func (s Statistic) kind() (byte, bool) {
+	switch s {
+	case "", StatAvg:
+		return kindNumber, true
+	case StatMin:
+		return kindMin, true
+	case StatMax:
+		return kindMax, true
+	case StatLast:
+		return kindLast, true
+	case StatCount:
+		return kindCount, true
+	}
+	return 0, false
+}
+
+// Downsampler is implemented by backends that keep rollup tiers.
+type Downsampler interface {
+	// Resolutions returns the resolutions of the rollup tiers, finest first.
+	Resolutions() []time.Duration
+	// ResolutionFor returns the resolution Query uses for a window
+	// starting at from, zero for raw snapshots.
+	ResolutionFor(from time.Time) time.Duration
+	// QueryResolution returns snapshots between two timestamps, exclusive,
+	// from the tier with the given resolution. Zero selects raw snapshots.
+	QueryResolution(from, to time.Time, resolution time.Duration) ([]Snapshot, error)
+}
+
+// rollupTier is an open rollup tier and its current, incomplete bucket.
+type rollupTier struct {
+	RollupTier
+	db     *TSDB
+	bucket *rollupBucket
+}
+
+// rollupStats aggregates the samples of one series in a bucket.
+type rollupStats struct {
+	min, max, sum, last float64
+	count               int
+}
+
+// rollupBucket aggregates the snapshots falling in one bucket.
+type rollupBucket struct {
+	start int64                   // Bucket start, in Unix milliseconds.
+	stats map[string]*rollupStats // By path.
+	text  map[string]seriesValue  // Latest strings and labels, by key.
+}
+
+// add aggregates a flattened snapshot into the bucket.
+func (b *rollupBucket) add(values []seriesValue) {
+	for _, v := range values {
+		switch v.key[0] {
+		case kindNumber, kindBool:
+			path := v.key[1:]
+			s := b.stats[path]
+			if s == nil {
+				s = &rollupStats{min: v.num, max: v.num}
+				b.stats[path] = s
+			}
+			s.min = math.Min(s.min, v.num)
+			s.max = math.Max(s.max, v.num)
+			s.sum += v.num
+			s.last = v.num
+			s.count++
+		default:
+			b.text[v.key] = v
+		}
+	}
+}
+
+// values returns the series values that represent the bucket.
+func (b *rollupBucket) values() []seriesValue {
+	out := make([]seriesValue, 0, 5*len(b.stats)+len(b.text))
+	for path, s := range b.stats {
+		out = append(out,
+			seriesValue{key: string(kindNumber) + path, num: s.sum / float64(s.count)},
+			seriesValue{key: string(kindMin) + path, num: s.min},
+			seriesValue{key: string(kindMax) + path, num: s.max},
+			seriesValue{key: string(kindLast) + path, num: s.last},
+			seriesValue{key: string(kindCount) + path, num: float64(s.count)},
+		)
+	}
+	for _, v := range b.text {
+		out = append(out, v)
+	}
+	return out
+}
+
+// add aggregates a raw snapshot taken at ts, in Unix milliseconds. When the
+// snapshot starts a new bucket the previous one is written to the tier.
+// Snapshots older than the current bucket are ignored.
+func (t *rollupTier) add(ts int64, values []seriesValue) error {
+	res := t.Resolution.Milliseconds()
+	start := ts - ts%res
+	if ts < 0 && ts%res != 0 {
+		start -= res
+	}
+	if t.bucket != nil && start < t.bucket.start {
+		return nil
+	}
+	if t.bucket != nil && start > t.bucket.start {
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+	if t.bucket == nil {
+		t.bucket = &rollupBucket{start: start, stats: map[string]*rollupStats{}, text: map[string]seriesValue{}}
+	}
+	t.bucket.add(values)
+	return nil
+}
+
+// flush writes the current bucket to the tier.
+func (t *rollupTier) flush() error {
+	if t.bucket == nil {
+		return nil
+	}
+	b := t.bucket
+	t.bucket = nil
+	return t.db.appendValues(b.start, b.values())
+}
+
+// openRollups opens the rollup tiers of db and aggregates any raw
+// snapshots they have not yet seen, such as those saved since the last
+// complete bucket before a restart.
+func (db *TSDB) openRollups() error {
+	tiers := append([]RollupTier(nil), db.opts.Rollups...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Resolution < tiers[j].Resolution })
+	for i, tier := range tiers {
+		if tier.Resolution < time.Second || tier.Resolution%time.Second != 0 {
+			return fmt.Errorf("invalid rollup resolution %v", tier.Resolution)
+		}
+		if i > 0 && tier.Resolution == tiers[i-1].Resolution {
+			return fmt.Errorf("duplicate rollup resolution %v", tier.Resolution)
+		}
+		blockDuration := 120 * tier.Resolution
+		if blockDuration < db.opts.BlockDuration {
+			blockDuration = db.opts.BlockDuration
+		}
+		tdb, err := OpenTSDB(TSDBOptions{
+			Dir:           filepath.Join(db.opts.Dir, "rollups", fmt.Sprintf("%ds", int64(tier.Resolution/time.Second))),
+			Retention:     Retention{MaxAge: tier.Retention},
+			Fsync:         db.opts.Fsync,
+			FsyncInterval: db.opts.FsyncInterval,
+			BlockDuration: blockDuration,
+			Keyring:       db.opts.Keyring,
+		})
+		if err != nil {
+			db.closeRollups()
+			return fmt.Errorf("opening %v rollups: %v", tier.Resolution, err)
+		}
+		t := &rollupTier{RollupTier: tier, db: tdb}
+		db.tiers = append(db.tiers, t)
+
+		// Re-aggregate the raw snapshots after the tier's last bucket.
+		from := int64(minMillis)
+		if st := tdb.Stats(); st.Snapshots > 0 {
+			from = st.Newest.UnixMilli() + tier.Resolution.Milliseconds()
+		}
+		err = db.scan(from, maxMillis, func(r snapshotReader) error {
+			snaps, err := readSnapshots(r, from, maxMillis, func(ts int64) bool { return ts >= from }, kindNumber)
+			if err != nil {
+				return err
+			}
+			for _, snap := range snaps {
+				values, err := flattenSnapshot(snap)
+				if err != nil {
+					return err
+				}
+				if err := t.add(snap.Timestamp.UnixMilli(), values); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			db.closeRollups()
+			return fmt.Errorf("backfilling %v rollups: %v", tier.Resolution, err)
+		}
+	}
+	return nil
+}
+
+// closeRollups closes the rollup tiers. Incomplete buckets are rebuilt from
+// the raw snapshots when the tiers are reopened.
+func (db *TSDB) closeRollups() error {
+	var err error
+	for _, t := range db.tiers {
+		if cerr := t.db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	db.tiers = nil
+	return err
+}
+
+// Resolutions returns the resolutions of the rollup tiers, finest first.
+func (db *TSDB) Resolutions() []time.Duration {
+	out := make([]time.Duration, len(db.tiers))
+	for i, t := range db.tiers {
+		out[i] = t.Resolution
+	}
+	return out
+}
+
+// ResolutionFor picks the tier for a query starting at from: raw snapshots
+// while from is within the raw retention, then the finest tier whose
+// retention covers it, falling back to the coarsest tier.
+func (db *TSDB) ResolutionFor(from time.Time) time.Duration {
+	age := db.now().Sub(from)
+	if len(db.tiers) == 0 || db.opts.Retention.MaxAge == 0 || age <= db.opts.Retention.MaxAge {
+		return 0
+	}
+	for _, t := range db.tiers {
+		if t.Retention == 0 || age <= t.Retention {
+			return t.Resolution
+		}
+	}
+	return db.tiers[len(db.tiers)-1].Resolution
+}
+
+// tier returns the rollup tier with the given resolution.
+func (db *TSDB) tier(resolution time.Duration) (*rollupTier, error) {
+	for _, t := range db.tiers {
+		if t.Resolution == resolution {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no rollup tier with resolution %v", resolution)
+}
+
+// QueryResolution returns snapshots between two timestamps, exclusive,
+// from the tier with the given resolution. Zero selects raw snapshots.
+// Rolled-up snapshots are timestamped at the start of their bucket and
+// hold each number's average; the bucket still being filled is not
+// included.
+func (db *TSDB) QueryResolution(from, to time.Time, resolution time.Duration) ([]Snapshot, error) {
+	if resolution == 0 {
+		return db.queryRaw(from, to)
+	}
+	t, err := db.tier(resolution)
+	if err != nil {
+		return nil, err
+	}
+	return t.db.queryRaw(from, to)
+}
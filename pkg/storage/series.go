@@ -0,0 +1,130 @@
+// pkg/storage/series.go
+
+package storage
+
+import "encoding/json"
+
+// Series kinds. A series key is its kind byte followed by its path.
+const (
+	kindNumber = 'n'
+	kindBool   = 'b'
+	kindString = 's' // Values are indexes into the block's string dictionary.
+	kindLabels = 'l' // The snapshot's labels, JSON-encoded and dictionary-coded.
+)
+
+// seriesValue is one flattened leaf of a snapshot.
+type seriesValue struct {
+	key  string
+	num  float64
+	text string
+}
+
+// flattenSnapshot turns a snapshot into one value per leaf, keyed by its
+// metric path (see JoinKey). Nulls and empty objects or arrays have no
+// leaves and are not stored.
+func flattenSnapshot(snap Snapshot) ([]seriesValue, error) {
+	metrics, err := JSONMetrics(snap.Metrics)
+	if err != nil {
+		return nil, err
+	}
+	var out []seriesValue
+	WalkLeaves(metrics, func(path string, v interface{}) {
+		switch v := v.(type) {
+		case float64:
+			out = append(out, seriesValue{key: string(kindNumber) + path, num: v})
+		case bool:
+			n := 0.0
+			if v {
+				n = 1
+			}
+			out = append(out, seriesValue{key: string(kindBool) + path, num: n})
+		case string:
+			out = append(out, seriesValue{key: string(kindString) + path, text: v})
+		}
+	})
+	if len(snap.Labels) > 0 {
+		labels, err := json.Marshal(snap.Labels)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, seriesValue{key: string(kindLabels), text: string(labels)})
+	}
+	return out, nil
+}
+
+// arrayNode collects array elements by index while a snapshot is rebuilt.
+type arrayNode map[int]interface{}
+
+// setPath stores v at the path in root, creating containers as needed.
+func setPath(root map[string]interface{}, path string, v interface{}) {
+	segments := splitPath(path)
+	var parent interface{} = root
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		var child interface{}
+		if !last {
+			if segments[i+1].array {
+				child = arrayNode{}
+			} else {
+				child = map[string]interface{}{}
+			}
+		}
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if seg.array {
+				return
+			}
+			if last {
+				p[seg.key] = v
+				return
+			}
+			if existing, ok := p[seg.key]; ok {
+				child = existing
+			} else {
+				p[seg.key] = child
+			}
+		case arrayNode:
+			if !seg.array {
+				return
+			}
+			if last {
+				p[seg.index] = v
+				return
+			}
+			if existing, ok := p[seg.index]; ok {
+				child = existing
+			} else {
+				p[seg.index] = child
+			}
+		default:
+			// A leaf already occupies this path.
+			return
+		}
+		parent = child
+	}
+}
+
+// finishArrays replaces the arrayNodes in a rebuilt value with slices.
+func finishArrays(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = finishArrays(e)
+		}
+		return v
+	case arrayNode:
+		n := 0
+		for i := range v {
+			if i+1 > n {
+				n = i + 1
+			}
+		}
+		out := make([]interface{}, n)
+		for i, e := range v {
+			out[i] = finishArrays(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -1,3 +1,5 @@
+// pkg/storage/storage.go
+
 package storage
 
 import "time"
@@ -5,46 +7,50 @@ import "time"
 // Snapshot represents a single set of collected metrics.
 type Snapshot struct {
 	Timestamp time.Time              `json:"timestamp"`
+	Labels    map[string]string      `json:"labels,omitempty"`
 	Metrics   map[string]interface{} `json:"metrics"`
 }
 
-// Storage defines the interface for storing snapshots.
+// Storage defines the interface for storing snapshots. Implementations are
+// safe for concurrent use.
 type Storage interface {
 	Save(snapshot Snapshot) error
 	GetAll() ([]Snapshot, error)
 	Query(from, to time.Time) ([]Snapshot, error)
+	Cursor(opts CursorOptions) (Cursor, error)
 }
 
-// InMemoryStorage is a simple storage backend that keeps snapshots in memory.
-type InMemoryStorage struct {
-	snapshots []Snapshot
-}
-
-// NewInMemoryStorage returns a new InMemoryStorage instance.
-func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
-		snapshots: []Snapshot{},
-	}
+// Stats describes the contents of a storage backend.
+type Stats struct {
+	Backend   string    `json:"backend"`
+	Snapshots int       `json:"snapshots"`
+	Bytes     int64     `json:"bytes"`
+	Oldest    time.Time `json:"oldest"`
+	Newest    time.Time `json:"newest"`
+	Evicted   uint64    `json:"evicted"`
+	// Resolution is set on the statistics of a rollup tier.
+	Resolution string `json:"resolution,omitempty"`
+	// Rollups describes the backend's rollup tiers, finest first.
+	Rollups []Stats `json:"rollups,omitempty"`
 }
 
-// Save appends a new snapshot.
-func (s *InMemoryStorage) Save(snapshot Snapshot) error {
-	s.snapshots = append(s.snapshots, snapshot)
-	return nil
+// StatsReporter is implemented by backends that can describe their contents.
+type StatsReporter interface {
+	Stats() Stats
 }
 
-// GetAll returns all snapshots.
-func (s *InMemoryStorage) GetAll() ([]Snapshot, error) {
-	return s.snapshots, nil
+// Retention bounds how much history a backend keeps. Zero values disable
+// the corresponding limit.
+type Retention struct {
+	MaxSnapshots int
+	MaxAge       time.Duration
+	MaxBytes     int64
 }
 
-// Query returns snapshots between two timestamps.
-func (s *InMemoryStorage) Query(from, to time.Time) ([]Snapshot, error) {
-	var result []Snapshot
-	for _, snap := range s.snapshots {
-		if snap.Timestamp.After(from) && snap.Timestamp.Before(to) {
-			result = append(result, snap)
-		}
-	}
-	return result, nil
+// DefaultRetention keeps a day of snapshots at the agent's 30 second
+// collection interval, within 64 MiB.
+var DefaultRetention = Retention{
+	MaxSnapshots: 2880,
+	MaxAge:       24 * time.Hour,
+	MaxBytes:     64 << 20,
 }
@@ -0,0 +1,760 @@
+// pkg/storage/tsdb.go
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TSDBOptions configures a TSDB.
+type TSDBOptions struct {
+	Dir           string
+	Retention     Retention
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration // Defaults to one second.
+	// BlockDuration is the span of time covered by each block file.
+	// Defaults to two hours.
+	BlockDuration time.Duration
+	// Rollups are the downsampled tiers kept alongside the raw snapshots.
+	Rollups []RollupTier
+	// Keyring encrypts new blocks and log records, including those of the
+	// rollup tiers. Without one they are stored unencrypted.
+	Keyring *Keyring
+}
+
+// TSDB is a columnar time-series store. Each snapshot is flattened into
+// one series per leaf value (see flattenSnapshot), and every series is
+// compressed with delta-of-delta timestamps and XOR-encoded values, so a
+// slowly changing metric costs a few bits per sample. Strings and labels
+// are dictionary-coded per block.
+//
+// New snapshots go to a write-ahead log and an in-memory head block. Once
+// the head spans BlockDuration it is written to an immutable block file
+// whose header records its time range and whose index records each
+// series' time range, so queries read only the blocks and chunks that
+// overlap the requested window.
+//
+// Each rollup tier is a TSDB of its own, fed as raw snapshots are saved.
+// Query reads raw snapshots while the window starts within the raw
+// retention and otherwise the finest tier that still covers it.
+//
+// Timestamps are stored with millisecond precision. Numbers are stored as
+// float64, as they are in the JSON the agent serves.
+type TSDB struct {
+	mu       sync.RWMutex
+	opts     TSDBOptions
+	blockDir string
+	blocks   []*blockMeta // Oldest first.
+	nextSeq  int
+
+	lock      *os.File
+	wal       *os.File
+	walSize   int64
+	head      *headBlock
+	tiers     []*rollupTier // Finest first.
+	dirty     bool          // Unsynced writes in the write-ahead log.
+	evicted   uint64
+	recovered int // Snapshots replayed from the log when opening.
+
+	now  func() time.Time
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// OpenTSDB opens or creates a TSDB in opts.Dir, recovering any snapshots
+// left in the write-ahead log.
+func OpenTSDB(opts TSDBOptions) (*TSDB, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("storage directory is required")
+	}
+	switch opts.Fsync {
+	case "":
+		opts.Fsync = FsyncInterval
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("invalid fsync policy %q", opts.Fsync)
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = time.Second
+	}
+	if opts.BlockDuration <= 0 {
+		opts.BlockDuration = 2 * time.Hour
+	}
+
+	db := &TSDB{
+		opts:     opts,
+		blockDir: filepath.Join(opts.Dir, "blocks"),
+		head:     newHeadBlock(),
+		now:      time.Now,
+		done:     make(chan struct{}),
+	}
+	if err := os.MkdirAll(db.blockDir, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	db.lock = lock
+	if err := db.loadBlocks(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	if err := db.openWAL(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	db.enforce()
+	if err := db.openRollups(); err != nil {
+		db.wal.Close()
+		lock.Close()
+		return nil, err
+	}
+
+	if opts.Fsync == FsyncInterval {
+		db.wg.Add(1)
+		go db.syncLoop()
+	}
+	return db, nil
+}
+
+// loadBlocks reads the headers of the block files and removes leftovers of
+// interrupted writes.
+func (db *TSDB) loadBlocks() error {
+	entries, err := os.ReadDir(db.blockDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(db.blockDir, name))
+			continue
+		}
+		seqStr, ok := strings.CutSuffix(name, ".blk")
+		if !ok {
+			continue
+		}
+		seq, err := strconv.Atoi(seqStr)
+		if err != nil {
+			continue
+		}
+		meta, err := readBlockMeta(filepath.Join(db.blockDir, name), seq)
+		if err != nil {
+			return fmt.Errorf("reading block %s: %v", name, err)
+		}
+		if meta.sealed {
+			if db.opts.Keyring == nil {
+				return fmt.Errorf("reading block %s: %w", name, ErrNoKey)
+			}
+			if _, ok := db.opts.Keyring.key(meta.keyID); !ok {
+				return fmt.Errorf("reading block %s: %w", name, ErrUnknownKey)
+			}
+		}
+		db.blocks = append(db.blocks, meta)
+	}
+	sort.Slice(db.blocks, func(i, j int) bool { return db.blocks[i].seq < db.blocks[j].seq })
+	if n := len(db.blocks); n > 0 {
+		db.nextSeq = db.blocks[n-1].seq + 1
+	}
+	return nil
+}
+
+// openWAL opens the write-ahead log, replays intact records into the head
+// block and truncates anything after the last one. Records already written
+// to a block are skipped; see openLog.
+func (db *TSDB) openWAL() error {
+	f, size, seq, err := openLog(filepath.Join(db.opts.Dir, "wal.log"), db.opts.Keyring, db.nextSeq, func(payload []byte) error {
+		ts, values, err := decodeWALEntry(payload)
+		if err != nil {
+			return err
+		}
+		db.head.add(ts, values)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	db.wal = f
+	db.walSize = size
+	db.nextSeq = seq
+	db.recovered = db.head.count
+	return nil
+}
+
+// Recovered returns how many snapshots were replayed from the write-ahead
+// log when the database was opened.
+func (db *TSDB) Recovered() int {
+	return db.recovered
+}
+
+// syncLoop flushes the write-ahead log every FsyncInterval.
+func (db *TSDB) syncLoop() {
+	defer db.wg.Done()
+	ticker := time.NewTicker(db.opts.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+			db.mu.Lock()
+			if db.dirty && db.wal != nil {
+				db.wal.Sync()
+				db.dirty = false
+			}
+			db.mu.Unlock()
+		}
+	}
+}
+
+// Save adds a snapshot to the head block and the rollup tiers.
+func (db *TSDB) Save(snapshot Snapshot) error {
+	values, err := flattenSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	ts := snapshot.Timestamp.UnixMilli()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.append(ts, values); err != nil {
+		return err
+	}
+	for _, t := range db.tiers {
+		if err := t.add(ts, values); err != nil {
+			return fmt.Errorf("writing %v rollup: %v", t.Resolution, err)
+		}
+	}
+	db.enforce()
+	return nil
+}
+
+// appendValues is Save for values that are already flattened. It is how
+// rollup tiers are written.
+func (db *TSDB) appendValues(ts int64, values []seriesValue) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.append(ts, values); err != nil {
+		return err
+	}
+	db.enforce()
+	return nil
+}
+
+// append logs values taken at ts, in Unix milliseconds, and adds them to
+// the head block, first writing the head out as a block if ts falls beyond
+// its BlockDuration. The caller holds db.mu.
+func (db *TSDB) append(ts int64, values []seriesValue) error {
+	if db.wal == nil {
+		return fmt.Errorf("storage is closed")
+	}
+	if db.head.count > 0 && ts-db.head.minT >= db.opts.BlockDuration.Milliseconds() {
+		if err := db.cut(); err != nil {
+			return fmt.Errorf("writing block: %v", err)
+		}
+	}
+	record, err := db.opts.Keyring.encodeRecord(encodeWALEntry(ts, values))
+	if err != nil {
+		return err
+	}
+	if _, err := db.wal.Write(record); err != nil {
+		// Drop a partly written record so the next one is not written
+		// after it, where replay could not reach it.
+		rewindLog(db.wal, db.walSize)
+		return err
+	}
+	if db.opts.Fsync == FsyncAlways {
+		if err := db.wal.Sync(); err != nil {
+			rewindLog(db.wal, db.walSize)
+			return err
+		}
+	} else {
+		db.dirty = true
+	}
+	db.walSize += int64(len(record))
+	db.head.add(ts, values)
+	return nil
+}
+
+// encodeWALEntry encodes flattened values for the write-ahead log as
+// [ts varint][count uvarint] followed by [key][value bits][text] for each
+// value, with strings length-prefixed.
+func encodeWALEntry(ts int64, values []seriesValue) []byte {
+	b := binary.AppendVarint(nil, ts)
+	b = binary.AppendUvarint(b, uint64(len(values)))
+	for _, v := range values {
+		b = binary.AppendUvarint(b, uint64(len(v.key)))
+		b = append(b, v.key...)
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(v.num))
+		b = binary.AppendUvarint(b, uint64(len(v.text)))
+		b = append(b, v.text...)
+	}
+	return b
+}
+
+// decodeWALEntry decodes a write-ahead log entry.
+func decodeWALEntry(data []byte) (int64, []seriesValue, error) {
+	d := indexDecoder{data: data}
+	ts := d.varint()
+	n := d.uvarint()
+	if n > uint64(len(data)) {
+		return 0, nil, errCorruptRecord
+	}
+	values := make([]seriesValue, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		v := seriesValue{key: d.string()}
+		if d.err == nil && len(d.data) < 8 {
+			d.err = errCorruptRecord
+		}
+		if d.err != nil {
+			break
+		}
+		v.num = math.Float64frombits(binary.BigEndian.Uint64(d.data))
+		d.data = d.data[8:]
+		v.text = d.string()
+		if v.key == "" {
+			d.err = errCorruptRecord
+		}
+		values = append(values, v)
+	}
+	if d.err != nil {
+		return 0, nil, errCorruptRecord
+	}
+	return ts, values, nil
+}
+
+// cut writes the head block to a new block file and resets the
+// write-ahead log. The block is written to a temporary file and renamed
+// into place before the log is reset. A crash in between leaves both, and
+// the log's header then names a block that exists, so its entries are not
+// replayed into the head again. The caller holds db.mu.
+func (db *TSDB) cut() error {
+	if db.head.count == 0 {
+		return nil
+	}
+	path := filepath.Join(db.blockDir, fmt.Sprintf("%010d.blk", db.nextSeq))
+	data, err := db.head.encode(db.opts.Keyring)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	syncDir(db.blockDir)
+	meta, err := readBlockMeta(path, db.nextSeq)
+	if err != nil {
+		return err
+	}
+
+	// The snapshots are durable in the block; start a fresh log.
+	db.blocks = append(db.blocks, meta)
+	db.nextSeq++
+	db.head = newHeadBlock()
+	size, err := resetLog(db.wal, db.nextSeq)
+	if err != nil {
+		return err
+	}
+	db.walSize = size
+	db.dirty = false
+	return nil
+}
+
+// writeFileAtomic writes data to path through a synced temporary file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// enforce deletes the oldest blocks that fall outside the retention
+// limits. Retention works on whole blocks. The caller holds db.mu.
+func (db *TSDB) enforce() {
+	r := db.opts.Retention
+	count, size := db.head.count, db.walSize
+	for _, b := range db.blocks {
+		count += b.count
+		size += b.size
+	}
+	cutoff := int64(0)
+	if r.MaxAge > 0 {
+		cutoff = db.now().Add(-r.MaxAge).UnixMilli()
+	}
+	for len(db.blocks) > 0 {
+		oldest := db.blocks[0]
+		expired := r.MaxAge > 0 && oldest.maxT < cutoff
+		tooMany := r.MaxSnapshots > 0 && count > r.MaxSnapshots
+		tooBig := r.MaxBytes > 0 && size > r.MaxBytes
+		if !expired && !tooMany && !tooBig {
+			return
+		}
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		count -= oldest.count
+		size -= oldest.size
+		db.evicted += uint64(oldest.count)
+		db.blocks = db.blocks[1:]
+	}
+}
+
+// scan calls fn with a reader for each block, then the head block, whose
+// time range overlaps [lo, hi] in Unix milliseconds. The caller holds
+// db.mu for reading.
+func (db *TSDB) scan(lo, hi int64, fn func(r snapshotReader) error) error {
+	for _, meta := range db.blocks {
+		if meta.maxT < lo || meta.minT > hi {
+			continue
+		}
+		b, err := openBlockFile(meta, db.opts.Keyring)
+		if err != nil {
+			return err
+		}
+		err = fn(b)
+		b.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", meta.path, err)
+		}
+	}
+	if db.head.count == 0 || db.head.maxT < lo || db.head.minT > hi {
+		return nil
+	}
+	head, err := newHeadReader(db.head)
+	if err != nil {
+		return err
+	}
+	return fn(head)
+}
+
+// GetAll returns every retained snapshot, oldest first.
+func (db *TSDB) GetAll() ([]Snapshot, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var out []Snapshot
+	err := db.scan(minMillis, maxMillis, func(r snapshotReader) error {
+		snaps, err := readSnapshots(r, minMillis, maxMillis, func(int64) bool { return true }, kindNumber)
+		out = append(out, snaps...)
+		return err
+	})
+	return out, err
+}
+
+// Query returns snapshots between two timestamps, exclusive, from the tier
+// chosen for the window's start.
+func (db *TSDB) Query(from, to time.Time) ([]Snapshot, error) {
+	return db.QueryResolution(from, to, db.ResolutionFor(from))
+}
+
+// queryRaw returns the snapshots of db between two timestamps, exclusive.
+func (db *TSDB) queryRaw(from, to time.Time) ([]Snapshot, error) {
+	lo, hi := from.UnixMilli(), to.UnixMilli()
+	inRange := func(ts int64) bool {
+		t := time.UnixMilli(ts)
+		return t.After(from) && t.Before(to)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var out []Snapshot
+	err := db.scan(lo, hi, func(r snapshotReader) error {
+		snaps, err := readSnapshots(r, lo, hi, inRange, kindNumber)
+		out = append(out, snaps...)
+		return err
+	})
+	return out, err
+}
+
+// Bounds for scans that cover all of history.
+const (
+	minMillis = -1 << 63
+	maxMillis = 1<<63 - 1
+)
+
+// Stats reports the number and on-disk size of retained snapshots.
+func (db *TSDB) Stats() Stats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	st := Stats{Backend: "tsdb", Evicted: db.evicted, Snapshots: db.head.count, Bytes: db.walSize}
+	first := true
+	var oldest, newest int64
+	note := func(minT, maxT int64) {
+		if first || minT < oldest {
+			oldest = minT
+		}
+		if first || maxT > newest {
+			newest = maxT
+		}
+		first = false
+	}
+	for _, b := range db.blocks {
+		st.Snapshots += b.count
+		st.Bytes += b.size
+		note(b.minT, b.maxT)
+	}
+	if db.head.count > 0 {
+		note(db.head.minT, db.head.maxT)
+	}
+	if !first {
+		st.Oldest = time.UnixMilli(oldest)
+		st.Newest = time.UnixMilli(newest)
+	}
+	for _, t := range db.tiers {
+		tst := t.db.Stats()
+		tst.Resolution = t.Resolution.String()
+		st.Rollups = append(st.Rollups, tst)
+	}
+	return st
+}
+
+// Close syncs and closes the write-ahead log and the rollup tiers.
+// Snapshots in the head block are replayed from the log the next time the
+// database is opened.
+func (db *TSDB) Close() error {
+	db.mu.Lock()
+	if db.wal == nil {
+		db.mu.Unlock()
+		return nil
+	}
+	close(db.done)
+	err := db.closeRollups()
+	if serr := db.wal.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := db.wal.Close(); err == nil {
+		err = cerr
+	}
+	db.wal = nil
+	db.lock.Close()
+	db.mu.Unlock()
+	db.wg.Wait()
+	return err
+}
+
+// Reencrypt writes the head block out, so that the write-ahead log holds
+// no records, and rewrites every block that is unencrypted or encrypted
+// with a previous key, then does the same for each rollup tier. Cursors
+// opened before may fail to read rewritten blocks.
+func (db *TSDB) Reencrypt() (int, error) {
+	k := db.opts.Keyring
+	if k == nil {
+		return 0, ErrNoKey
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.wal == nil {
+		return 0, fmt.Errorf("storage is closed")
+	}
+	if err := db.cut(); err != nil {
+		return 0, fmt.Errorf("writing block: %v", err)
+	}
+	n := 0
+	for i, meta := range db.blocks {
+		if meta.sealed && meta.keyID == k.keys[0].id {
+			continue
+		}
+		rewritten, err := db.reencryptBlock(meta)
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", meta.path, err)
+		}
+		db.blocks[i] = rewritten
+		n++
+	}
+	if n > 0 {
+		syncDir(db.blockDir)
+	}
+	for _, t := range db.tiers {
+		m, err := t.db.Reencrypt()
+		n += m
+		if err != nil {
+			return n, fmt.Errorf("%v rollups: %w", t.Resolution, err)
+		}
+	}
+	return n, nil
+}
+
+// reencryptBlock rewrites a block with the active key and returns its new
+// summary. The caller holds db.mu.
+func (db *TSDB) reencryptBlock(meta *blockMeta) (*blockMeta, error) {
+	b, err := openBlockFile(meta, db.opts.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	times, dict, series, err := readBlockChunks(b)
+	b.Close()
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeBlock(meta.minT, meta.maxT, meta.count, times, dict, series, db.opts.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(meta.path, data); err != nil {
+		return nil, err
+	}
+	return readBlockMeta(meta.path, meta.seq)
+}
+
+// readBlockChunks reads every chunk of a block file, decrypted.
+func readBlockChunks(b *blockFile) (blockChunk, []string, map[string]blockChunk, error) {
+	idx, err := b.index()
+	if err != nil {
+		return blockChunk{}, nil, nil, err
+	}
+	read := func(ref chunkRef) (blockChunk, error) {
+		data, err := b.chunk(ref)
+		return blockChunk{ref: ref, data: data}, err
+	}
+	times, err := read(idx.times)
+	if err != nil {
+		return blockChunk{}, nil, nil, err
+	}
+	series := make(map[string]blockChunk, len(idx.series))
+	for key, ref := range idx.series {
+		if series[key], err = read(ref); err != nil {
+			return blockChunk{}, nil, nil, fmt.Errorf("series %q: %v", key[1:], err)
+		}
+	}
+	return times, idx.dict, series, nil
+}
+
+// Cursor returns a cursor over the raw snapshots, or those of the rollup
+// tier selected by opts with numbers holding the selected statistic.
+// Blocks are decoded one at a time, and only those overlapping the
+// cursor's range.
+func (db *TSDB) Cursor(opts CursorOptions) (Cursor, error) {
+	c, err := newCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+	src := db
+	if c.opts.Resolution != 0 {
+		t, err := db.tier(c.opts.Resolution)
+		if err != nil {
+			return nil, err
+		}
+		src = t.db
+	}
+	s, err := src.newSource(c)
+	if err != nil {
+		return nil, err
+	}
+	c.src = s
+	return c, nil
+}
+
+// newSource captures the blocks of db that overlap the window of c.
+func (db *TSDB) newSource(c *cursor) (*tsdbSource, error) {
+	const ms = int64(time.Millisecond)
+	s := &tsdbSource{c: c, k: db.opts.Keyring, lo: floorDiv(c.lo, ms), hi: floorDiv(c.hi, ms), numbers: kindNumber}
+	if c.opts.Resolution != 0 {
+		s.numbers, _ = c.opts.Statistic.kind()
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, meta := range db.blocks {
+		if meta.maxT >= s.lo && meta.minT <= s.hi {
+			s.blocks = append(s.blocks, meta)
+		}
+	}
+	if db.head.count > 0 && db.head.maxT >= s.lo && db.head.minT <= s.hi {
+		head, err := newHeadReader(db.head)
+		if err != nil {
+			return nil, err
+		}
+		s.head = head
+	}
+	if c.opts.Reverse {
+		for i, j := 0, len(s.blocks)-1; i < j; i, j = i+1, j-1 {
+			s.blocks[i], s.blocks[j] = s.blocks[j], s.blocks[i]
+		}
+	}
+	return s, nil
+}
+
+// floorDiv divides a by b, rounding toward negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// tsdbSource yields the snapshots of a TSDB block by block: the block
+// files, then the head block, or the reverse.
+type tsdbSource struct {
+	c       *cursor
+	k       *Keyring
+	lo, hi  int64        // Window in Unix milliseconds.
+	numbers byte         // Series kind read as numbers.
+	blocks  []*blockMeta // Remaining blocks, in cursor order.
+	head    *headReader  // Nil once read.
+	buf     sliceSource  // Snapshots of the current block, in cursor order.
+}
+
+func (s *tsdbSource) next() (Snapshot, bool, error) {
+	for len(s.buf.snaps) == 0 {
+		var r snapshotReader
+		switch {
+		case s.head != nil && (s.c.opts.Reverse || len(s.blocks) == 0):
+			r, s.head = s.head, nil
+		case len(s.blocks) > 0:
+			meta := s.blocks[0]
+			s.blocks = s.blocks[1:]
+			b, err := openBlockFile(meta, s.k)
+			if os.IsNotExist(err) {
+				// Removed by retention since the cursor was opened.
+				continue
+			}
+			if err != nil {
+				return Snapshot{}, false, err
+			}
+			r = b
+		default:
+			return Snapshot{}, false, nil
+		}
+		keep := func(ts int64) bool { return ts >= s.lo && ts <= s.hi }
+		snaps, err := readSnapshots(r, s.lo, s.hi, keep, s.numbers)
+		if b, ok := r.(*blockFile); ok {
+			b.Close()
+		}
+		if err != nil {
+			return Snapshot{}, false, err
+		}
+		if s.c.opts.Reverse {
+			reverseSnapshots(snaps)
+		}
+		s.buf.snaps = snaps
+	}
+	return s.buf.next()
+}
+
+func (s *tsdbSource) close() error {
+	s.blocks, s.head = nil, nil
+	return s.buf.close()
+}
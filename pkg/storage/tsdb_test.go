@@ -0,0 +1,154 @@
+// pkg/storage/tsdb_test.go
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestTSDB(t *testing.T, dir string) *TSDB {
+	t.Helper()
+	db, err := OpenTSDB(TSDBOptions{Dir: dir, Fsync: FsyncAlways, BlockDuration: time.Minute})
+	if err != nil {
+		t.Fatalf("OpenTSDB: %v", err)
+	}
+	return db
+}
+
+func TestTSDBRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestTSDB(t, dir)
+	var want []Snapshot
+	// 30-second snapshots over a one-minute block duration span several
+	// blocks and leave some in the head.
+	for i := 0; i < 7; i++ {
+		snap := richSnapshot(i)
+		if err := db.Save(snap); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		want = append(want, snap)
+	}
+	if len(db.blocks) == 0 {
+		t.Fatal("no blocks were written")
+	}
+	got, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	sameSnapshots(t, got, want)
+
+	got, err = db.Query(want[2].Timestamp.Add(-time.Millisecond), want[5].Timestamp.Add(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	sameSnapshots(t, got, want[2:6])
+
+	db.Close()
+	db = openTestTSDB(t, dir)
+	defer db.Close()
+	got, err = db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll after restart: %v", err)
+	}
+	sameSnapshots(t, got, want)
+}
+
+// TestTSDBCrashAfterCut recovers from a crash after the head was written
+// to a block but before the log was reset, which leaves the written entries
+// in the log.
+func TestTSDBCrashAfterCut(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestTSDB(t, dir)
+	want := []Snapshot{richSnapshot(0), richSnapshot(1)}
+	for _, snap := range want {
+		if err := db.Save(snap); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	walPath := filepath.Join(dir, "wal.log")
+	log, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.mu.Lock()
+	err = db.cut()
+	db.mu.Unlock()
+	if err != nil {
+		t.Fatalf("cut: %v", err)
+	}
+	db.Close()
+	if err := os.WriteFile(walPath, log, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestTSDB(t, dir)
+	if db.Recovered() != 0 {
+		t.Errorf("Recovered() = %d, want 0", db.Recovered())
+	}
+	got, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	sameSnapshots(t, got, want)
+
+	// Later snapshots go to the next block and survive another restart.
+	want = append(want, richSnapshot(2))
+	if err := db.Save(want[2]); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	db.Close()
+	db = openTestTSDB(t, dir)
+	defer db.Close()
+	got, err = db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll after restart: %v", err)
+	}
+	sameSnapshots(t, got, want)
+}
+
+// TestTSDBOutOfOrderReplay keeps log entries older than the newest block,
+// as written by imports, when replaying the log.
+func TestTSDBOutOfOrderReplay(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestTSDB(t, dir)
+	for _, i := range []int{4, 5, 6, 1} {
+		if err := db.Save(richSnapshot(i)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	db.Close()
+	db = openTestTSDB(t, dir)
+	defer db.Close()
+	got, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("GetAll returned %d snapshots, want 4", len(got))
+	}
+}
+
+func TestWALEntryRoundTrip(t *testing.T) {
+	values, err := flattenSnapshot(richSnapshot(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, got, err := decodeWALEntry(encodeWALEntry(1700000000123, values))
+	if err != nil {
+		t.Fatalf("decodeWALEntry: %v", err)
+	}
+	if ts != 1700000000123 || len(got) != len(values) {
+		t.Fatalf("decoded ts %d and %d values", ts, len(got))
+	}
+	for i := range values {
+		if got[i] != values[i] {
+			t.Errorf("value %d = %+v, want %+v", i, got[i], values[i])
+		}
+	}
+	if _, _, err := decodeWALEntry(encodeWALEntry(1, values)[:10]); err == nil {
+		t.Error("decoding a truncated entry succeeded")
+	}
+}
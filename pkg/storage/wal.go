@@ -0,0 +1,127 @@
+// pkg/storage/wal.go
+
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// walMarker starts the payload of the header record of a write-ahead log.
+// Snapshot and series records never start with it.
+const walMarker = "\x00wal"
+
+// A write-ahead log starts with a header record holding the sequence number
+// of the segment or block its records are written to next. Writing them
+// out renames that file into place before the log is reset, so after a
+// crash between the two the file already exists and the log's records are
+// discarded on replay rather than restored a second time. Logs written
+// before headers were introduced are replayed in full.
+
+// encodeWALHeader returns the header record of a log whose records will be
+// written to the file with sequence number seq.
+func encodeWALHeader(seq int) []byte {
+	return frameRecord(binary.AppendUvarint([]byte(walMarker), uint64(seq)), false)
+}
+
+// parseWALHeader returns the sequence number of a header record payload.
+func parseWALHeader(payload []byte) (int, bool) {
+	rest, ok := bytes.CutPrefix(payload, []byte(walMarker))
+	if !ok {
+		return 0, false
+	}
+	seq, n := binary.Uvarint(rest)
+	if n <= 0 || n != len(rest) {
+		return 0, false
+	}
+	return int(seq), true
+}
+
+// openLog opens the write-ahead log at path and passes the payload of each
+// intact record to replay, stopping at the first damaged record or replay
+// error and truncating the log there. nextSeq is the sequence number the
+// next segment or block would be written with; when the log's header names
+// an earlier one, its records were already written out and are discarded.
+// It returns the log positioned at its end, the log's size and the sequence
+// number its records will be written to, which is never below nextSeq.
+func openLog(path string, k *Keyring, nextSeq int, replay func(payload []byte) error) (*os.File, int64, int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	seq, size, err := replayLog(f, k, nextSeq, replay)
+	if err == nil && size == 0 {
+		// Empty, or holding records that were already written out.
+		size, err = resetLog(f, seq)
+	} else if err == nil {
+		err = rewindLog(f, size)
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, 0, err
+	}
+	return f, size, seq, nil
+}
+
+// replayLog replays the records of f for openLog and returns the sequence
+// number of its records and the size of its intact part, or 0 when its
+// records were already written out.
+func replayLog(f *os.File, k *Keyring, nextSeq int, replay func(payload []byte) error) (int, int64, error) {
+	r := bufio.NewReader(f)
+	seq := nextSeq
+	var good int64
+	for first := true; ; first = false {
+		payload, n, err := k.readRecord(r)
+		if isKeyError(err) {
+			return 0, 0, fmt.Errorf("replaying write-ahead log: %w", err)
+		}
+		if err != nil {
+			break
+		}
+		if first {
+			if s, ok := parseWALHeader(payload); ok {
+				if s < nextSeq {
+					return nextSeq, 0, nil
+				}
+				seq = s
+				good += n
+				continue
+			}
+		}
+		if replay(payload) != nil {
+			break
+		}
+		good += n
+	}
+	return seq, good, nil
+}
+
+// resetLog empties a write-ahead log and writes the header for records
+// written to the file with sequence number seq, returning the log's size.
+func resetLog(f *os.File, seq int) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	header := encodeWALHeader(seq)
+	if _, err := f.WriteAt(header, 0); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(int64(len(header)), io.SeekStart); err != nil {
+		return 0, err
+	}
+	return int64(len(header)), f.Sync()
+}
+
+// rewindLog truncates a write-ahead log to size and positions it there. It
+// drops a damaged tail, or a record that was only partly written.
+func rewindLog(f *os.File, size int64) error {
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	_, err := f.Seek(size, io.SeekStart)
+	return err
+}